@@ -1,11 +1,19 @@
 package errors
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
+	"reflect"
+	"strings"
 
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
 	any "github.com/golang/protobuf/ptypes/any"
+	// registers the well-known error detail types
+	_ "google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 )
 
@@ -43,6 +51,12 @@ const (
 	VersionNotSpecified Code = 7
 	// VersionUndecidable represents there being multiple upstreams that match the specified (service, version) pair
 	VersionUndecidable Code = 8
+	// InvalidParameter represents a user provided path or query parameter not matching the message's type
+	InvalidParameter Code = 9
+	// InvalidMetadata represents a user provided header not being valid gRPC metadata
+	InvalidMetadata Code = 10
+	// TypeNotFound represents a message type not used by the exposed methods of an upstream
+	TypeNotFound Code = 11
 )
 
 // Error satisfies the error interface
@@ -62,6 +76,12 @@ func (e *ProxyError) Error() string {
 		return "multiple versions of this service exist. specify version in request"
 	case VersionUndecidable:
 		return "multiple backends exist. add version annotations"
+	case InvalidParameter:
+		return "invalid parameter"
+	case InvalidMetadata:
+		return "invalid metadata"
+	case TypeNotFound:
+		return "no such message type"
 	default:
 		return "unknown failure"
 	}
@@ -84,11 +104,33 @@ func (e *ProxyError) HTTPStatusCode() int {
 		return http.StatusBadRequest
 	case VersionUndecidable:
 		return http.StatusBadRequest
+	case InvalidParameter:
+		return http.StatusBadRequest
+	case InvalidMetadata:
+		return http.StatusBadRequest
+	case TypeNotFound:
+		return http.StatusNotFound
 	default:
 		return http.StatusInternalServerError
 	}
 }
 
+// GRPCCode returns the gRPC status code matching the internal error
+func (e *ProxyError) GRPCCode() codes.Code {
+	switch e.Code {
+	case UpstreamConnFailure:
+		return codes.Unavailable
+	case MessageTypeMismatch, InvalidParameter, InvalidMetadata:
+		return codes.InvalidArgument
+	case MethodNotFound:
+		return codes.Unimplemented
+	case TypeNotFound:
+		return codes.NotFound
+	default:
+		return codes.Unknown
+	}
+}
+
 // WriteJSON writes an JSON representation of the internal error for responses
 func (e *ProxyError) WriteJSON(w io.Writer) error {
 	type JSONSchema struct {
@@ -106,6 +148,9 @@ type GRPCError struct {
 	StatusCode int        `json:"code"`
 	Message    string     `json:"message"`
 	Details    []*any.Any `json:"details,omitempty"`
+	// Resolver resolves the types of details which are not among the registered ones, e.g. the
+	// types known to the backend reflection service
+	Resolver jsonpb.AnyResolver `json:"-"`
 }
 
 // HTTPStatusCode converts gRPC status codes to HTTP status codes
@@ -122,7 +167,7 @@ func (e *GRPCError) HTTPStatusCode() int {
 	case codes.InvalidArgument:
 		return http.StatusBadRequest
 	case codes.DeadlineExceeded:
-		return http.StatusRequestTimeout
+		return http.StatusGatewayTimeout
 	case codes.NotFound:
 		return http.StatusNotFound
 	case codes.AlreadyExists:
@@ -161,3 +206,54 @@ func (e *GRPCError) Error() string {
 func (e *GRPCError) WriteJSON(w io.Writer) error {
 	return json.NewEncoder(w).Encode(e)
 }
+
+// MarshalJSON renders the details as the JSON representation of their message with an @type
+// field. Details of a type that cannot be resolved are rendered with their base64 encoded value.
+func (e *GRPCError) MarshalJSON() ([]byte, error) {
+	type JSONSchema struct {
+		StatusCode int               `json:"code"`
+		Message    string            `json:"message"`
+		Details    []json.RawMessage `json:"details,omitempty"`
+	}
+	return json.Marshal(&JSONSchema{
+		StatusCode: e.StatusCode,
+		Message:    e.Message,
+		Details:    e.renderDetails(),
+	})
+}
+
+func (e *GRPCError) renderDetails() []json.RawMessage {
+	var details []json.RawMessage
+	m := &jsonpb.Marshaler{AnyResolver: &registryResolver{e.Resolver}}
+	for _, d := range e.Details {
+		js, err := m.MarshalToString(d)
+		if err != nil {
+			b, _ := json.Marshal(map[string]string{
+				"@type": d.GetTypeUrl(),
+				"value": base64.StdEncoding.EncodeToString(d.GetValue()),
+			})
+			js = string(b)
+		}
+		details = append(details, json.RawMessage(js))
+	}
+	return details
+}
+
+// registryResolver resolves types among the registered ones first, then with the resolver if any
+type registryResolver struct {
+	resolver jsonpb.AnyResolver
+}
+
+func (r *registryResolver) Resolve(typeURL string) (proto.Message, error) {
+	name := typeURL
+	if i := strings.LastIndex(typeURL, "/"); i >= 0 {
+		name = typeURL[i+1:]
+	}
+	if t := proto.MessageType(name); t != nil {
+		return reflect.New(t.Elem()).Interface().(proto.Message), nil
+	}
+	if r.resolver == nil {
+		return nil, fmt.Errorf("unknown message type %q", name)
+	}
+	return r.resolver.Resolve(typeURL)
+}
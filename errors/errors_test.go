@@ -3,10 +3,16 @@ package errors
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"reflect"
 	"testing"
 
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	any "github.com/golang/protobuf/ptypes/any"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 )
 
@@ -43,6 +49,18 @@ func TestError_Error(t *testing.T) {
 			Code: VersionUndecidable,
 			msg:  "multiple backends exist. add version annotations",
 		},
+		{
+			Code: InvalidParameter,
+			msg:  "invalid parameter",
+		},
+		{
+			Code: InvalidMetadata,
+			msg:  "invalid metadata",
+		},
+		{
+			Code: TypeNotFound,
+			msg:  "no such message type",
+		},
 	}
 	for _, tc := range cases {
 		t.Run(fmt.Sprintf("%d", tc.Code), func(t *testing.T) {
@@ -89,7 +107,7 @@ func TestGRPCError_HTTPStatusCode(t *testing.T) {
 		},
 		{
 			codes.DeadlineExceeded,
-			http.StatusRequestTimeout,
+			http.StatusGatewayTimeout,
 		},
 		{
 			codes.NotFound,
@@ -171,3 +189,93 @@ func TestInternalError_WriteJSON(t *testing.T) {
 		t.Fatalf("got %v, want %v", got, want)
 	}
 }
+
+type mockResolver map[string]proto.Message
+
+func (r mockResolver) Resolve(typeURL string) (proto.Message, error) {
+	if m, ok := r[typeURL]; ok {
+		return m, nil
+	}
+	return nil, fmt.Errorf("unknown type %s", typeURL)
+}
+
+func TestGRPCError_MarshalJSON(t *testing.T) {
+	badRequest, err := ptypes.MarshalAny(&errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{
+			{Field: "name", Description: "must not be empty"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	retryInfo, err := ptypes.MarshalAny(&errdetails.RetryInfo{
+		RetryDelay: ptypes.DurationProto(1500000000),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	localized, err := proto.Marshal(&errdetails.LocalizedMessage{Locale: "en-US", Message: "Hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	custom := &any.Any{TypeUrl: "type.example.com/example.Custom", Value: localized}
+	unknown := &any.Any{TypeUrl: "type.example.com/example.Unknown", Value: []byte{1, 2}}
+
+	cases := []struct {
+		name     string
+		details  []*any.Any
+		resolver mockResolver
+		want     string
+	}{
+		{
+			name: "no details",
+			want: `{"code":3,"message":"invalid"}`,
+		},
+		{
+			name:    "well-known details",
+			details: []*any.Any{badRequest, retryInfo},
+			want: `{"code":3,"message":"invalid","details":[` +
+				`{"@type":"type.googleapis.com/google.rpc.BadRequest","fieldViolations":[{"field":"name","description":"must not be empty"}]},` +
+				`{"@type":"type.googleapis.com/google.rpc.RetryInfo","retryDelay":"1.500s"}]}`,
+		},
+		{
+			name:     "resolved details",
+			details:  []*any.Any{custom},
+			resolver: mockResolver{custom.TypeUrl: &errdetails.LocalizedMessage{}},
+			want: `{"code":3,"message":"invalid","details":[` +
+				`{"@type":"type.example.com/example.Custom","locale":"en-US","message":"Hello"}]}`,
+		},
+		{
+			name:    "unknown details",
+			details: []*any.Any{unknown},
+			want: `{"code":3,"message":"invalid","details":[` +
+				`{"@type":"type.example.com/example.Unknown","value":"AQI="}]}`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := &GRPCError{
+				StatusCode: int(codes.InvalidArgument),
+				Message:    "invalid",
+				Details:    tc.details,
+			}
+			if tc.resolver != nil {
+				e.Resolver = tc.resolver
+			}
+			b, err := json.Marshal(e)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got, want interface{}
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tc.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %s, want %s", b, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package errors
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+)
+
+// ProblemContentType is the media type of problem details documents
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details document, which describes internal errors and errors
+// returned by gRPC upstream alike
+type Problem struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Status   int               `json:"status"`
+	Detail   string            `json:"detail,omitempty"`
+	GRPCCode int               `json:"grpc_code"`
+	Details  []json.RawMessage `json:"details,omitempty"`
+}
+
+// NewProblem describes the error as a problem answered with the HTTP status code
+func NewProblem(err Error, status int) *Problem {
+	p := &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+	}
+	switch e := err.(type) {
+	case *GRPCError:
+		p.Detail = e.Message
+		p.GRPCCode = e.StatusCode
+		p.Details = e.renderDetails()
+	case *ProxyError:
+		p.Detail = e.Message
+		if p.Detail == "" {
+			p.Detail = e.Error()
+		}
+		p.GRPCCode = int(e.GRPCCode())
+	default:
+		p.Detail = err.Error()
+		p.GRPCCode = int(codes.Unknown)
+	}
+	return p
+}
+
+// WriteJSON writes the JSON representation of the problem for responses
+func (p *Problem) WriteJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(p)
+}
+
+// LoadHTTPStatusCodes reads the JSON file at path, which maps gRPC status codes, by name or number,
+// to the HTTP status codes errors with them are answered with, e.g. {"RESOURCE_EXHAUSTED": 429}
+func LoadHTTPStatusCodes(path string) (map[codes.Code]int, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read HTTP status codes")
+	}
+	var raw map[string]int
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, errors.Wrap(err, "failed to parse HTTP status codes")
+	}
+	statusCodes := make(map[codes.Code]int, len(raw))
+	for k, v := range raw {
+		var c codes.Code
+		if err := c.UnmarshalJSON([]byte(strconv.Quote(k))); err != nil {
+			if err := c.UnmarshalJSON([]byte(k)); err != nil {
+				return nil, errors.Errorf("invalid gRPC status code %q", k)
+			}
+		}
+		if v < 100 || v > 599 {
+			return nil, errors.Errorf("invalid HTTP status code %d of %s", v, k)
+		}
+		statusCodes[c] = v
+	}
+	return statusCodes, nil
+}
@@ -0,0 +1,117 @@
+package errors
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/ptypes"
+	any "github.com/golang/protobuf/ptypes/any"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+)
+
+func TestNewProblem(t *testing.T) {
+	detail, err := ptypes.MarshalAny(&errdetails.LocalizedMessage{Locale: "en-US", Message: "Hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name   string
+		err    Error
+		status int
+		want   string
+	}{
+		{
+			name: "gRPC error",
+			err: &GRPCError{
+				StatusCode: int(codes.ResourceExhausted),
+				Message:    "quota exceeded",
+				Details:    []*any.Any{detail},
+			},
+			status: http.StatusTooManyRequests,
+			want: `{"type":"about:blank","title":"Too Many Requests","status":429,"detail":"quota exceeded","grpc_code":8,` +
+				`"details":[{"@type":"type.googleapis.com/google.rpc.LocalizedMessage","locale":"en-US","message":"Hello"}]}`,
+		},
+		{
+			name: "proxy error",
+			err: &ProxyError{
+				Code:    MessageTypeMismatch,
+				Message: "input JSON does not match messageImpl type",
+			},
+			status: http.StatusBadRequest,
+			want: `{"type":"about:blank","title":"Bad Request","status":400,` +
+				`"detail":"input JSON does not match messageImpl type","grpc_code":3}`,
+		},
+		{
+			name:   "proxy error without message",
+			err:    &ProxyError{Code: UpstreamConnFailure},
+			status: http.StatusBadGateway,
+			want: `{"type":"about:blank","title":"Bad Gateway","status":502,` +
+				`"detail":"could not connect to backend gRPC service","grpc_code":14}`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := json.Marshal(NewProblem(tc.err, tc.status))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := string(b), tc.want; got != want {
+				t.Fatalf("got %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestLoadHTTPStatusCodes(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+		want    map[codes.Code]int
+		invalid bool
+	}{
+		{
+			name:    "by name and number",
+			content: `{"RESOURCE_EXHAUSTED": 429, "14": 502}`,
+			want: map[codes.Code]int{
+				codes.ResourceExhausted: http.StatusTooManyRequests,
+				codes.Unavailable:       http.StatusBadGateway,
+			},
+		},
+		{
+			name:    "unknown gRPC status code",
+			content: `{"TOO_MANY": 429}`,
+			invalid: true,
+		},
+		{
+			name:    "invalid HTTP status code",
+			content: `{"RESOURCE_EXHAUSTED": 42}`,
+			invalid: true,
+		},
+	}
+	dir, err := ioutil.TempDir("", "errors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, "status.json")
+			if err := ioutil.WriteFile(path, []byte(tc.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := LoadHTTPStatusCodes(path)
+			if (err != nil) != tc.invalid {
+				t.Fatalf("got error %v, want error %t", err, tc.invalid)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
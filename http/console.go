@@ -0,0 +1,246 @@
+package http
+
+import (
+	"net/http"
+)
+
+// consoleCSP only allows the console to load its own inline script and styles, and to call the
+// proxy it is served by
+const consoleCSP = "default-src 'self'; script-src 'unsafe-inline'; style-src 'unsafe-inline'"
+
+// ConsoleHandler handles requests for the API console, a self-contained page which lists the
+// services and methods from IntrospectHandler, and calls them through RPCCallHandler
+func (s *Server) ConsoleHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Security-Policy", consoleCSP)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(consoleHTML))
+	}
+}
+
+// consoleHTML is the API console. Its paths are relative to /actuator/console, so that it works
+// behind a path prefix.
+const consoleHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>gRPC Mate Console</title>
+<style>
+  body { margin: 0; font: 14px/1.4 sans-serif; display: flex; height: 100vh; color: #222; }
+  nav { width: 320px; overflow: auto; border-right: 1px solid #ddd; padding: 8px; box-sizing: border-box; }
+  main { flex: 1; overflow: auto; padding: 8px 16px; }
+  summary { font-weight: bold; cursor: pointer; padding: 4px 0; word-break: break-all; }
+  nav button { display: block; width: 100%; text-align: left; border: 0; background: none; padding: 3px 12px; cursor: pointer; }
+  nav button:hover, nav button.selected { background: #e8f0fe; }
+  label { display: block; font-weight: bold; margin: 12px 0 4px; }
+  textarea { width: 100%; box-sizing: border-box; font: 13px monospace; }
+  pre { background: #f6f6f6; padding: 8px; white-space: pre-wrap; word-break: break-all; margin: 0; }
+  .error { color: #b00; }
+  .muted { color: #777; }
+</style>
+</head>
+<body>
+<nav>
+  <div id="services" class="muted">Loading services...</div>
+</nav>
+<main>
+  <h2 id="method">Select a method</h2>
+  <div id="route" class="muted"></div>
+  <label for="headers">Headers <span class="muted">(one "Name: value" per line, e.g. Grpc-Metadata-Key: value)</span></label>
+  <textarea id="headers" rows="4"></textarea>
+  <label for="body">Request body</label>
+  <textarea id="body" rows="14"></textarea>
+  <p>
+    <button id="send" disabled>Send</button>
+    <button id="cancel" disabled>Cancel</button>
+  </p>
+  <label>Status</label>
+  <pre id="status"></pre>
+  <label>Response headers</label>
+  <pre id="response-headers"></pre>
+  <label>Response body</label>
+  <pre id="response-body"></pre>
+</main>
+<script>
+(function () {
+  "use strict";
+  var types = {};
+  var current = null;
+  var controller = null;
+
+  function $(id) {
+    return document.getElementById(id);
+  }
+
+  function text(id, s, isError) {
+    $(id).textContent = s;
+    $(id).className = isError ? "error" : "";
+  }
+
+  function load() {
+    fetch("services").then(function (res) {
+      if (!res.ok) {
+        throw new Error("failed to list services: " + res.status + " " + res.statusText);
+      }
+      return res.json();
+    }).then(function (data) {
+      (data.types || []).forEach(function (t) {
+        types[t.name] = t;
+      });
+      render(data.services || []);
+    }).catch(function (err) {
+      text("services", err.message, true);
+    });
+  }
+
+  function render(services) {
+    var nav = $("services");
+    nav.textContent = "";
+    nav.className = "";
+    services.forEach(function (svc) {
+      var details = document.createElement("details");
+      details.open = true;
+      var summary = document.createElement("summary");
+      summary.textContent = svc.name;
+      details.appendChild(summary);
+      (svc.methods || []).forEach(function (m) {
+        var button = document.createElement("button");
+        button.textContent = m.name + (m.deprecated ? " (deprecated)" : "");
+        button.title = m.comments || "";
+        button.onclick = function () {
+          var selected = nav.querySelector("button.selected");
+          if (selected) {
+            selected.className = "";
+          }
+          button.className = "selected";
+          select(svc, m);
+        };
+        details.appendChild(button);
+      });
+      nav.appendChild(details);
+    });
+  }
+
+  function select(svc, m) {
+    current = { service: svc.name, method: m };
+    text("method", svc.name + "/" + m.name);
+    text("route", "POST ../v1/" + svc.name + "/" + m.name + "  (" + m.input + " → " + m.output + ")");
+    var t = types[m.input];
+    var template = t && t.template ? t.template : {};
+    // the messages of client streaming calls are sent as a JSON array
+    $("body").value = JSON.stringify(m.client_streaming ? [template] : template, null, 2);
+    // bidirectional streaming needs a WebSocket, which the console does not open
+    var bidi = m.client_streaming && m.server_streaming;
+    $("send").disabled = bidi;
+    text("status", "");
+    if (bidi) {
+      text("status", "Bidirectional streaming methods can only be called through WebSocket", true);
+    }
+  }
+
+  function parseHeaders() {
+    var headers = new Headers({ "Content-Type": "application/json" });
+    $("headers").value.split("\n").forEach(function (line) {
+      var i = line.indexOf(":");
+      if (line.trim() === "") {
+        return;
+      }
+      if (i <= 0) {
+        throw new Error("invalid header line: " + line);
+      }
+      headers.append(line.slice(0, i).trim(), line.slice(i + 1).trim());
+    });
+    return headers;
+  }
+
+  function send() {
+    if (!current) {
+      return;
+    }
+    var headers;
+    try {
+      headers = parseHeaders();
+    } catch (err) {
+      text("status", err.message, true);
+      return;
+    }
+    controller = typeof AbortController === "function" ? new AbortController() : null;
+    $("send").disabled = true;
+    $("cancel").disabled = !controller;
+    text("status", "Sending...");
+    text("response-headers", "");
+    text("response-body", "");
+    var started = Date.now();
+    var url = "../v1/" + encodeURIComponent(current.service) + "/" + encodeURIComponent(current.method.name);
+    fetch(url, {
+      method: "POST",
+      headers: headers,
+      body: $("body").value,
+      signal: controller ? controller.signal : undefined
+    }).then(function (res) {
+      text("status", res.status + " " + res.statusText + "  (" + (Date.now() - started) + " ms to headers)", !res.ok);
+      var lines = [];
+      res.headers.forEach(function (value, name) {
+        lines.push(name + ": " + value);
+      });
+      text("response-headers", lines.join("\n"));
+      return readBody(res).then(function (body) {
+        // single JSON documents are pretty printed once complete, streams are kept as received
+        try {
+          text("response-body", JSON.stringify(JSON.parse(body), null, 2), !res.ok);
+        } catch (e) {
+          text("response-body", body, !res.ok);
+        }
+        $("status").textContent += "  (" + (Date.now() - started) + " ms in total)";
+      });
+    }).catch(function (err) {
+      text("status", err.name === "AbortError" ? "Canceled" : err.message, true);
+    }).then(function () {
+      controller = null;
+      $("send").disabled = !current;
+      $("cancel").disabled = true;
+    });
+  }
+
+  // readBody shows the body as it arrives, e.g. the messages of server streaming calls, and
+  // resolves to the whole body
+  function readBody(res) {
+    if (!res.body || !res.body.getReader || typeof TextDecoder !== "function") {
+      return res.text().then(function (body) {
+        $("response-body").textContent = body;
+        return body;
+      });
+    }
+    var reader = res.body.getReader();
+    var decoder = new TextDecoder();
+    var body = "";
+    function next() {
+      return reader.read().then(function (chunk) {
+        if (chunk.done) {
+          return body + decoder.decode();
+        }
+        body += decoder.decode(chunk.value, { stream: true });
+        $("response-body").textContent = body;
+        return next();
+      });
+    }
+    return next();
+  }
+
+  $("send").onclick = send;
+  $("cancel").onclick = function () {
+    if (controller) {
+      controller.abort();
+    }
+  };
+  load();
+})();
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,131 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/gdong42/grpc-mate/route"
+)
+
+// RESTConventionMapper derives a RESTful route from the name of a method and the shape of its input
+// message, for methods without google.api.http options, e.g.
+//
+//	GetFoo(GetFooRequest{name})       -> GET    /v1/foos/{name}
+//	ListFoos(ListFoosRequest)         -> GET    /v1/foos
+//	CreateFoo(CreateFooRequest)       -> POST   /v1/foos
+//	UpdateFoo(UpdateFooRequest{foo})  -> PATCH  /v1/foos/{foo.name}
+//	DeleteFoo(DeleteFooRequest{name}) -> DELETE /v1/foos/{name}
+//
+// The resource is identified by the name, id, or foo_id field, looked up in this order in the input
+// message, and in the foo field of the input message for updates. Methods that follow none of the
+// conventions, and streaming methods, get no route but the default one.
+func RESTConventionMapper(m *route.Method) []*route.Route {
+	if m.ClientStreaming || m.ServerStreaming {
+		return nil
+	}
+	verb, resource := splitMethodName(m.Name)
+	if resource == "" {
+		return nil
+	}
+	var httpMethod, path, body string
+	switch verb {
+	case "Get":
+		id := identifierField(m, "", resource)
+		if id == "" {
+			return nil
+		}
+		httpMethod, path = http.MethodGet, "/v1/"+pluralize(resource)+"/{"+id+"}"
+	case "List":
+		httpMethod, path = http.MethodGet, "/v1/"+lowerFirst(resource)
+	case "Create":
+		httpMethod, path, body = http.MethodPost, "/v1/"+pluralize(resource), "*"
+	case "Update":
+		httpMethod, path, body = http.MethodPatch, "/v1/"+pluralize(resource), "*"
+		if id := identifierField(m, "", resource); id != "" {
+			path += "/{" + id + "}"
+		} else if f := snakeCase(resource); m.HasInputField(f) {
+			if id := identifierField(m, f+".", resource); id != "" {
+				path += "/{" + id + "}"
+				body = f
+			}
+		}
+	case "Delete":
+		id := identifierField(m, "", resource)
+		if id == "" {
+			return nil
+		}
+		httpMethod, path = http.MethodDelete, "/v1/"+pluralize(resource)+"/{"+id+"}"
+	default:
+		return nil
+	}
+	tmpl, err := route.ParseTemplate(path)
+	if err != nil {
+		return nil
+	}
+	return []*route.Route{{
+		HTTPMethod: httpMethod,
+		Template:   tmpl,
+		Service:    m.Service,
+		Method:     m.Name,
+		Body:       body,
+	}}
+}
+
+// splitMethodName splits a method name into its leading verb and the resource, e.g. GetFoo into
+// Get and Foo
+func splitMethodName(name string) (string, string) {
+	for i, c := range name {
+		if i > 0 && unicode.IsUpper(c) {
+			return name[:i], name[i:]
+		}
+	}
+	return name, ""
+}
+
+// identifierField finds the field identifying the resource among the fields with the prefix
+func identifierField(m *route.Method, prefix, resource string) string {
+	for _, f := range []string{"name", "id", snakeCase(resource) + "_id"} {
+		if m.HasInputField(prefix + f) {
+			return prefix + f
+		}
+	}
+	return ""
+}
+
+// pluralize makes the collection name of a resource, e.g. BookCategory into bookCategories
+func pluralize(resource string) string {
+	r := lowerFirst(resource)
+	switch {
+	case strings.HasSuffix(r, "y") && !strings.HasSuffix(r, "ay") && !strings.HasSuffix(r, "ey") &&
+		!strings.HasSuffix(r, "oy") && !strings.HasSuffix(r, "uy"):
+		return strings.TrimSuffix(r, "y") + "ies"
+	case strings.HasSuffix(r, "s") || strings.HasSuffix(r, "x") || strings.HasSuffix(r, "z") ||
+		strings.HasSuffix(r, "ch") || strings.HasSuffix(r, "sh"):
+		return r + "es"
+	default:
+		return r + "s"
+	}
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+// snakeCase converts a resource name into a field name, e.g. BookCategory into book_category
+func snakeCase(s string) string {
+	var b strings.Builder
+	for i, c := range s {
+		if unicode.IsUpper(c) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			c = unicode.ToLower(c)
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
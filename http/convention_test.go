@@ -0,0 +1,114 @@
+package http
+
+import (
+	"testing"
+
+	"github.com/gdong42/grpc-mate/route"
+)
+
+func TestRESTConventionMapper(t *testing.T) {
+	cases := []struct {
+		name        string
+		method      string
+		inputFields []string
+		streaming   bool
+		route       string
+		body        string
+	}{
+		{
+			name:        "get by name",
+			method:      "GetFoo",
+			inputFields: []string{"name"},
+			route:       "GET /v1/foos/{name}",
+		},
+		{
+			name:        "get by resource id",
+			method:      "GetBookCategory",
+			inputFields: []string{"book_category_id", "view"},
+			route:       "GET /v1/bookCategories/{book_category_id}",
+		},
+		{
+			name:        "get without identifier",
+			method:      "GetFoo",
+			inputFields: []string{"filter"},
+			route:       "",
+		},
+		{
+			name:   "list",
+			method: "ListFoos",
+			route:  "GET /v1/foos",
+		},
+		{
+			name:        "create",
+			method:      "CreateBox",
+			inputFields: []string{"box"},
+			route:       "POST /v1/boxes",
+			body:        "*",
+		},
+		{
+			name:        "update by nested identifier",
+			method:      "UpdateFoo",
+			inputFields: []string{"foo", "foo.name", "update_mask"},
+			route:       "PATCH /v1/foos/{foo.name}",
+			body:        "foo",
+		},
+		{
+			name:        "update by identifier",
+			method:      "UpdateFoo",
+			inputFields: []string{"id", "title"},
+			route:       "PATCH /v1/foos/{id}",
+			body:        "*",
+		},
+		{
+			name:        "delete",
+			method:      "DeleteFoo",
+			inputFields: []string{"id"},
+			route:       "DELETE /v1/foos/{id}",
+		},
+		{
+			name:   "unknown verb",
+			method: "SayHello",
+			route:  "",
+		},
+		{
+			name:   "verb only",
+			method: "Get",
+			route:  "",
+		},
+		{
+			name:      "streaming",
+			method:    "ListFoos",
+			streaming: true,
+			route:     "",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			routes := RESTConventionMapper(&route.Method{
+				Service:         "example.Foos",
+				Name:            tc.method,
+				InputFields:     tc.inputFields,
+				ServerStreaming: tc.streaming,
+			})
+			if tc.route == "" {
+				if len(routes) != 0 {
+					t.Fatalf("got %v, want no route", routes)
+				}
+				return
+			}
+			if len(routes) != 1 {
+				t.Fatalf("got %d routes, want 1", len(routes))
+			}
+			r := routes[0]
+			if got, want := r.String(), tc.route; got != want {
+				t.Fatalf("got %s, want %s", got, want)
+			}
+			if got, want := r.Body, tc.body; got != want {
+				t.Fatalf("got body %s, want %s", got, want)
+			}
+			if got, want := r.Service+"/"+r.Method, "example.Foos/"+tc.method; got != want {
+				t.Fatalf("got %s, want %s", got, want)
+			}
+		})
+	}
+}
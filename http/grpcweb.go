@@ -0,0 +1,313 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+
+	perrors "github.com/gdong42/grpc-mate/errors"
+	"github.com/gdong42/grpc-mate/metadata"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	grpc_metadata "google.golang.org/grpc/metadata"
+)
+
+const (
+	grpcWebContentType     = "application/grpc-web"
+	grpcWebTextContentType = "application/grpc-web-text"
+	// grpcWebTrailerFlag marks a frame carrying trailers, and grpcWebCompressedFlag a compressed one
+	grpcWebTrailerFlag    = 0x80
+	grpcWebCompressedFlag = 0x01
+	// defaultGRPCWebMaxMessageSize is the default maximum size of request messages, the same as
+	// the default maximum size of messages received by gRPC servers
+	defaultGRPCWebMaxMessageSize = 4 << 20
+)
+
+// isGRPCWebRequest tells if the request is a gRPC-Web call
+func isGRPCWebRequest(r *http.Request) bool {
+	return r.Method == http.MethodPost && strings.HasPrefix(r.Header.Get("Content-Type"), grpcWebContentType)
+}
+
+// isGRPCWebPreflight tells if the request is a CORS preflight request of a gRPC-Web call, which
+// browsers send before every call from another origin
+func isGRPCWebPreflight(r *http.Request) bool {
+	if r.Method != http.MethodOptions || r.Header.Get("Origin") == "" ||
+		r.Header.Get("Access-Control-Request-Method") != http.MethodPost {
+		return false
+	}
+	parts := strings.Split(r.URL.Path, "/")
+	return len(parts) == 3 && parts[1] != "" && parts[2] != ""
+}
+
+// grpcWebPreflight answers a CORS preflight request of a gRPC-Web call, allowing the requested
+// headers from the allowed origins only
+func (s *Server) grpcWebPreflight(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	w.Header().Set("Vary", "Origin, Access-Control-Request-Headers")
+	if !s.allowsOrigin(origin) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	w.Header().Set("Access-Control-Allow-Methods", http.MethodPost)
+	if headers := r.Header.Get("Access-Control-Request-Headers"); headers != "" {
+		w.Header().Set("Access-Control-Allow-Headers", headers)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// allowsOrigin tells if cross-origin gRPC-Web and WebSocket calls are allowed from the origin, *
+// allowing any
+func (s *Server) allowsOrigin(origin string) bool {
+	for _, o := range s.allowedOrigins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// GRPCWebHandler handles gRPC-Web calls, in the binary or the base64 text format, whose messages
+// are forwarded to the upstream as they are. Response messages are sent as they arrive, followed by
+// a trailer frame carrying the gRPC status and the trailer metadata, while header metadata is sent
+// as response headers. Request messages larger than the maximum message size fail the call, and
+// text bodies, which are decoded whole, are limited to the encoded size of a frame of that size.
+// Requests failing before the call, e.g. with an undecodable body or invalid metadata, are answered
+// with a trailer frame as well, for gRPC-Web clients to read the status. Calls from allowed origins
+// are answered with CORS headers exposing the status and metadata headers.
+func (s *Server) GRPCWebHandler(client GrpcClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		cors := origin != "" && s.allowsOrigin(origin)
+		if origin != "" {
+			w.Header().Set("Vary", "Origin")
+		}
+		if cors {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+		// example path:
+		// example.com/pkg.Service/Method
+		parts := strings.Split(r.URL.Path, "/")
+		if len(parts) != 3 || parts[1] == "" || parts[2] == "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		contentType := r.Header.Get("Content-Type")
+		var text bool
+		switch contentType {
+		case grpcWebContentType, grpcWebContentType + "+proto":
+		case grpcWebTextContentType, grpcWebTextContentType + "+proto":
+			text = true
+		default:
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+
+		fw := &grpcWebWriter{w: w, text: text, contentType: contentType, cors: cors}
+		fw.flusher, _ = w.(http.Flusher)
+		fail := func(err error) {
+			s.logger.Error("error in handling gRPC-Web call",
+				zap.String("err", err.Error()))
+			fw.write(grpcWebTrailerFlag, grpcWebTrailers(err, nil), nil)
+		}
+
+		body := io.Reader(r.Body)
+		defer r.Body.Close()
+		if text {
+			limit := int64(base64.StdEncoding.EncodedLen(5 + s.grpcWebMaxMessageSize))
+			b, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+			if err != nil && int64(len(b)) == limit {
+				fail(&perrors.GRPCError{
+					StatusCode: int(codes.ResourceExhausted),
+					Message:    fmt.Sprintf("received body larger than max (%d)", limit),
+				})
+				return
+			}
+			if err != nil {
+				fail(invalidGRPCWebFrame(err.Error()))
+				return
+			}
+			b, err = decodeBase64Chunks(b)
+			if err != nil {
+				fail(invalidGRPCWebFrame(err.Error()))
+				return
+			}
+			body = bytes.NewReader(b)
+		}
+
+		mapper := s.metadataConfig.Mapper(parts[1], parts[2])
+		md, err := mapper.FromHeaders(r.Header)
+		if err != nil {
+			fail(err)
+			return
+		}
+		timeout, err := s.callTimeout(r, callee{Service: parts[1], Method: parts[2]})
+		if err != nil {
+			fail(err)
+			return
+		}
+		ctx, cancel := withTimeout(grpc_metadata.NewOutgoingContext(r.Context(), grpc_metadata.MD(md)), timeout)
+		defer cancel()
+		var header, trailer metadata.Metadata
+		err = client.InvokeRaw(ctx, r.URL.Path, grpcWebFrames(body, s.grpcWebMaxMessageSize), &header, &trailer, func(m []byte) error {
+			return fw.write(0, m, mapper.Filter(header))
+		})
+		if err != nil {
+			s.logger.Error("error in handling gRPC-Web call",
+				zap.String("err", err.Error()))
+		}
+		fw.write(grpcWebTrailerFlag, grpcWebTrailers(err, mapper.Filter(trailer)), mapper.Filter(header))
+	}
+}
+
+// grpcWebFrames returns a function reading the message of the next data frame from r, failing
+// before reading a message larger than maxSize. It returns io.EOF after the last one.
+func grpcWebFrames(r io.Reader, maxSize int) func() ([]byte, error) {
+	br := bufio.NewReader(r)
+	return func() ([]byte, error) {
+		prefix := make([]byte, 5)
+		if _, err := io.ReadFull(br, prefix); err == io.EOF {
+			return nil, io.EOF
+		} else if err != nil {
+			return nil, invalidGRPCWebFrame(err.Error())
+		}
+		if prefix[0]&grpcWebCompressedFlag != 0 {
+			return nil, invalidGRPCWebFrame("compressed messages are not supported")
+		}
+		size := binary.BigEndian.Uint32(prefix[1:])
+		if uint64(size) > uint64(maxSize) {
+			return nil, &perrors.GRPCError{
+				StatusCode: int(codes.ResourceExhausted),
+				Message:    fmt.Sprintf("received message larger than max (%d vs. %d)", size, maxSize),
+			}
+		}
+		m := make([]byte, size)
+		if _, err := io.ReadFull(br, m); err != nil {
+			return nil, invalidGRPCWebFrame(err.Error())
+		}
+		if prefix[0]&grpcWebTrailerFlag != 0 {
+			return nil, io.EOF
+		}
+		return m, nil
+	}
+}
+
+func invalidGRPCWebFrame(reason string) error {
+	return &perrors.ProxyError{
+		Code:    perrors.MessageTypeMismatch,
+		Message: "invalid gRPC-Web frame: " + reason,
+	}
+}
+
+// grpcWebTrailers makes the content of the trailer frame, from the status of the call and the
+// trailer metadata
+func grpcWebTrailers(err error, trailer metadata.Metadata) []byte {
+	st := grpcStatus(err)
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "grpc-status:%d\r\n", st.StatusCode)
+	if st.Message != "" {
+		fmt.Fprintf(&b, "grpc-message:%s\r\n", percentEncode(st.Message))
+	}
+	keys := make([]string, 0, len(trailer))
+	for k := range trailer {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range trailer[k] {
+			fmt.Fprintf(&b, "%s:%s\r\n", strings.ToLower(k), v)
+		}
+	}
+	return b.Bytes()
+}
+
+// grpcWebWriter writes gRPC-Web frames, starting the response on the first write
+type grpcWebWriter struct {
+	w           http.ResponseWriter
+	flusher     http.Flusher
+	text        bool
+	contentType string
+	// cors tells whether the headers are exposed to cross-origin callers
+	cors    bool
+	started bool
+}
+
+func (fw *grpcWebWriter) write(flag byte, m []byte, header metadata.Metadata) error {
+	if !fw.started {
+		for k, v := range header {
+			fw.w.Header()[http.CanonicalHeaderKey(k)] = v
+		}
+		fw.w.Header().Set("Content-Type", fw.contentType)
+		if fw.cors {
+			fw.w.Header().Set("Access-Control-Expose-Headers", exposedHeaders(header))
+		}
+		fw.w.WriteHeader(http.StatusOK)
+		fw.started = true
+	}
+	frame := make([]byte, 5+len(m))
+	frame[0] = flag
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(m)))
+	copy(frame[5:], m)
+	if fw.text {
+		// every frame is encoded on its own, as padded base64 chunks may be concatenated
+		frame = []byte(base64.StdEncoding.EncodeToString(frame))
+	}
+	if _, err := fw.w.Write(frame); err != nil {
+		return err
+	}
+	if fw.flusher != nil {
+		fw.flusher.Flush()
+	}
+	return nil
+}
+
+// exposedHeaders lists the gRPC status headers, which trailers-only responses of other gRPC-Web
+// proxies carry, along with the header metadata, for cross-origin callers to read them
+func exposedHeaders(header metadata.Metadata) string {
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, http.CanonicalHeaderKey(k))
+	}
+	sort.Strings(keys)
+	return strings.Join(append([]string{"Grpc-Status", "Grpc-Message"}, keys...), ", ")
+}
+
+// decodeBase64Chunks decodes base64 made of concatenated padded chunks, by decoding every 4 bytes
+// quantum on its own
+func decodeBase64Chunks(b []byte) ([]byte, error) {
+	b = bytes.Join(bytes.Fields(b), nil)
+	if len(b)%4 != 0 {
+		return nil, fmt.Errorf("invalid base64 length %d", len(b))
+	}
+	out := make([]byte, 0, len(b)/4*3)
+	q := make([]byte, 3)
+	for i := 0; i < len(b); i += 4 {
+		n, err := base64.StdEncoding.Decode(q, b[i:i+4])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, q[:n]...)
+	}
+	return out, nil
+}
+
+// percentEncode encodes a grpc-message value as required by the gRPC protocol
+func percentEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < ' ' || c > '~' || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,323 @@
+package http
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	perrors "github.com/gdong42/grpc-mate/errors"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+)
+
+func grpcWebFrame(flag byte, m string) []byte {
+	frame := make([]byte, 5+len(m))
+	frame[0] = flag
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(m)))
+	copy(frame[5:], m)
+	return frame
+}
+
+func TestGRPCWebHandler(t *testing.T) {
+	binaryRequest := append(grpcWebFrame(0, "a"), grpcWebFrame(0, "bc")...)
+	binaryResponse := bytes.Join([][]byte{
+		grpcWebFrame(0, "a"),
+		grpcWebFrame(0, "bc"),
+		grpcWebFrame(0x80, "grpc-status:0\r\nx-trailer:trailer\r\n"),
+	}, nil)
+	textRequest := base64.StdEncoding.EncodeToString(grpcWebFrame(0, "a")) +
+		base64.StdEncoding.EncodeToString(grpcWebFrame(0, "bc"))
+	textResponse := base64.StdEncoding.EncodeToString(grpcWebFrame(0, "a")) +
+		base64.StdEncoding.EncodeToString(grpcWebFrame(0, "bc")) +
+		base64.StdEncoding.EncodeToString(grpcWebFrame(0x80, "grpc-status:0\r\nx-trailer:trailer\r\n"))
+
+	cases := []struct {
+		name        string
+		contentType string
+		header      http.Header
+		body        []byte
+		err         error
+		status      int
+		response    []byte
+		// invalid tells if the request fails before the call
+		invalid bool
+	}{
+		{
+			name:        "binary",
+			contentType: "application/grpc-web",
+			body:        binaryRequest,
+			status:      http.StatusOK,
+			response:    binaryResponse,
+		},
+		{
+			name:        "binary proto",
+			contentType: "application/grpc-web+proto",
+			body:        binaryRequest,
+			status:      http.StatusOK,
+			response:    binaryResponse,
+		},
+		{
+			name:        "text",
+			contentType: "application/grpc-web-text",
+			body:        []byte(textRequest),
+			status:      http.StatusOK,
+			response:    []byte(textResponse),
+		},
+		{
+			name:        "grpc error",
+			contentType: "application/grpc-web+proto",
+			body:        grpcWebFrame(0, "a"),
+			err: &perrors.GRPCError{
+				StatusCode: int(codes.NotFound),
+				Message:    "not found: 100%",
+			},
+			status: http.StatusOK,
+			response: append(grpcWebFrame(0, "a"),
+				grpcWebFrame(0x80, "grpc-status:5\r\ngrpc-message:not found: 100%25\r\nx-trailer:trailer\r\n")...),
+		},
+		{
+			name:        "truncated frame",
+			contentType: "application/grpc-web+proto",
+			body:        grpcWebFrame(0, "a")[:4],
+			status:      http.StatusOK,
+			response: grpcWebFrame(0x80, "grpc-status:3\r\n"+
+				"grpc-message:invalid gRPC-Web frame: unexpected EOF\r\nx-trailer:trailer\r\n"),
+		},
+		{
+			name:        "undecodable text",
+			contentType: "application/grpc-web-text",
+			body:        []byte("abc"),
+			status:      http.StatusOK,
+			response: []byte(base64.StdEncoding.EncodeToString(grpcWebFrame(0x80, "grpc-status:3\r\n"+
+				"grpc-message:invalid gRPC-Web frame: invalid base64 length 3\r\n"))),
+			invalid: true,
+		},
+		{
+			name:        "invalid binary metadata",
+			contentType: "application/grpc-web+proto",
+			header:      http.Header{"Grpc-Metadata-Foo-Bin": {"!!!"}},
+			body:        binaryRequest,
+			status:      http.StatusOK,
+			response: grpcWebFrame(0x80, "grpc-status:3\r\n"+
+				"grpc-message:invalid metadata foo-bin: invalid base64 value \"!!!\"\r\n"),
+			invalid: true,
+		},
+		{
+			name:        "invalid timeout",
+			contentType: "application/grpc-web+proto",
+			header:      http.Header{"Grpc-Timeout": {"1x"}},
+			body:        binaryRequest,
+			status:      http.StatusOK,
+			response: grpcWebFrame(0x80, "grpc-status:3\r\n"+
+				"grpc-message:invalid timeout Grpc-Timeout: invalid unit of \"1x\"\r\n"),
+			invalid: true,
+		},
+		{
+			name:        "unsupported format",
+			contentType: "application/grpc-web+json",
+			body:        binaryRequest,
+			status:      http.StatusUnsupportedMediaType,
+			response:    []byte{},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mc := &mockClient{
+				isReady:   true,
+				streamErr: tc.err,
+			}
+			server := New(mc, zap.NewNop())
+			req, err := http.NewRequest("POST", "/svc1.Service/Method1", bytes.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for k, v := range tc.header {
+				req.Header[k] = v
+			}
+			req.Header.Set("Content-Type", tc.contentType)
+			rr := httptest.NewRecorder()
+			server.router.ServeHTTP(rr, req)
+
+			if got, want := rr.Code, tc.status; got != want {
+				t.Fatalf("got %d, want %d", got, want)
+			}
+			if got, want := rr.Body.Bytes(), tc.response; !bytes.Equal(got, want) {
+				t.Fatalf("got %q, want %q", got, want)
+			}
+			if tc.status != http.StatusOK {
+				return
+			}
+			if got, want := rr.Header().Get("Content-Type"), tc.contentType; got != want {
+				t.Fatalf("got %q, want %q", got, want)
+			}
+			if tc.invalid {
+				return
+			}
+			if got, want := rr.Header().Get("X-Header"), "header"; got != want {
+				t.Fatalf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestGRPCWebHandlerMaxMessageSize(t *testing.T) {
+	oversized := make([]byte, 5)
+	binary.BigEndian.PutUint32(oversized[1:], 0xffffffff)
+
+	cases := []struct {
+		name        string
+		contentType string
+		body        []byte
+		status      int
+		response    []byte
+	}{
+		{
+			name:        "message within limit",
+			contentType: "application/grpc-web+proto",
+			body:        grpcWebFrame(0, "abcd"),
+			status:      http.StatusOK,
+			response: append(grpcWebFrame(0, "abcd"),
+				grpcWebFrame(0x80, "grpc-status:0\r\nx-trailer:trailer\r\n")...),
+		},
+		{
+			name:        "message over limit",
+			contentType: "application/grpc-web+proto",
+			body:        grpcWebFrame(0, "abcde"),
+			status:      http.StatusOK,
+			response: grpcWebFrame(0x80, "grpc-status:8\r\n"+
+				"grpc-message:received message larger than max (5 vs. 4)\r\nx-trailer:trailer\r\n"),
+		},
+		{
+			name:        "oversized length prefix",
+			contentType: "application/grpc-web+proto",
+			body:        oversized,
+			status:      http.StatusOK,
+			response: grpcWebFrame(0x80, "grpc-status:8\r\n"+
+				"grpc-message:received message larger than max (4294967295 vs. 4)\r\nx-trailer:trailer\r\n"),
+		},
+		{
+			name:        "text body over limit",
+			contentType: "application/grpc-web-text",
+			body: []byte(base64.StdEncoding.EncodeToString(grpcWebFrame(0, "ab")) +
+				base64.StdEncoding.EncodeToString(grpcWebFrame(0, "cd"))),
+			status: http.StatusOK,
+			response: []byte(base64.StdEncoding.EncodeToString(grpcWebFrame(0x80, "grpc-status:8\r\n"+
+				"grpc-message:received body larger than max (12)\r\n"))),
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mc := &mockClient{isReady: true}
+			server := New(mc, zap.NewNop(), WithGRPCWebMaxMessageSize(4))
+			req, err := http.NewRequest("POST", "/svc1.Service/Method1", bytes.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", tc.contentType)
+			rr := httptest.NewRecorder()
+			server.router.ServeHTTP(rr, req)
+
+			if got, want := rr.Code, tc.status; got != want {
+				t.Fatalf("got %d, want %d", got, want)
+			}
+			if got, want := rr.Body.Bytes(), tc.response; !bytes.Equal(got, want) {
+				t.Fatalf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestGRPCWebCORS(t *testing.T) {
+	cases := []struct {
+		name    string
+		method  string
+		origin  string
+		headers http.Header
+		status  int
+		want    http.Header
+	}{
+		{
+			name:   "preflight from allowed origin",
+			method: http.MethodOptions,
+			origin: "https://app.example.com",
+			headers: http.Header{
+				"Access-Control-Request-Method":  {"POST"},
+				"Access-Control-Request-Headers": {"content-type,x-grpc-web,x-user-agent"},
+			},
+			status: http.StatusNoContent,
+			want: http.Header{
+				"Vary":                         {"Origin, Access-Control-Request-Headers"},
+				"Access-Control-Allow-Origin":  {"https://app.example.com"},
+				"Access-Control-Allow-Methods": {"POST"},
+				"Access-Control-Allow-Headers": {"content-type,x-grpc-web,x-user-agent"},
+			},
+		},
+		{
+			name:   "preflight from other origin",
+			method: http.MethodOptions,
+			origin: "https://evil.example.com",
+			headers: http.Header{
+				"Access-Control-Request-Method": {"POST"},
+			},
+			status: http.StatusForbidden,
+			want: http.Header{
+				"Vary": {"Origin, Access-Control-Request-Headers"},
+			},
+		},
+		{
+			name:   "call from allowed origin",
+			method: http.MethodPost,
+			origin: "https://app.example.com",
+			headers: http.Header{
+				"Content-Type": {"application/grpc-web+proto"},
+			},
+			status: http.StatusOK,
+			want: http.Header{
+				"Vary":                          {"Origin"},
+				"Access-Control-Allow-Origin":   {"https://app.example.com"},
+				"Access-Control-Expose-Headers": {"Grpc-Status, Grpc-Message, X-Header"},
+				"Content-Type":                  {"application/grpc-web+proto"},
+				"X-Header":                      {"header"},
+			},
+		},
+		{
+			name:   "call from other origin",
+			method: http.MethodPost,
+			origin: "https://evil.example.com",
+			headers: http.Header{
+				"Content-Type": {"application/grpc-web+proto"},
+			},
+			status: http.StatusOK,
+			want: http.Header{
+				"Vary":         {"Origin"},
+				"Content-Type": {"application/grpc-web+proto"},
+				"X-Header":     {"header"},
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mc := &mockClient{isReady: true}
+			server := New(mc, zap.NewNop(), WithAllowedOrigins([]string{"https://app.example.com"}))
+			req, err := http.NewRequest(tc.method, "/svc1.Service/Method1", bytes.NewReader(grpcWebFrame(0, "a")))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header = tc.headers
+			req.Header.Set("Origin", tc.origin)
+			rr := httptest.NewRecorder()
+			server.router.ServeHTTP(rr, req)
+
+			if got, want := rr.Code, tc.status; got != want {
+				t.Fatalf("got %d, want %d", got, want)
+			}
+			if got, want := rr.Header(), tc.want; !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -2,14 +2,19 @@ package http
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"strings"
 
 	perrors "github.com/gdong42/grpc-mate/errors"
 	"github.com/gdong42/grpc-mate/metadata"
+	"github.com/gdong42/grpc-mate/openapi"
+	"github.com/gdong42/grpc-mate/route"
+	"github.com/gorilla/websocket"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
 	grpc_metadata "google.golang.org/grpc/metadata"
 )
 
@@ -40,23 +45,199 @@ func (s *Server) IntrospectHandler(client GrpcClient) http.HandlerFunc {
 		}
 		// example path and query parameter:
 		// example.com/actuator/services - list all services
+		s.writeIntrospection(w, r, client, client.Introspect)
+	}
+}
+
+// IntrospectServiceHandler handles requests that introspect a service and the types it uses
+func (s *Server) IntrospectServiceHandler(client GrpcClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		// example path:
+		// example.com/actuator/services/helloworld.Greeter
+		serviceName := strings.TrimPrefix(r.URL.Path, "/actuator/services/")
+		if serviceName == "" || strings.Contains(serviceName, "/") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		s.writeIntrospection(w, r, client, func() ([]byte, error) {
+			return client.IntrospectService(serviceName)
+		})
+	}
+}
+
+// IntrospectTypeHandler handles requests that introspect a message or enum type
+func (s *Server) IntrospectTypeHandler(client GrpcClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		// example path:
+		// example.com/actuator/types/helloworld.HelloRequest
+		typeName := strings.TrimPrefix(r.URL.Path, "/actuator/types/")
+		if typeName == "" || strings.Contains(typeName, "/") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		s.writeIntrospection(w, r, client, func() ([]byte, error) {
+			return client.IntrospectType(typeName)
+		})
+	}
+}
+
+// writeIntrospection writes the introspection tagged by the descriptor hash, or Not Modified if the
+// request already has it, so that clients polling for changes do not get the same one again
+func (s *Server) writeIntrospection(w http.ResponseWriter, r *http.Request, client GrpcClient,
+	introspect func() ([]byte, error)) {
+
+	if !client.IsReady() {
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	// the hash is got first, for the introspection not to be older than its tag if the descriptors
+	// change in between
+	hash, err := client.DescriptorHash()
+	var response []byte
+	if err == nil {
+		etag := `"` + hash + `"`
+		// clients revalidate the introspection before using it
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("ETag", etag)
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		response, err = introspect()
+	}
+	if err != nil {
+		w.Header().Del("Cache-Control")
+		w.Header().Del("ETag")
+		s.returnError(w, err)
+		s.logger.Error("error in introspection",
+			zap.String("err", err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(response)
+}
+
+// etagMatches tells if the If-None-Match header lists the entity tag, weakly compared, or is *
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, t := range strings.Split(ifNoneMatch, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// OpenAPIHandler handles requests that describe all exposed methods and their routes as an OpenAPI
+// document, in YAML or JSON
+func (s *Server) OpenAPIHandler(client GrpcClient, yaml bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		// example path:
+		// example.com/actuator/openapi.json
+		// example.com/actuator/openapi.yaml
 
 		if !client.IsReady() {
 			w.WriteHeader(http.StatusBadGateway)
 			return
 		}
-		response, err := client.Introspect()
+		response, err := client.OpenAPI()
 		if err != nil {
-			returnError(w, errors.Cause(err).(perrors.Error))
-			s.logger.Error("error in introspection",
+			s.returnError(w, err)
+			s.logger.Error("error in generating OpenAPI document",
 				zap.String("err", err.Error()))
 			return
 		}
+		contentType := "application/json"
+		if yaml {
+			if response, err = openapi.JSONToYAML(response); err != nil {
+				s.returnError(w, &perrors.ProxyError{Code: perrors.Unknown, Message: err.Error()})
+				return
+			}
+			contentType = "application/yaml"
+		}
 
-		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Type", contentType)
 		w.WriteHeader(http.StatusOK)
 		w.Write(response)
-		return
+	}
+}
+
+// JSONSchemaHandler handles requests that describe a message type as a JSON Schema document
+func (s *Server) JSONSchemaHandler(client GrpcClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		// example path:
+		// example.com/actuator/schemas/helloworld.HelloRequest
+		typeName := strings.TrimPrefix(r.URL.Path, "/actuator/schemas/")
+		if typeName == "" || strings.Contains(typeName, "/") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if !client.IsReady() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		response, err := client.JSONSchema(typeName)
+		if err != nil {
+			s.returnError(w, err)
+			s.logger.Error("error in describing type",
+				zap.String("err", err.Error()))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/schema+json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(response)
+	}
+}
+
+// DescriptorCacheHandler handles requests that report the statistics of the descriptor cache, or
+// invalidate it if the actuator is writable
+func (s *Server) DescriptorCacheHandler(client GrpcClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// example path and query parameter:
+		// GET example.com/actuator/descriptors - report hits, misses and cached services
+		// DELETE example.com/actuator/descriptors - invalidate all services
+		// DELETE example.com/actuator/descriptors?service=helloworld.Greeter - invalidate a service
+		switch r.Method {
+		case http.MethodGet:
+			response, err := client.DescriptorCacheStats()
+			if err != nil {
+				s.returnError(w, &perrors.ProxyError{Code: perrors.Unknown, Message: err.Error()})
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write(response)
+		case http.MethodDelete:
+			if !s.writableActuator {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			client.InvalidateDescriptors(r.URL.Query()["service"]...)
+			s.logger.Info("descriptor cache invalidated",
+				zap.Strings("services", r.URL.Query()["service"]))
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
 	}
 }
 
@@ -68,11 +249,44 @@ func (s *Server) CatchAllHandler() http.HandlerFunc {
 	}
 }
 
+// RouteHandler handles requests mapped to gRPC methods by google.api.http options, that are not
+// handled by RPCCallHandler, as well as gRPC-Web calls and their CORS preflight requests. Requests
+// matching no route are handled by CatchAllHandler.
+func (s *Server) RouteHandler(client GrpcClient) http.HandlerFunc {
+	catchAll := s.CatchAllHandler()
+	grpcWeb := s.GRPCWebHandler(client)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if isGRPCWebRequest(r) {
+			grpcWeb(w, r)
+			return
+		}
+		if isGRPCWebPreflight(r) {
+			s.grpcWebPreflight(w, r)
+			return
+		}
+		m, pathMatched := s.lookupRoute(client, r)
+		if m != nil {
+			s.invokeRoute(w, r, client, m)
+			return
+		}
+		if pathMatched {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		catchAll(w, r)
+	}
+}
+
 // RPCCallHandler handles requests for making gRPC calls
 func (s *Server) RPCCallHandler(client GrpcClient) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			// TODO supports method directives in pb?
+		// routes from google.api.http options take precedence over the default route
+		if m, _ := s.lookupRoute(client, r); m != nil {
+			s.invokeRoute(w, r, client, m)
+			return
+		}
+		upgrade := websocket.IsWebSocketUpgrade(r)
+		if r.Method != http.MethodPost && !upgrade {
 			w.WriteHeader(http.StatusMethodNotAllowed)
 			return
 		}
@@ -99,34 +313,140 @@ func (s *Server) RPCCallHandler(client GrpcClient) http.HandlerFunc {
 			Service: service,
 			Method:  method,
 		}
-		ctx := grpc_metadata.NewOutgoingContext(r.Context(),
-			grpc_metadata.MD(metadata.MetadataFromHeaders(r.Header)))
 
-		md := make(metadata.Metadata)
+		if upgrade {
+			s.invokeWebSocket(w, r, client, c)
+			return
+		}
+
+		defer r.Body.Close()
+		s.invoke(w, r, client, c, r.Body, nil)
+	}
+}
+
+// lookupRoute finds the route of the request in the route table of the client. When no route
+// matches, it tells whether a route matches the path but with another HTTP method.
+func (s *Server) lookupRoute(client GrpcClient, r *http.Request) (*route.Match, bool) {
+	if !client.IsReady() {
+		return nil, false
+	}
+	table, err := client.Routes()
+	if err != nil {
+		s.logger.Error("error in building routes",
+			zap.String("err", err.Error()))
+		return nil, false
+	}
+	return table.Lookup(r.Method, r.URL.EscapedPath())
+}
 
-		inputMessage, err := ioutil.ReadAll(r.Body)
+func (s *Server) invokeRoute(w http.ResponseWriter, r *http.Request, client GrpcClient, m *route.Match) {
+	c := callee{
+		Service: m.Service,
+		Method:  m.Method,
+	}
+	var body io.Reader
+	if m.Body != "" {
 		defer r.Body.Close()
+		body = r.Body
+	}
+	s.invoke(w, r, client, c, body, m.Params(r.URL.Query()))
+}
+
+// invoke calls the method with the input message read from body, which is nil when the request
+// body is not part of the input message. The body is read as a stream of messages for client
+// streaming methods.
+func (s *Server) invoke(w http.ResponseWriter, r *http.Request, client GrpcClient, c callee,
+	body io.Reader, params *route.Params) {
+
+	mapper := s.metadataConfig.Mapper(c.Service, c.Method)
+	md, err := mapper.FromHeaders(r.Header)
+	if err != nil {
+		s.returnError(w, err)
+		s.logger.Error("error in handling call",
+			zap.String("err", err.Error()))
+		return
+	}
+	timeout, err := s.callTimeout(r, c)
+	if err != nil {
+		s.returnError(w, err)
+		s.logger.Error("error in handling call",
+			zap.String("err", err.Error()))
+		return
+	}
+	ctx, cancel := withTimeout(grpc_metadata.NewOutgoingContext(r.Context(), grpc_metadata.MD(md)), timeout)
+	defer cancel()
+
+	m, err := client.Method(c.Service, c.Method)
+	if err != nil {
+		s.returnError(w, err)
+		s.logger.Error("error in handling call",
+			zap.String("err", err.Error()))
+		return
+	}
+	if m.ClientStreaming && m.ServerStreaming {
+		// bidirectional streaming needs a full duplex connection, see invokeWebSocket
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+	if m.ClientStreaming {
+		s.invokeClientStream(ctx, w, client, c, mapper, body, params)
+		return
+	}
+	var inputMessage []byte
+	if body != nil {
+		b, err := ioutil.ReadAll(body)
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
-		response, err := client.Invoke(ctx, c.Service, c.Method, inputMessage, &md)
-		if err != nil {
-			returnError(w, errors.Cause(err).(perrors.Error))
-			s.logger.Error("error in handling call",
-				zap.String("err", err.Error()))
-			return
+		inputMessage = b
+	}
+	if m.ServerStreaming {
+		if acceptsEventStream(r) {
+			s.invokeEventStream(ctx, w, r, client, c, mapper, inputMessage, params)
+		} else {
+			s.invokeServerStream(ctx, w, client, c, mapper, inputMessage, params)
 		}
+		return
+	}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write(response)
+	var header, trailer metadata.Metadata
+	response, err := client.Invoke(ctx, c.Service, c.Method, inputMessage, params, &header, &trailer)
+	writeHeaderMetadata(w, mapper.ToHeaders(header))
+	s.writeTrailerMetadata(w, mapper, trailer, false)
+	if err != nil {
+		s.returnError(w, err)
+		s.logger.Error("error in handling call",
+			zap.String("err", err.Error()))
 		return
 	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(response)
 }
 
-func returnError(w http.ResponseWriter, err perrors.Error) {
-	w.WriteHeader(err.HTTPStatusCode())
+// returnError answers with the cause of the error, as a problem details document if so configured.
+// Errors returned by gRPC upstream are answered with the HTTP status code configured for their gRPC
+// status code, if any, and causes that are not an Error as unknown internal errors.
+func (s *Server) returnError(w http.ResponseWriter, cause error) {
+	err, ok := errors.Cause(cause).(perrors.Error)
+	if !ok {
+		err = &perrors.ProxyError{Code: perrors.Unknown, Message: cause.Error()}
+	}
+	status := err.HTTPStatusCode()
+	if e, ok := err.(*perrors.GRPCError); ok {
+		if st, ok := s.httpStatusCodes[codes.Code(e.StatusCode)]; ok {
+			status = st
+		}
+		writeRetryHeaders(w, e)
+	}
+	if s.problemJSON {
+		w.Header().Set("Content-Type", perrors.ProblemContentType)
+		w.WriteHeader(status)
+		perrors.NewProblem(err, status).WriteJSON(w)
+		return
+	}
+	w.WriteHeader(status)
 	err.WriteJSON(w)
-	return
 }
@@ -4,17 +4,54 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
+	perrors "github.com/gdong42/grpc-mate/errors"
 	"github.com/gdong42/grpc-mate/metadata"
+	"github.com/gdong42/grpc-mate/proxy"
+	"github.com/gdong42/grpc-mate/proxy/test"
+	"github.com/gdong42/grpc-mate/route"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	grpc_metadata "google.golang.org/grpc/metadata"
 )
 
 type mockClient struct {
 	isReady bool
+	routes  []*route.Route
+	// lastMessage and lastParams hold the input of the last invocation
+	lastMessage []byte
+	lastParams  *route.Params
+	// streams holds the messages streamed by server streaming methods, followed by streamErr
+	streams   map[string][]string
+	streamErr error
+	// streamDelay delays streamed messages, and lastMetadata holds the outgoing metadata of the
+	// last invocation
+	streamDelay  time.Duration
+	lastMetadata grpc_metadata.MD
+	// clientStreams holds the client streaming methods, and lastMessages holds the input of the
+	// last client streaming invocation
+	clientStreams map[string]bool
+	lastMessages  []string
+	// header and trailer are the metadata returned by unary and server streaming methods, and
+	// invokeErr the error returned by unary ones
+	header, trailer metadata.Metadata
+	invokeErr       error
+	// lastDeadline is the deadline of the last unary invocation, if any
+	lastDeadline time.Time
+	// invalidated holds the services of the last descriptor cache invalidation
+	invalidated []string
+	// describeErr is the error returned by the introspection and the OpenAPI document, if any
+	describeErr error
+	// canceled, if not nil, makes streams wait for their context to be done before closing it
+	canceled chan struct{}
 }
 
 func (c *mockClient) IsReady() bool {
@@ -25,15 +62,141 @@ func (c *mockClient) Invoke(ctx context.Context,
 	serviceName string,
 	methodName string,
 	message []byte,
-	md *metadata.Metadata,
+	params *route.Params,
+	header, trailer *metadata.Metadata,
 ) ([]byte, error) {
+	c.lastMessage = message
+	c.lastParams = params
+	c.lastMetadata, _ = grpc_metadata.FromOutgoingContext(ctx)
+	c.lastDeadline, _ = ctx.Deadline()
+	*header, *trailer = c.header, c.trailer
+	if c.invokeErr != nil {
+		return nil, c.invokeErr
+	}
 	response := fmt.Sprintf(`{"service":"%s","method":"%s"}`,
 		serviceName,
 		methodName)
 	return []byte(response), nil
 }
 
+func (c *mockClient) InvokeServerStream(ctx context.Context,
+	serviceName string,
+	methodName string,
+	message []byte,
+	params *route.Params,
+	header, trailer *metadata.Metadata,
+	onMessage func([]byte) error,
+) error {
+	c.lastMessage = message
+	c.lastParams = params
+	c.lastMetadata, _ = grpc_metadata.FromOutgoingContext(ctx)
+	*header, *trailer = c.header, c.trailer
+	for _, m := range c.streams[methodName] {
+		time.Sleep(c.streamDelay)
+		if err := onMessage([]byte(m)); err != nil {
+			return err
+		}
+	}
+	return c.streamErr
+}
+
+func (c *mockClient) InvokeClientStream(ctx context.Context,
+	serviceName string,
+	methodName string,
+	next func() ([]byte, error),
+	params *route.Params,
+	header, trailer *metadata.Metadata,
+) ([]byte, error) {
+	c.lastMessages = []string{}
+	for {
+		m, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		c.lastMessages = append(c.lastMessages, string(m))
+	}
+	response := fmt.Sprintf(`{"service":"%s","method":"%s","count":%d}`,
+		serviceName,
+		methodName,
+		len(c.lastMessages))
+	return []byte(response), nil
+}
+
+func (c *mockClient) InvokeStream(ctx context.Context,
+	serviceName string,
+	methodName string,
+	next func() ([]byte, error),
+	params *route.Params,
+	header, trailer *metadata.Metadata,
+	onMessage func([]byte) error,
+) error {
+	c.lastMetadata, _ = grpc_metadata.FromOutgoingContext(ctx)
+	// messages are echoed back
+	for {
+		m, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := onMessage(m); err != nil {
+			return err
+		}
+		// like the proxy, only the first message is read for methods without client streaming
+		if !c.clientStreams[methodName] {
+			break
+		}
+	}
+	if c.canceled != nil {
+		<-ctx.Done()
+		close(c.canceled)
+	}
+	return c.streamErr
+}
+
+func (c *mockClient) InvokeRaw(ctx context.Context,
+	fullMethod string,
+	next func() ([]byte, error),
+	header, trailer *metadata.Metadata,
+	onMessage func([]byte) error,
+) error {
+	c.lastMetadata, _ = grpc_metadata.FromOutgoingContext(ctx)
+	*header = metadata.Metadata{"x-header": {"header"}}
+	*trailer = metadata.Metadata{"x-trailer": {"trailer"}}
+	// messages are echoed back
+	for {
+		m, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := onMessage(m); err != nil {
+			return err
+		}
+	}
+	return c.streamErr
+}
+
+func (c *mockClient) Method(serviceName, methodName string) (*route.Method, error) {
+	_, streaming := c.streams[methodName]
+	return &route.Method{
+		Service:         serviceName,
+		Name:            methodName,
+		ClientStreaming: c.clientStreams[methodName],
+		ServerStreaming: streaming,
+	}, nil
+}
+
 func (c *mockClient) Introspect() ([]byte, error) {
+	if c.describeErr != nil {
+		return nil, c.describeErr
+	}
 	response := `{"services":[{
 		"name": "helloworld.Greeter",
 		"methods": []
@@ -41,6 +204,65 @@ func (c *mockClient) Introspect() ([]byte, error) {
 	return []byte(response), nil
 }
 
+func (c *mockClient) IntrospectService(serviceName string) ([]byte, error) {
+	if serviceName != "helloworld.Greeter" {
+		return nil, &perrors.ProxyError{Code: perrors.ServiceUnresolvable}
+	}
+	return []byte(`{"services":[{"name":"helloworld.Greeter","methods":[]}],"types":[],"enums":[]}`), nil
+}
+
+func (c *mockClient) IntrospectType(typeName string) ([]byte, error) {
+	if typeName != "helloworld.HelloRequest" {
+		return nil, &perrors.ProxyError{Code: perrors.TypeNotFound}
+	}
+	return []byte(`{"name":"helloworld.HelloRequest","fields":[]}`), nil
+}
+
+func (c *mockClient) DescriptorHash() (string, error) {
+	return "0123abcd", nil
+}
+
+func (c *mockClient) OpenAPI() ([]byte, error) {
+	if c.describeErr != nil {
+		return nil, c.describeErr
+	}
+	return []byte(`{"openapi":"3.0.3","info":{"title":"grpc-mate","version":"latest"},"paths":{}}`), nil
+}
+
+func (c *mockClient) JSONSchema(typeName string) ([]byte, error) {
+	if typeName != "helloworld.HelloRequest" {
+		return nil, &perrors.ProxyError{Code: perrors.TypeNotFound}
+	}
+	return []byte(`{"$schema":"https://json-schema.org/draft/2020-12/schema"}`), nil
+}
+
+func (c *mockClient) Routes() (*route.Table, error) {
+	return route.NewTable(c.routes), nil
+}
+
+func (c *mockClient) InvalidateDescriptors(serviceNames ...string) {
+	c.invalidated = serviceNames
+}
+
+func (c *mockClient) DescriptorCacheStats() ([]byte, error) {
+	return []byte(`{"hits":2,"misses":1,"services":1}`), nil
+}
+
+func newMockRoute(t *testing.T, httpMethod, path, method, body string) *route.Route {
+	t.Helper()
+	tmpl, err := route.ParseTemplate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &route.Route{
+		HTTPMethod: httpMethod,
+		Template:   tmpl,
+		Service:    "svc1",
+		Method:     method,
+		Body:       body,
+	}
+}
+
 func TestHealthCheckHandler(t *testing.T) {
 	mc := &mockClient{}
 	server := New(mc, zap.NewNop())
@@ -108,6 +330,330 @@ func TestIntrospectHandler(t *testing.T) {
 	}
 }
 
+func TestIntrospectionETag(t *testing.T) {
+	cases := []struct {
+		name        string
+		path        string
+		ifNoneMatch string
+		status      int
+	}{
+		{
+			name:   "all services",
+			path:   "/actuator/services",
+			status: http.StatusOK,
+		},
+		{
+			name:        "all services not modified",
+			path:        "/actuator/services",
+			ifNoneMatch: `"0123abcd"`,
+			status:      http.StatusNotModified,
+		},
+		{
+			name:        "all services modified",
+			path:        "/actuator/services",
+			ifNoneMatch: `"4567"`,
+			status:      http.StatusOK,
+		},
+		{
+			name:        "weak tag among others",
+			path:        "/actuator/services",
+			ifNoneMatch: `"4567", W/"0123abcd"`,
+			status:      http.StatusNotModified,
+		},
+		{
+			name:   "service",
+			path:   "/actuator/services/helloworld.Greeter",
+			status: http.StatusOK,
+		},
+		{
+			name:        "service not modified",
+			path:        "/actuator/services/helloworld.Greeter",
+			ifNoneMatch: "*",
+			status:      http.StatusNotModified,
+		},
+		{
+			name:   "unknown service",
+			path:   "/actuator/services/helloworld.Unknown",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "type",
+			path:   "/actuator/types/helloworld.HelloRequest",
+			status: http.StatusOK,
+		},
+		{
+			name:   "unknown type",
+			path:   "/actuator/types/helloworld.Unknown",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "no type",
+			path:   "/actuator/types/",
+			status: http.StatusNotFound,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mc := &mockClient{
+				isReady: true,
+			}
+			server := New(mc, zap.NewNop())
+			req, err := http.NewRequest("GET", tc.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tc.ifNoneMatch)
+			}
+			rr := httptest.NewRecorder()
+			server.router.ServeHTTP(rr, req)
+
+			if got, want := rr.Code, tc.status; got != want {
+				t.Fatalf("got status %d, want %d", got, want)
+			}
+			wantETag := `"0123abcd"`
+			switch tc.status {
+			case http.StatusNotModified:
+				if rr.Body.Len() != 0 {
+					t.Errorf("got body %s, want none", rr.Body.String())
+				}
+			case http.StatusOK:
+				if got, want := rr.Header().Get("Content-Type"), "application/json"; got != want {
+					t.Errorf("got content type %s, want %s", got, want)
+				}
+			default:
+				wantETag = ""
+			}
+			if got, want := rr.Header().Get("ETag"), wantETag; got != want {
+				t.Errorf("got ETag %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	cases := []struct {
+		name        string
+		yaml        bool
+		contentType string
+		body        string
+	}{
+		{
+			name:        "json",
+			contentType: "application/json",
+			body:        `{"openapi":"3.0.3","info":{"title":"grpc-mate","version":"latest"},"paths":{}}`,
+		},
+		{
+			name:        "yaml",
+			yaml:        true,
+			contentType: "application/yaml",
+			body:        "openapi: 3.0.3\ninfo:\n  title: grpc-mate\n  version: latest\npaths: {}\n",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mc := &mockClient{
+				isReady: true,
+			}
+			server := New(mc, zap.NewNop())
+			req, err := http.NewRequest("GET", "/actuator/openapi", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rr := httptest.NewRecorder()
+			server.OpenAPIHandler(mc, tc.yaml).ServeHTTP(rr, req)
+
+			if got, want := rr.Code, http.StatusOK; got != want {
+				t.Errorf("got status %d, want %d", got, want)
+			}
+			if got, want := rr.Header().Get("Content-Type"), tc.contentType; got != want {
+				t.Errorf("got content type %s, want %s", got, want)
+			}
+			if got, want := rr.Body.String(), tc.body; got != want {
+				t.Errorf("got body %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestDescriptionHandlersInternalErrors(t *testing.T) {
+	mc := &mockClient{
+		isReady:     true,
+		describeErr: fmt.Errorf("failed to build routes: %v", "invalid path template"),
+	}
+	server := New(mc, zap.NewNop())
+	handlers := map[string]http.HandlerFunc{
+		"introspection": server.IntrospectHandler(mc),
+		"openapi":       server.OpenAPIHandler(mc, false),
+	}
+	for name, h := range handlers {
+		t.Run(name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/actuator/"+name, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+
+			if got, want := rr.Code, http.StatusInternalServerError; got != want {
+				t.Errorf("got status %d, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestJSONSchemaHandler(t *testing.T) {
+	cases := []struct {
+		name   string
+		path   string
+		status int
+	}{
+		{
+			name:   "found",
+			path:   "/actuator/schemas/helloworld.HelloRequest",
+			status: http.StatusOK,
+		},
+		{
+			name:   "not found",
+			path:   "/actuator/schemas/helloworld.Unknown",
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "no type",
+			path:   "/actuator/schemas/",
+			status: http.StatusNotFound,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mc := &mockClient{
+				isReady: true,
+			}
+			server := New(mc, zap.NewNop())
+			req, err := http.NewRequest("GET", tc.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rr := httptest.NewRecorder()
+			server.JSONSchemaHandler(mc).ServeHTTP(rr, req)
+
+			if got, want := rr.Code, tc.status; got != want {
+				t.Fatalf("got status %d, want %d", got, want)
+			}
+			if tc.status != http.StatusOK {
+				return
+			}
+			if got, want := rr.Header().Get("Content-Type"), "application/schema+json"; got != want {
+				t.Errorf("got content type %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestConsoleHandler(t *testing.T) {
+	mc := &mockClient{}
+	server := New(mc, zap.NewNop())
+	req, err := http.NewRequest("GET", "/actuator/console", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	server.ConsoleHandler().ServeHTTP(rr, req)
+
+	if got, want := rr.Code, http.StatusOK; got != want {
+		t.Fatalf("got status %d, want %d", got, want)
+	}
+	if got, want := rr.Header().Get("Content-Type"), "text/html; charset=utf-8"; got != want {
+		t.Errorf("got content type %s, want %s", got, want)
+	}
+	body := rr.Body.String()
+	if !strings.Contains(body, "<html") {
+		t.Errorf("got body %s, want an HTML page", body)
+	}
+	// the console is self-contained, e.g. it works without internet access
+	for _, s := range []string{"http://", "https://", "src="} {
+		if strings.Contains(body, s) {
+			t.Errorf("got %q in the console, want no external resources", s)
+		}
+	}
+
+	req, err = http.NewRequest("POST", "/actuator/console", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr = httptest.NewRecorder()
+	server.ConsoleHandler().ServeHTTP(rr, req)
+	if got, want := rr.Code, http.StatusMethodNotAllowed; got != want {
+		t.Errorf("got status %d, want %d", got, want)
+	}
+}
+
+func TestDescriptorCacheHandler(t *testing.T) {
+	cases := []struct {
+		name        string
+		method      string
+		path        string
+		status      int
+		body        string
+		writable    bool
+		invalidated []string
+	}{
+		{
+			name:   "stats",
+			method: "GET",
+			path:   "/actuator/descriptors",
+			status: http.StatusOK,
+			body:   `{"hits":2,"misses":1,"services":1}`,
+		},
+		{
+			name:        "invalidate services",
+			method:      "DELETE",
+			path:        "/actuator/descriptors?service=svc1&service=svc2",
+			writable:    true,
+			status:      http.StatusNoContent,
+			invalidated: []string{"svc1", "svc2"},
+		},
+		{
+			name:   "read-only actuator",
+			method: "DELETE",
+			path:   "/actuator/descriptors",
+			status: http.StatusMethodNotAllowed,
+		},
+		{
+			name:   "method not allowed",
+			method: "POST",
+			path:   "/actuator/descriptors",
+			status: http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mc := &mockClient{isReady: true}
+			var opts []Option
+			if tc.writable {
+				opts = append(opts, WithWritableActuator())
+			}
+			server := New(mc, zap.NewNop(), opts...)
+			req, err := http.NewRequest(tc.method, tc.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rr := httptest.NewRecorder()
+			server.DescriptorCacheHandler(mc).ServeHTTP(rr, req)
+
+			if got, want := rr.Code, tc.status; got != want {
+				t.Fatalf("got status %v, want %v", got, want)
+			}
+			if got, want := rr.Body.String(), tc.body; got != want {
+				t.Fatalf("got body %s, want %s", got, want)
+			}
+			if got, want := mc.invalidated, tc.invalidated; !reflect.DeepEqual(got, want) {
+				t.Fatalf("got invalidated %v, want %v", got, want)
+			}
+		})
+	}
+}
+
 func TestCatchAllHandler(t *testing.T) {
 	mc := &mockClient{}
 	server := New(mc, zap.NewNop())
@@ -211,3 +757,217 @@ func TestRPCCallHandlerSuccess(t *testing.T) {
 		t.Errorf("handler did not returns expected value [method1] for body key: method, got %v", actualMethod)
 	}
 }
+
+func TestRPCCallHandlerDispatchesRoutes(t *testing.T) {
+	mc := &mockClient{
+		isReady: true,
+	}
+	mc.routes = []*route.Route{
+		newMockRoute(t, http.MethodGet, "/v1/users/{user_id}", "GetUser", ""),
+		newMockRoute(t, http.MethodPatch, "/v1/users/{user.id}", "UpdateUser", "user"),
+		newMockRoute(t, http.MethodPost, "/users:batchGet", "BatchGetUsers", "*"),
+	}
+	server := New(mc, zap.NewNop())
+
+	cases := []struct {
+		name       string
+		httpMethod string
+		path       string
+		body       string
+		status     int
+		method     string
+		message    string
+		params     *route.Params
+	}{
+		{
+			name:       "get without body",
+			httpMethod: http.MethodGet,
+			path:       "/v1/users/42?view=FULL",
+			body:       `{"ignored":true}`,
+			status:     http.StatusOK,
+			method:     "GetUser",
+			message:    "",
+			params: &route.Params{
+				PathParams:  map[string]string{"user_id": "42"},
+				QueryParams: url.Values{"view": {"FULL"}},
+			},
+		},
+		{
+			name:       "body mapped to field",
+			httpMethod: http.MethodPatch,
+			path:       "/v1/users/42",
+			body:       `{"name":"gdong42"}`,
+			status:     http.StatusOK,
+			method:     "UpdateUser",
+			message:    `{"name":"gdong42"}`,
+			params: &route.Params{
+				BodyField:   "user",
+				PathParams:  map[string]string{"user.id": "42"},
+				QueryParams: url.Values{},
+			},
+		},
+		{
+			name:       "route outside of /v1/",
+			httpMethod: http.MethodPost,
+			path:       "/users:batchGet",
+			body:       `{"ids":["42"]}`,
+			status:     http.StatusOK,
+			method:     "BatchGetUsers",
+			message:    `{"ids":["42"]}`,
+			params: &route.Params{
+				BodyField:   "*",
+				PathParams:  map[string]string{},
+				QueryParams: url.Values{},
+			},
+		},
+		{
+			name:       "method not allowed",
+			httpMethod: http.MethodPut,
+			path:       "/v1/users/42",
+			status:     http.StatusMethodNotAllowed,
+		},
+		{
+			name:       "method not allowed outside of /v1/",
+			httpMethod: http.MethodGet,
+			path:       "/users:batchGet",
+			status:     http.StatusMethodNotAllowed,
+		},
+		{
+			name:       "default route still works",
+			httpMethod: http.MethodPost,
+			path:       "/v1/svc1/CreateUser",
+			body:       `{"name":"gdong42"}`,
+			status:     http.StatusOK,
+			method:     "CreateUser",
+			message:    `{"name":"gdong42"}`,
+		},
+		{
+			name:       "not found",
+			httpMethod: http.MethodGet,
+			path:       "/users",
+			status:     http.StatusNotFound,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mc.lastMessage = nil
+			req, err := http.NewRequest(tc.httpMethod, tc.path, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rr := httptest.NewRecorder()
+			server.router.ServeHTTP(rr, req)
+
+			if got, want := rr.Code, tc.status; got != want {
+				t.Fatalf("handler returned wrong status code: got %v want %v", got, want)
+			}
+			if tc.status != http.StatusOK {
+				return
+			}
+			var actual map[string]string
+			if err := json.Unmarshal(rr.Body.Bytes(), &actual); err != nil {
+				t.Fatalf("Invalid JSON in response: %s, err: %v", rr.Body.String(), err)
+			}
+			if got, want := actual["method"], tc.method; got != want {
+				t.Fatalf("got method %s, want %s", got, want)
+			}
+			if got, want := string(mc.lastMessage), tc.message; got != want {
+				t.Fatalf("got message %s, want %s", got, want)
+			}
+			if got, want := mc.lastParams, tc.params; !reflect.DeepEqual(got, want) {
+				t.Fatalf("got params %#v, want %#v", got, want)
+			}
+		})
+	}
+}
+
+func TestRPCCallHandlerErrors(t *testing.T) {
+	cases := []struct {
+		name        string
+		opts        []Option
+		status      int
+		contentType string
+		body        string
+	}{
+		{
+			name:   "default",
+			status: http.StatusServiceUnavailable,
+			body:   `{"code":8,"message":"quota exceeded"}` + "\n",
+		},
+		{
+			name:        "problem JSON with status override",
+			opts:        []Option{WithProblemJSON(), WithHTTPStatusCodes(map[codes.Code]int{codes.ResourceExhausted: http.StatusTooManyRequests})},
+			status:      http.StatusTooManyRequests,
+			contentType: "application/problem+json",
+			body: `{"type":"about:blank","title":"Too Many Requests","status":429,"detail":"quota exceeded","grpc_code":8}` +
+				"\n",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mc := &mockClient{
+				isReady: true,
+				invokeErr: &perrors.GRPCError{
+					StatusCode: int(codes.ResourceExhausted),
+					Message:    "quota exceeded",
+				},
+			}
+			server := New(mc, zap.NewNop(), tc.opts...)
+			req, err := http.NewRequest("POST", "/v1/svc1/method1", strings.NewReader(`{}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rr := httptest.NewRecorder()
+			server.RPCCallHandler(mc).ServeHTTP(rr, req)
+
+			if got, want := rr.Code, tc.status; got != want {
+				t.Errorf("got status %v, want %v", got, want)
+			}
+			if got, want := rr.Header().Get("Content-Type"), tc.contentType; got != want {
+				t.Errorf("got content type %q, want %q", got, want)
+			}
+			if got, want := rr.Body.String(), tc.body; got != want {
+				t.Errorf("got body %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestRPCCallHandlerUpstreamStatusOverrides(t *testing.T) {
+	cc, stop := test.StartTestServer(t)
+	defer stop()
+	p := proxy.NewProxy(cc)
+	server := New(p, zap.NewNop(), WithHTTPStatusCodes(map[codes.Code]int{
+		codes.Unavailable:       http.StatusBadGateway,
+		codes.ResourceExhausted: http.StatusTooManyRequests,
+	}))
+
+	cases := []struct {
+		code   codes.Code
+		status int
+	}{
+		{
+			code:   codes.Unavailable,
+			status: http.StatusBadGateway,
+		},
+		{
+			code:   codes.ResourceExhausted,
+			status: http.StatusTooManyRequests,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.code.String(), func(t *testing.T) {
+			req, err := http.NewRequest("POST", "/v1/"+test.TestService+"/"+test.UnaryCall,
+				strings.NewReader(fmt.Sprintf(`{"responseSize":%d}`, tc.code)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rr := httptest.NewRecorder()
+			server.RPCCallHandler(p).ServeHTTP(rr, req)
+
+			if got, want := rr.Code, tc.status; got != want {
+				t.Fatalf("got status %v, want %v: %s", got, want, rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gdong42/grpc-mate/metadata"
+)
+
+// writeHeaderMetadata adds the headers header metadata is mapped to as response headers. It must
+// be called before the response starts.
+func writeHeaderMetadata(w http.ResponseWriter, headers map[string][]string) {
+	for k, v := range headers {
+		for _, vv := range v {
+			w.Header().Add(k, vv)
+		}
+	}
+}
+
+// writeTrailerMetadata sets the trailer metadata of a call as HTTP trailers, or as response headers
+// named with the trailer header prefix, if any, when the response has not started.
+func (s *Server) writeTrailerMetadata(w http.ResponseWriter, mapper *metadata.Mapper, trailer metadata.Metadata,
+	started bool) {
+
+	if s.trailerHeaderPrefix != "" && !started {
+		for k, v := range mapper.Filter(trailer) {
+			for _, vv := range v {
+				w.Header().Add(s.trailerHeaderPrefix+k, vv)
+			}
+		}
+		return
+	}
+	for k, v := range mapper.ToHeaders(trailer) {
+		w.Header()[http.TrailerPrefix+http.CanonicalHeaderKey(k)] = v
+	}
+}
@@ -0,0 +1,195 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	perrors "github.com/gdong42/grpc-mate/errors"
+	"github.com/gdong42/grpc-mate/metadata"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	grpc_metadata "google.golang.org/grpc/metadata"
+)
+
+func TestRPCCallHandlerMetadata(t *testing.T) {
+	cases := []struct {
+		name      string
+		method    string
+		err       error
+		opts      []Option
+		status    int
+		headers   map[string]string
+		trailers  map[string]string
+		notHeader string
+	}{
+		{
+			name:     "unary",
+			method:   "method1",
+			status:   http.StatusOK,
+			headers:  map[string]string{"Grpc-Metadata-X-Header": "header"},
+			trailers: map[string]string{"Grpc-Metadata-X-Trailer": "trailer"},
+		},
+		{
+			name:   "unary error",
+			method: "method1",
+			err: &perrors.GRPCError{
+				StatusCode: int(codes.NotFound),
+				Message:    "not found",
+			},
+			status:   http.StatusNotFound,
+			headers:  map[string]string{"Grpc-Metadata-X-Header": "header"},
+			trailers: map[string]string{"Grpc-Metadata-X-Trailer": "trailer"},
+		},
+		{
+			name:   "unary with trailer header prefix",
+			method: "method1",
+			opts:   []Option{WithTrailerHeaderPrefix("Grpc-Trailer-")},
+			status: http.StatusOK,
+			headers: map[string]string{
+				"Grpc-Metadata-X-Header": "header",
+				"Grpc-Trailer-X-Trailer": "trailer",
+			},
+			trailers:  map[string]string{},
+			notHeader: "Grpc-Metadata-X-Trailer",
+		},
+		{
+			name:     "server streaming",
+			method:   "stream1",
+			opts:     []Option{WithTrailerHeaderPrefix("Grpc-Trailer-")},
+			status:   http.StatusOK,
+			headers:  map[string]string{"Grpc-Metadata-X-Header": "header"},
+			trailers: map[string]string{"Grpc-Metadata-X-Trailer": "trailer", "Grpc-Status": "0"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mc := &mockClient{
+				isReady:   true,
+				streams:   map[string][]string{"stream1": {`{"id":1}`}},
+				header:    metadata.Metadata{"x-header": {"header"}},
+				trailer:   metadata.Metadata{"x-trailer": {"trailer"}},
+				invokeErr: tc.err,
+			}
+			server := New(mc, zap.NewNop(), tc.opts...)
+			req, err := http.NewRequest("POST", "/v1/svc1/"+tc.method, strings.NewReader(`{}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rr := httptest.NewRecorder()
+			server.RPCCallHandler(mc).ServeHTTP(rr, req)
+			res := rr.Result()
+
+			if got, want := res.StatusCode, tc.status; got != want {
+				t.Errorf("got status %v, want %v", got, want)
+			}
+			for k, want := range tc.headers {
+				if got := res.Header.Get(k); got != want {
+					t.Errorf("got header %s %q, want %q", k, got, want)
+				}
+			}
+			if tc.notHeader != "" {
+				if got := res.Header.Get(tc.notHeader); got != "" {
+					t.Errorf("got unexpected header %s %q", tc.notHeader, got)
+				}
+			}
+			for k, want := range tc.trailers {
+				if got := res.Trailer.Get(k); got != want {
+					t.Errorf("got trailer %s %q, want %q", k, got, want)
+				}
+			}
+			if tc.trailers != nil && len(tc.trailers) == 0 && len(res.Trailer) != 0 {
+				t.Errorf("got unexpected trailers %v", res.Trailer)
+			}
+		})
+	}
+}
+
+func TestRPCCallHandlerMetadataConfig(t *testing.T) {
+	mc := &mockClient{
+		isReady: true,
+		header:  metadata.Metadata{"request-id": {"1"}, "x-internal": {"secret"}},
+	}
+	config := &metadata.Config{
+		Rules: metadata.Rules{
+			Allow:  []string{"Authorization"},
+			Rename: map[string]string{"X-Request-Id": "request-id"},
+		},
+		Routes: map[string]*metadata.Rules{
+			"svc1/method1": {Deny: []string{"X-Internal"}},
+		},
+	}
+	server := New(mc, zap.NewNop(), WithMetadataConfig(config))
+	req, err := http.NewRequest("POST", "/v1/svc1/method1", strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("X-Request-Id", "1")
+	req.Header.Set("User-Agent", "test")
+	rr := httptest.NewRecorder()
+	server.RPCCallHandler(mc).ServeHTTP(rr, req)
+
+	want := grpc_metadata.MD{"authorization": {"Bearer token"}, "request-id": {"1"}}
+	if got := mc.lastMetadata; !reflect.DeepEqual(got, want) {
+		t.Errorf("got metadata %v, want %v", got, want)
+	}
+	if got, want := rr.Header().Get("X-Request-Id"), "1"; got != want {
+		t.Errorf("got header X-Request-Id %q, want %q", got, want)
+	}
+	if got := rr.Header().Get("Grpc-Metadata-X-Internal"); got != "" {
+		t.Errorf("got unexpected header Grpc-Metadata-X-Internal %q", got)
+	}
+}
+
+func TestRPCCallHandlerBinaryMetadata(t *testing.T) {
+	cases := []struct {
+		name   string
+		value  string
+		status int
+		want   grpc_metadata.MD
+	}{
+		{
+			name:   "valid",
+			value:  "AAH/+A==",
+			status: http.StatusOK,
+			want:   grpc_metadata.MD{"token-bin": {"\x00\x01\xff\xf8"}},
+		},
+		{
+			name:   "invalid",
+			value:  "not base64!",
+			status: http.StatusBadRequest,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mc := &mockClient{
+				isReady: true,
+				header:  metadata.Metadata{"token-bin": {"\x00\x01\xff\xf8"}},
+			}
+			server := New(mc, zap.NewNop())
+			req, err := http.NewRequest("POST", "/v1/svc1/method1", strings.NewReader(`{}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Grpc-Metadata-Token-Bin", tc.value)
+			rr := httptest.NewRecorder()
+			server.RPCCallHandler(mc).ServeHTTP(rr, req)
+
+			if got, want := rr.Code, tc.status; got != want {
+				t.Fatalf("got status %v, want %v", got, want)
+			}
+			if tc.want == nil {
+				return
+			}
+			if got, want := mc.lastMetadata, tc.want; !reflect.DeepEqual(got, want) {
+				t.Errorf("got metadata %q, want %q", got, want)
+			}
+			if got, want := rr.Header().Get("Grpc-Metadata-Token-Bin"), "AAH/+A=="; got != want {
+				t.Errorf("got header %q, want %q", got, want)
+			}
+		})
+	}
+}
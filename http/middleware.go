@@ -1,6 +1,9 @@
 package http
 
 import (
+	"bufio"
+	"errors"
+	"net"
 	"net/http"
 
 	"go.uber.org/zap"
@@ -45,3 +48,22 @@ func (w *responseWriterDelegator) WriteHeader(code int) {
 	w.status = code
 	w.ResponseWriter.WriteHeader(code)
 }
+
+// Flush sends buffered data to the client, if the underlying ResponseWriter supports it, for
+// streamed responses
+func (w *responseWriterDelegator) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack lets the handler take over the connection, if the underlying ResponseWriter supports it,
+// for WebSocket connections
+func (w *responseWriterDelegator) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("the ResponseWriter does not support hijacking")
+	}
+	w.status = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
@@ -0,0 +1,52 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	perrors "github.com/gdong42/grpc-mate/errors"
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+)
+
+const (
+	retryAfterHeader         = "Retry-After"
+	rateLimitRemainingHeader = "RateLimit-Remaining"
+	rateLimitResetHeader     = "RateLimit-Reset"
+)
+
+// writeRetryHeaders tells clients when to retry a call that failed with RetryInfo or QuotaFailure
+// details: the retry delay as Retry-After, and quota failures as an exhausted rate limit that resets
+// after the retry delay, if any. It must be called before the response starts.
+func writeRetryHeaders(w http.ResponseWriter, e *perrors.GRPCError) {
+	var (
+		delay        time.Duration
+		hasDelay     bool
+		quotaFailure bool
+	)
+	for _, d := range e.Details {
+		switch {
+		case ptypes.Is(d, &errdetails.RetryInfo{}):
+			var info errdetails.RetryInfo
+			if err := ptypes.UnmarshalAny(d, &info); err != nil || info.RetryDelay == nil {
+				continue
+			}
+			if t, err := ptypes.Duration(info.RetryDelay); err == nil && t >= 0 {
+				delay, hasDelay = t, true
+			}
+		case ptypes.Is(d, &errdetails.QuotaFailure{}):
+			quotaFailure = true
+		}
+	}
+	seconds := strconv.FormatInt(int64((delay+time.Second-1)/time.Second), 10)
+	if hasDelay {
+		w.Header().Set(retryAfterHeader, seconds)
+	}
+	if quotaFailure {
+		w.Header().Set(rateLimitRemainingHeader, "0")
+		if hasDelay {
+			w.Header().Set(rateLimitResetHeader, seconds)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	perrors "github.com/gdong42/grpc-mate/errors"
+	"github.com/gdong42/grpc-mate/proxy"
+	"github.com/gdong42/grpc-mate/proxy/test"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	any "github.com/golang/protobuf/ptypes/any"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+)
+
+func TestWriteRetryHeaders(t *testing.T) {
+	retryInfo := &errdetails.RetryInfo{RetryDelay: ptypes.DurationProto(1500 * time.Millisecond)}
+	quotaFailure := &errdetails.QuotaFailure{
+		Violations: []*errdetails.QuotaFailure_Violation{{Subject: "project:1", Description: "daily limit"}},
+	}
+	cases := []struct {
+		name    string
+		details []proto.Message
+		headers http.Header
+	}{
+		{
+			name:    "no details",
+			headers: http.Header{},
+		},
+		{
+			name:    "retry info",
+			details: []proto.Message{retryInfo},
+			headers: http.Header{"Retry-After": {"2"}},
+		},
+		{
+			name:    "quota failure",
+			details: []proto.Message{quotaFailure},
+			headers: http.Header{"Ratelimit-Remaining": {"0"}},
+		},
+		{
+			name:    "retry info and quota failure",
+			details: []proto.Message{quotaFailure, retryInfo},
+			headers: http.Header{
+				"Retry-After":         {"2"},
+				"Ratelimit-Remaining": {"0"},
+				"Ratelimit-Reset":     {"2"},
+			},
+		},
+		{
+			name:    "other details",
+			details: []proto.Message{&errdetails.LocalizedMessage{Locale: "en-US", Message: "Hello"}},
+			headers: http.Header{},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var details []*any.Any
+			for _, d := range tc.details {
+				a, err := ptypes.MarshalAny(d)
+				if err != nil {
+					t.Fatal(err)
+				}
+				details = append(details, a)
+			}
+			rr := httptest.NewRecorder()
+			writeRetryHeaders(rr, &perrors.GRPCError{
+				StatusCode: int(codes.ResourceExhausted),
+				Message:    "quota exceeded",
+				Details:    details,
+			})
+			if got, want := rr.Header(), tc.headers; !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestRPCCallHandlerRetryHeaders(t *testing.T) {
+	cc, stop := test.StartTestServer(t)
+	defer stop()
+	p := proxy.NewProxy(cc)
+	server := New(p, zap.NewNop())
+
+	cases := []struct {
+		code    codes.Code
+		status  int
+		headers map[string]string
+	}{
+		{
+			code:    codes.Unavailable,
+			status:  http.StatusServiceUnavailable,
+			headers: map[string]string{"Retry-After": "2"},
+		},
+		{
+			code:   codes.ResourceExhausted,
+			status: http.StatusServiceUnavailable,
+			headers: map[string]string{
+				"Retry-After":         "2",
+				"Ratelimit-Remaining": "0",
+				"Ratelimit-Reset":     "2",
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.code.String(), func(t *testing.T) {
+			req, err := http.NewRequest("POST", "/v1/"+test.TestService+"/"+test.UnaryCall,
+				strings.NewReader(fmt.Sprintf(`{"responseSize":%d}`, tc.code)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rr := httptest.NewRecorder()
+			server.RPCCallHandler(p).ServeHTTP(rr, req)
+
+			if got, want := rr.Code, tc.status; got != want {
+				t.Fatalf("got status %v, want %v: %s", got, want, rr.Body.String())
+			}
+			for k, v := range tc.headers {
+				if got, want := rr.Header().Get(k), v; got != want {
+					t.Errorf("got %s %q, want %q", k, got, want)
+				}
+			}
+		})
+	}
+}
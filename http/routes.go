@@ -3,6 +3,13 @@ package http
 func (s *Server) registerHandlers(grpcClient GrpcClient) {
 	s.router.HandleFunc("/actuator/health", s.HealthCheckHandler())
 	s.router.HandleFunc("/actuator/services", s.IntrospectHandler(grpcClient))
+	s.router.HandleFunc("/actuator/services/", s.IntrospectServiceHandler(grpcClient))
+	s.router.HandleFunc("/actuator/types/", s.IntrospectTypeHandler(grpcClient))
+	s.router.HandleFunc("/actuator/openapi.json", s.OpenAPIHandler(grpcClient, false))
+	s.router.HandleFunc("/actuator/openapi.yaml", s.OpenAPIHandler(grpcClient, true))
+	s.router.HandleFunc("/actuator/schemas/", s.JSONSchemaHandler(grpcClient))
+	s.router.HandleFunc("/actuator/descriptors", s.DescriptorCacheHandler(grpcClient))
+	s.router.HandleFunc("/actuator/console", s.ConsoleHandler())
 	s.router.HandleFunc("/v1/", apply(s.RPCCallHandler(grpcClient), []Adapter{s.withLog}...))
-	s.router.HandleFunc("/", apply(s.CatchAllHandler(), []Adapter{s.withLog}...))
+	s.router.HandleFunc("/", apply(s.RouteHandler(grpcClient), []Adapter{s.withLog}...))
 }
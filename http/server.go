@@ -4,9 +4,12 @@ import (
 	"context"
 	"net"
 	"net/http"
+	"time"
 
 	"github.com/gdong42/grpc-mate/metadata"
+	"github.com/gdong42/grpc-mate/route"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
 )
 
 // GrpcClient is a dynamic gRPC client that performs reflection
@@ -16,9 +19,48 @@ type GrpcClient interface {
 		serviceName string,
 		methodName string,
 		message []byte,
-		md *metadata.Metadata,
+		params *route.Params,
+		header, trailer *metadata.Metadata,
 	) (response []byte, err error)
+	InvokeServerStream(ctx context.Context,
+		serviceName string,
+		methodName string,
+		message []byte,
+		params *route.Params,
+		header, trailer *metadata.Metadata,
+		onMessage func(response []byte) error,
+	) error
+	InvokeClientStream(ctx context.Context,
+		serviceName string,
+		methodName string,
+		next func() (message []byte, err error),
+		params *route.Params,
+		header, trailer *metadata.Metadata,
+	) (response []byte, err error)
+	InvokeStream(ctx context.Context,
+		serviceName string,
+		methodName string,
+		next func() (message []byte, err error),
+		params *route.Params,
+		header, trailer *metadata.Metadata,
+		onMessage func(response []byte) error,
+	) error
+	InvokeRaw(ctx context.Context,
+		fullMethod string,
+		next func() (message []byte, err error),
+		header, trailer *metadata.Metadata,
+		onMessage func(response []byte) error,
+	) error
+	Method(serviceName, methodName string) (*route.Method, error)
 	Introspect() (response []byte, err error)
+	IntrospectService(serviceName string) (response []byte, err error)
+	IntrospectType(typeName string) (response []byte, err error)
+	DescriptorHash() (hash string, err error)
+	OpenAPI() (response []byte, err error)
+	JSONSchema(typeName string) (response []byte, err error)
+	Routes() (*route.Table, error)
+	InvalidateDescriptors(serviceNames ...string)
+	DescriptorCacheStats() (response []byte, err error)
 }
 
 // Server is a grpc-mate server
@@ -26,13 +68,125 @@ type Server struct {
 	router     *http.ServeMux
 	grpcClient GrpcClient
 	logger     *zap.Logger
+
+	sseKeepAlive          time.Duration
+	wsPingInterval        time.Duration
+	trailerHeaderPrefix   string
+	metadataConfig        *metadata.Config
+	defaultTimeout        time.Duration
+	methodTimeouts        map[string]time.Duration
+	problemJSON           bool
+	httpStatusCodes       map[codes.Code]int
+	grpcWebMaxMessageSize int
+	allowedOrigins        []string
+	writableActuator      bool
+}
+
+// Option configures a Server
+type Option func(*Server)
+
+// WithSSEKeepAlive sets the idle interval after which a keepalive comment is sent in Server-Sent
+// Events streams. A non-positive interval disables keepalives.
+func WithSSEKeepAlive(d time.Duration) Option {
+	return func(s *Server) {
+		s.sseKeepAlive = d
+	}
+}
+
+// WithWebSocketPingInterval sets the interval between pings sent to WebSocket clients, which are
+// considered gone if they do not respond within twice the interval. A non-positive interval
+// disables pings.
+func WithWebSocketPingInterval(d time.Duration) Option {
+	return func(s *Server) {
+		s.wsPingInterval = d
+	}
+}
+
+// WithTrailerHeaderPrefix makes the trailer metadata of calls returned as response headers named
+// with the prefix, rather than as HTTP trailers, whenever the response has not started before the
+// call ends, which is always the case but for streamed responses
+func WithTrailerHeaderPrefix(prefix string) Option {
+	return func(s *Server) {
+		s.trailerHeaderPrefix = prefix
+	}
+}
+
+// WithMetadataConfig sets the rules mapping HTTP headers to gRPC metadata and back, instead of
+// mapping Grpc-Metadata- headers only
+func WithMetadataConfig(c *metadata.Config) Option {
+	return func(s *Server) {
+		s.metadataConfig = c
+	}
+}
+
+// WithDefaultTimeout sets the timeout of calls to methods without a timeout of their own. Calls
+// have no timeout by default.
+func WithDefaultTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.defaultTimeout = d
+	}
+}
+
+// WithMethodTimeouts sets the timeouts of calls to services, e.g. helloworld.Greeter, and methods,
+// e.g. helloworld.Greeter/SayHello, which take precedence over those of their service
+func WithMethodTimeouts(timeouts map[string]time.Duration) Option {
+	return func(s *Server) {
+		s.methodTimeouts = timeouts
+	}
+}
+
+// WithProblemJSON makes errors answered with RFC 7807 problem details documents, of the
+// application/problem+json media type
+func WithProblemJSON() Option {
+	return func(s *Server) {
+		s.problemJSON = true
+	}
+}
+
+// WithHTTPStatusCodes overrides the HTTP status codes errors returned by gRPC upstream are answered
+// with, by their gRPC status code
+func WithHTTPStatusCodes(statusCodes map[codes.Code]int) Option {
+	return func(s *Server) {
+		s.httpStatusCodes = statusCodes
+	}
+}
+
+// WithGRPCWebMaxMessageSize sets the maximum size of gRPC-Web request messages, 4 MiB by default
+func WithGRPCWebMaxMessageSize(size int) Option {
+	return func(s *Server) {
+		s.grpcWebMaxMessageSize = size
+	}
+}
+
+// WithAllowedOrigins sets the origins gRPC-Web calls are allowed from by CORS, and WebSocket calls
+// besides the origin of the request host, * allowing any. Cross-origin calls are not allowed by
+// default.
+func WithAllowedOrigins(origins []string) Option {
+	return func(s *Server) {
+		s.allowedOrigins = origins
+	}
+}
+
+// WithWritableActuator enables the actuator endpoints that change the state of the server, i.e.
+// the invalidation of the descriptor cache, which are read-only by default as they are not
+// authenticated
+func WithWritableActuator() Option {
+	return func(s *Server) {
+		s.writableActuator = true
+	}
 }
 
 // New creates a new grpc-mate server
-func New(grpcClient GrpcClient, logger *zap.Logger) *Server {
+func New(grpcClient GrpcClient, logger *zap.Logger, opts ...Option) *Server {
 	s := &Server{
-		router: http.NewServeMux(),
-		logger: logger,
+		router:                http.NewServeMux(),
+		logger:                logger,
+		sseKeepAlive:          defaultSSEKeepAlive,
+		wsPingInterval:        defaultWebSocketPingInterval,
+		grpcWebMaxMessageSize: defaultGRPCWebMaxMessageSize,
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
 	s.registerHandlers(grpcClient)
 	return s
@@ -0,0 +1,177 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdong42/grpc-mate/metadata"
+	"github.com/gdong42/grpc-mate/route"
+	"go.uber.org/zap"
+	grpc_metadata "google.golang.org/grpc/metadata"
+)
+
+const (
+	eventStreamContentType = "text/event-stream"
+	// lastEventIDMetadataKey is the metadata key the Last-Event-ID header is forwarded as
+	lastEventIDMetadataKey = "last-event-id"
+	// defaultSSEKeepAlive is the default idle interval after which a keepalive comment is sent
+	defaultSSEKeepAlive = 15 * time.Second
+)
+
+// acceptsEventStream tells if the client asks for Server-Sent Events
+func acceptsEventStream(r *http.Request) bool {
+	for _, v := range r.Header["Accept"] {
+		for _, t := range strings.Split(v, ",") {
+			if strings.HasPrefix(strings.TrimSpace(t), eventStreamContentType) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// invokeEventStream writes the messages of a server streaming call as Server-Sent Events, one
+// data event per message, followed by a terminal end event on success or error event on failure
+// carrying the gRPC status. Events are numbered by their id, continuing from the Last-Event-ID
+// header, which is also forwarded to the upstream as last-event-id metadata so that it can resume
+// the stream. A keepalive comment is sent whenever the stream has been idle for the keepalive
+// interval, unless it is not positive. The call fails like a unary one when it fails before the stream starts.
+func (s *Server) invokeEventStream(ctx context.Context, w http.ResponseWriter, r *http.Request, client GrpcClient,
+	c callee, mapper *metadata.Mapper, inputMessage []byte, params *route.Params) {
+
+	ew := &eventWriter{w: w}
+	ew.flusher, _ = w.(http.Flusher)
+	if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "" {
+		ctx = grpc_metadata.AppendToOutgoingContext(ctx, lastEventIDMetadataKey, lastEventID)
+		if id, err := strconv.ParseInt(lastEventID, 10, 64); err == nil {
+			ew.id = id
+		}
+	}
+
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		// a non-positive keepalive interval disables keepalives
+		var tick <-chan time.Time
+		if s.sseKeepAlive > 0 {
+			t := time.NewTicker(s.sseKeepAlive)
+			defer t.Stop()
+			tick = t.C
+		}
+		for {
+			select {
+			case <-done:
+				return
+			case <-tick:
+				ew.keepAlive()
+			}
+		}
+	}()
+	var header, trailer metadata.Metadata
+	err := client.InvokeServerStream(ctx, c.Service, c.Method, inputMessage, params, &header, &trailer, func(m []byte) error {
+		ew.setHeader(mapper.ToHeaders(header))
+		return ew.message(m)
+	})
+	close(done)
+	<-stopped
+
+	if err != nil {
+		s.logger.Error("error in handling streaming call",
+			zap.String("err", err.Error()))
+		if !ew.started {
+			writeHeaderMetadata(w, mapper.ToHeaders(header))
+			s.writeTrailerMetadata(w, mapper, trailer, false)
+			s.returnError(w, err)
+			return
+		}
+	}
+	ew.setHeader(mapper.ToHeaders(header))
+	s.writeTrailerMetadata(w, mapper, trailer, true)
+	st, _ := json.Marshal(grpcStatus(err))
+	if err != nil {
+		ew.event("error", st)
+	} else {
+		ew.event("end", st)
+	}
+}
+
+// eventWriter writes Server-Sent Events, starting the response on the first write
+type eventWriter struct {
+	mu      sync.Mutex
+	w       http.ResponseWriter
+	flusher http.Flusher
+	started bool
+	// active tells if anything was written since the last keepalive tick
+	active bool
+	// id is the id of the last message event
+	id int64
+}
+
+// setHeader adds the headers header metadata is mapped to, unless the response has started
+func (e *eventWriter) setHeader(headers map[string][]string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.started {
+		writeHeaderMetadata(e.w, headers)
+	}
+}
+
+// message writes a data event with the next id
+func (e *eventWriter) message(data []byte) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.id++
+	return e.write("id: " + strconv.FormatInt(e.id, 10) + "\n" + dataLines(data) + "\n")
+}
+
+// event writes a named event
+func (e *eventWriter) event(name string, data []byte) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.write("event: " + name + "\n" + dataLines(data) + "\n")
+}
+
+// keepAlive writes a comment if nothing was written since the last call
+func (e *eventWriter) keepAlive() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.active {
+		e.active = false
+		return nil
+	}
+	err := e.write(": keepalive\n\n")
+	e.active = false
+	return err
+}
+
+func (e *eventWriter) write(s string) error {
+	if !e.started {
+		e.w.Header().Set("Content-Type", eventStreamContentType)
+		e.w.Header().Set("Cache-Control", "no-cache")
+		e.w.WriteHeader(http.StatusOK)
+		e.started = true
+	}
+	e.active = true
+	if _, err := fmt.Fprint(e.w, s); err != nil {
+		return err
+	}
+	if e.flusher != nil {
+		e.flusher.Flush()
+	}
+	return nil
+}
+
+// dataLines prefixes every line of data with the data field name
+func dataLines(data []byte) string {
+	var b strings.Builder
+	for _, l := range strings.Split(string(data), "\n") {
+		b.WriteString("data: " + l + "\n")
+	}
+	return b.String()
+}
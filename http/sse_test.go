@@ -0,0 +1,144 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	perrors "github.com/gdong42/grpc-mate/errors"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+)
+
+func TestRPCCallHandlerEventStream(t *testing.T) {
+	cases := []struct {
+		name        string
+		lastEventID string
+		messages    []string
+		err         error
+		status      int
+		body        string
+		metadata    []string
+	}{
+		{
+			name:     "success",
+			messages: []string{`{"id":1}`, `{"id":2}`},
+			status:   http.StatusOK,
+			body: "id: 1\ndata: {\"id\":1}\n\n" +
+				"id: 2\ndata: {\"id\":2}\n\n" +
+				"event: end\ndata: {\"code\":0,\"message\":\"\"}\n\n",
+		},
+		{
+			name:     "error after messages",
+			messages: []string{`{"id":1}`},
+			err: &perrors.GRPCError{
+				StatusCode: int(codes.Internal),
+				Message:    "stream broken",
+			},
+			status: http.StatusOK,
+			body: "id: 1\ndata: {\"id\":1}\n\n" +
+				"event: error\ndata: {\"code\":13,\"message\":\"stream broken\"}\n\n",
+		},
+		{
+			name:     "error before messages",
+			messages: []string{},
+			err: &perrors.GRPCError{
+				StatusCode: int(codes.NotFound),
+				Message:    "not found",
+			},
+			status: http.StatusNotFound,
+			body:   "{\"code\":5,\"message\":\"not found\"}\n",
+		},
+		{
+			name:        "resumed from last event id",
+			lastEventID: "41",
+			messages:    []string{`{"id":42}`},
+			status:      http.StatusOK,
+			body: "id: 42\ndata: {\"id\":42}\n\n" +
+				"event: end\ndata: {\"code\":0,\"message\":\"\"}\n\n",
+			metadata: []string{"41"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mc := &mockClient{
+				isReady:   true,
+				streams:   map[string][]string{"method1": tc.messages},
+				streamErr: tc.err,
+			}
+			server := New(mc, zap.NewNop())
+			req, err := http.NewRequest("POST", "/v1/svc1/method1", strings.NewReader(`{}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Accept", "text/event-stream")
+			if tc.lastEventID != "" {
+				req.Header.Set("Last-Event-ID", tc.lastEventID)
+			}
+			rr := httptest.NewRecorder()
+			server.router.ServeHTTP(rr, req)
+
+			if got, want := rr.Code, tc.status; got != want {
+				t.Fatalf("got %d, want %d", got, want)
+			}
+			if got, want := rr.Body.String(), tc.body; got != want {
+				t.Fatalf("got %q, want %q", got, want)
+			}
+			if got, want := mc.lastMetadata["last-event-id"], tc.metadata; !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+			if tc.status == http.StatusOK {
+				if got, want := rr.Header().Get("Content-Type"), "text/event-stream"; got != want {
+					t.Fatalf("got %q, want %q", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRPCCallHandlerEventStreamKeepAlive(t *testing.T) {
+	cases := []struct {
+		name      string
+		keepAlive time.Duration
+		body      string
+	}{
+		{
+			name:      "enabled",
+			keepAlive: 10 * time.Millisecond,
+			body:      ": keepalive\n\n",
+		},
+		{
+			name:      "disabled",
+			keepAlive: 0,
+			body:      "id: 1\n",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mc := &mockClient{
+				isReady:     true,
+				streams:     map[string][]string{"method1": {`{"id":1}`}},
+				streamDelay: 50 * time.Millisecond,
+			}
+			server := New(mc, zap.NewNop(), WithSSEKeepAlive(tc.keepAlive))
+			req, err := http.NewRequest("POST", "/v1/svc1/method1", strings.NewReader(`{}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Accept", "text/event-stream")
+			rr := httptest.NewRecorder()
+			server.router.ServeHTTP(rr, req)
+
+			body := rr.Body.String()
+			if !strings.HasPrefix(body, tc.body) {
+				t.Fatalf("got %q, want it to start with %q", body, tc.body)
+			}
+			if !strings.Contains(body, "id: 1\ndata: {\"id\":1}\n\n") {
+				t.Fatalf("got %q, want the message event", body)
+			}
+		})
+	}
+}
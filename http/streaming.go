@@ -0,0 +1,175 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	perrors "github.com/gdong42/grpc-mate/errors"
+	"github.com/gdong42/grpc-mate/metadata"
+	"github.com/gdong42/grpc-mate/route"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	ndjsonContentType = "application/x-ndjson"
+	// grpcStatusTrailer and grpcMessageTrailer carry the gRPC status of a streamed response
+	grpcStatusTrailer  = "Grpc-Status"
+	grpcMessageTrailer = "Grpc-Message"
+)
+
+// invokeServerStream writes the messages of a server streaming call as newline-delimited JSON,
+// flushing every message as it arrives, followed by the gRPC status in the Grpc-Status and
+// Grpc-Message trailers, along with the trailer metadata. The call fails like a unary one when it
+// fails before the first message. The upstream stream is canceled when the client goes away, since
+// ctx is the request context.
+func (s *Server) invokeServerStream(ctx context.Context, w http.ResponseWriter, client GrpcClient, c callee,
+	mapper *metadata.Mapper, inputMessage []byte, params *route.Params) {
+
+	var header, trailer metadata.Metadata
+	started := false
+	start := func() {
+		writeHeaderMetadata(w, mapper.ToHeaders(header))
+		w.Header().Set("Content-Type", ndjsonContentType)
+		w.Header().Set("Trailer", grpcStatusTrailer+", "+grpcMessageTrailer)
+		w.WriteHeader(http.StatusOK)
+		started = true
+	}
+	flusher, _ := w.(http.Flusher)
+	err := client.InvokeServerStream(ctx, c.Service, c.Method, inputMessage, params, &header, &trailer, func(m []byte) error {
+		if !started {
+			start()
+		}
+		if _, err := w.Write(append(m, '\n')); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		s.logger.Error("error in handling streaming call",
+			zap.String("err", err.Error()))
+		if !started {
+			writeHeaderMetadata(w, mapper.ToHeaders(header))
+			s.writeTrailerMetadata(w, mapper, trailer, false)
+			s.returnError(w, err)
+			return
+		}
+	}
+	if !started {
+		start()
+	}
+	s.writeTrailerMetadata(w, mapper, trailer, true)
+	st := grpcStatus(err)
+	w.Header().Set(grpcStatusTrailer, strconv.Itoa(st.StatusCode))
+	w.Header().Set(grpcMessageTrailer, st.Message)
+}
+
+// invokeClientStream sends the elements of a JSON array body, or the messages of a newline-delimited
+// JSON body, as the input messages of a client streaming call. Messages are decoded one at a time
+// while the call goes on, rather than reading the whole body upfront.
+func (s *Server) invokeClientStream(ctx context.Context, w http.ResponseWriter, client GrpcClient, c callee,
+	mapper *metadata.Mapper, body io.Reader, params *route.Params) {
+
+	var header, trailer metadata.Metadata
+	response, err := client.InvokeClientStream(ctx, c.Service, c.Method, jsonStream(body), params, &header, &trailer)
+	writeHeaderMetadata(w, mapper.ToHeaders(header))
+	s.writeTrailerMetadata(w, mapper, trailer, false)
+	if err != nil {
+		s.returnError(w, err)
+		s.logger.Error("error in handling streaming call",
+			zap.String("err", err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(response)
+}
+
+// jsonStream returns a function reading the next element of a JSON array, or the next value of a
+// newline-delimited JSON stream, from r. It returns io.EOF after the last one.
+func jsonStream(r io.Reader) func() ([]byte, error) {
+	if r == nil {
+		return func() ([]byte, error) {
+			return nil, io.EOF
+		}
+	}
+	br := bufio.NewReader(r)
+	dec := json.NewDecoder(br)
+	// the body is an array if its first non-whitespace character opens one
+	isArray, started := false, false
+	return func() ([]byte, error) {
+		if !started {
+			started = true
+			b, err := skipSpace(br)
+			if err == io.EOF {
+				return nil, io.EOF
+			}
+			if err != nil {
+				return nil, invalidJSONStream(err)
+			}
+			if b == '[' {
+				isArray = true
+				if _, err := dec.Token(); err != nil {
+					return nil, invalidJSONStream(err)
+				}
+			}
+		}
+		if isArray && !dec.More() {
+			if _, err := dec.Token(); err != nil {
+				return nil, invalidJSONStream(err)
+			}
+			return nil, io.EOF
+		}
+		var m json.RawMessage
+		if err := dec.Decode(&m); err == io.EOF && !isArray {
+			return nil, io.EOF
+		} else if err != nil {
+			return nil, invalidJSONStream(err)
+		}
+		return m, nil
+	}
+}
+
+// skipSpace skips whitespace and returns the next byte without consuming it
+func skipSpace(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if b != ' ' && b != '\t' && b != '\r' && b != '\n' {
+			return b, br.UnreadByte()
+		}
+	}
+}
+
+func invalidJSONStream(err error) error {
+	return &perrors.ProxyError{
+		Code:    perrors.MessageTypeMismatch,
+		Message: "request body is neither a JSON array nor newline-delimited JSON: " + err.Error(),
+	}
+}
+
+// grpcStatus converts the error of a call into a gRPC status
+func grpcStatus(err error) *perrors.GRPCError {
+	if err == nil {
+		return &perrors.GRPCError{StatusCode: int(codes.OK)}
+	}
+	switch e := errors.Cause(err).(type) {
+	case *perrors.GRPCError:
+		return e
+	case *perrors.ProxyError:
+		return &perrors.GRPCError{StatusCode: int(e.GRPCCode()), Message: e.Message}
+	default:
+		return &perrors.GRPCError{StatusCode: int(codes.Unknown), Message: err.Error()}
+	}
+}
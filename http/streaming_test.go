@@ -0,0 +1,180 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	perrors "github.com/gdong42/grpc-mate/errors"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+)
+
+func TestRPCCallHandlerServerStreaming(t *testing.T) {
+	cases := []struct {
+		name        string
+		messages    []string
+		err         error
+		status      int
+		contentType string
+		body        string
+		grpcStatus  string
+		grpcMessage string
+	}{
+		{
+			name:        "success",
+			messages:    []string{`{"id":1}`, `{"id":2}`},
+			status:      http.StatusOK,
+			contentType: "application/x-ndjson",
+			body:        "{\"id\":1}\n{\"id\":2}\n",
+			grpcStatus:  "0",
+		},
+		{
+			name:        "empty stream",
+			messages:    []string{},
+			status:      http.StatusOK,
+			contentType: "application/x-ndjson",
+			body:        "",
+			grpcStatus:  "0",
+		},
+		{
+			name:     "error after messages",
+			messages: []string{`{"id":1}`},
+			err: &perrors.GRPCError{
+				StatusCode: int(codes.Internal),
+				Message:    "stream broken",
+			},
+			status:      http.StatusOK,
+			contentType: "application/x-ndjson",
+			body:        "{\"id\":1}\n",
+			grpcStatus:  "13",
+			grpcMessage: "stream broken",
+		},
+		{
+			name:     "error before messages",
+			messages: []string{},
+			err: &perrors.GRPCError{
+				StatusCode: int(codes.NotFound),
+				Message:    "not found",
+			},
+			status:      http.StatusNotFound,
+			contentType: "",
+			body:        "{\"code\":5,\"message\":\"not found\"}\n",
+			grpcStatus:  "",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mc := &mockClient{
+				isReady:   true,
+				streams:   map[string][]string{"method1": tc.messages},
+				streamErr: tc.err,
+			}
+			server := New(mc, zap.NewNop())
+			req, err := http.NewRequest("POST", "/v1/svc1/method1", strings.NewReader(`{}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rr := httptest.NewRecorder()
+			server.router.ServeHTTP(rr, req)
+			res := rr.Result()
+
+			if got, want := res.StatusCode, tc.status; got != want {
+				t.Fatalf("got %d, want %d", got, want)
+			}
+			if got, want := res.Header.Get("Content-Type"), tc.contentType; got != want {
+				t.Fatalf("got %q, want %q", got, want)
+			}
+			if got, want := rr.Body.String(), tc.body; got != want {
+				t.Fatalf("got %q, want %q", got, want)
+			}
+			if got, want := res.Trailer.Get("Grpc-Status"), tc.grpcStatus; got != want {
+				t.Fatalf("got %q, want %q", got, want)
+			}
+			if got, want := res.Trailer.Get("Grpc-Message"), tc.grpcMessage; got != want {
+				t.Fatalf("got %q, want %q", got, want)
+			}
+			if !rr.Flushed && len(tc.messages) > 0 {
+				t.Fatalf("messages were not flushed")
+			}
+		})
+	}
+}
+
+func TestRPCCallHandlerClientStreaming(t *testing.T) {
+	cases := []struct {
+		name     string
+		body     string
+		status   int
+		messages []string
+	}{
+		{
+			name:     "json array",
+			body:     ` [{"id":1}, {"id":2} ,{"id":3}]`,
+			status:   http.StatusOK,
+			messages: []string{`{"id":1}`, `{"id":2}`, `{"id":3}`},
+		},
+		{
+			name:     "empty json array",
+			body:     `[]`,
+			status:   http.StatusOK,
+			messages: []string{},
+		},
+		{
+			name:     "newline-delimited json",
+			body:     "{\"id\":1}\n{\"id\":2}\n",
+			status:   http.StatusOK,
+			messages: []string{`{"id":1}`, `{"id":2}`},
+		},
+		{
+			name:     "empty body",
+			body:     "",
+			status:   http.StatusOK,
+			messages: []string{},
+		},
+		{
+			name:     "malformed array",
+			body:     `[{"id":1},`,
+			status:   http.StatusBadRequest,
+			messages: []string{`{"id":1}`},
+		},
+		{
+			name:     "malformed newline-delimited json",
+			body:     "{\"id\":1}\n{\"id\":",
+			status:   http.StatusBadRequest,
+			messages: []string{`{"id":1}`},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mc := &mockClient{
+				isReady:       true,
+				clientStreams: map[string]bool{"method1": true},
+			}
+			server := New(mc, zap.NewNop())
+			req, err := http.NewRequest("POST", "/v1/svc1/method1", strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rr := httptest.NewRecorder()
+			server.router.ServeHTTP(rr, req)
+
+			if got, want := rr.Code, tc.status; got != want {
+				t.Fatalf("got %d, want %d", got, want)
+			}
+			if got, want := mc.lastMessages, tc.messages; !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+			if tc.status != http.StatusOK {
+				return
+			}
+			want := fmt.Sprintf(`{"service":"svc1","method":"method1","count":%d}`, len(tc.messages))
+			if got := rr.Body.String(); got != want {
+				t.Fatalf("got %s, want %s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	perrors "github.com/gdong42/grpc-mate/errors"
+)
+
+const (
+	// grpcTimeoutHeader carries a timeout in the gRPC format, e.g. 100m for 100 milliseconds
+	grpcTimeoutHeader = "Grpc-Timeout"
+	// requestTimeoutHeader carries a timeout as a duration, e.g. 1.5s, or as a whole number of
+	// seconds
+	requestTimeoutHeader = "X-Request-Timeout"
+)
+
+// grpcTimeoutUnits are the units of Grpc-Timeout values
+var grpcTimeoutUnits = map[byte]time.Duration{
+	'H': time.Hour,
+	'M': time.Minute,
+	'S': time.Second,
+	'm': time.Millisecond,
+	'u': time.Microsecond,
+	'n': time.Nanosecond,
+}
+
+// callTimeout returns the timeout of the call, or 0 if it has none. It is the one requested by the
+// Grpc-Timeout or X-Request-Timeout header, limited by the one configured for the method, its
+// service, or all calls, whichever is the most specific.
+func (s *Server) callTimeout(r *http.Request, c callee) (time.Duration, error) {
+	timeout, err := requestTimeout(r.Header)
+	if err != nil {
+		return 0, err
+	}
+	if t := s.methodTimeout(c); t > 0 && (timeout == 0 || t < timeout) {
+		timeout = t
+	}
+	return timeout, nil
+}
+
+// withTimeout returns a cancelable context, with a deadline unless timeout is 0
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout == 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// methodTimeout returns the timeout configured for the method, or 0 if none
+func (s *Server) methodTimeout(c callee) time.Duration {
+	if t, ok := s.methodTimeouts[c.Service+"/"+c.Method]; ok {
+		return t
+	}
+	if t, ok := s.methodTimeouts[c.Service]; ok {
+		return t
+	}
+	return s.defaultTimeout
+}
+
+// requestTimeout parses the timeout requested by the headers, or returns 0 if none
+func requestTimeout(h http.Header) (time.Duration, error) {
+	if v := h.Get(grpcTimeoutHeader); v != "" {
+		t, err := parseGRPCTimeout(v)
+		if err != nil {
+			return 0, invalidTimeout(grpcTimeoutHeader, err)
+		}
+		return t, nil
+	}
+	if v := h.Get(requestTimeoutHeader); v != "" {
+		t, err := parseRequestTimeout(v)
+		if err != nil {
+			return 0, invalidTimeout(requestTimeoutHeader, err)
+		}
+		return t, nil
+	}
+	return 0, nil
+}
+
+// parseGRPCTimeout parses a timeout made of at most 8 digits followed by a unit
+func parseGRPCTimeout(v string) (time.Duration, error) {
+	if len(v) < 2 || len(v) > 9 {
+		return 0, fmt.Errorf("invalid value %q", v)
+	}
+	unit, ok := grpcTimeoutUnits[v[len(v)-1]]
+	if !ok {
+		return 0, fmt.Errorf("invalid unit of %q", v)
+	}
+	n, err := strconv.ParseUint(v[:len(v)-1], 10, 64)
+	if err != nil || n == 0 {
+		return 0, fmt.Errorf("invalid value %q", v)
+	}
+	// the largest value in hours overflows a time.Duration
+	if unit == time.Hour && n > uint64(1<<63-1)/uint64(time.Hour) {
+		return 1<<63 - 1, nil
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// parseRequestTimeout parses a timeout either as a duration or as a number of seconds
+func parseRequestTimeout(v string) (time.Duration, error) {
+	t, err := time.ParseDuration(v)
+	if err != nil {
+		n, nerr := strconv.ParseUint(v, 10, 32)
+		if nerr != nil {
+			return 0, fmt.Errorf("invalid value %q", v)
+		}
+		t = time.Duration(n) * time.Second
+	}
+	if t <= 0 {
+		return 0, fmt.Errorf("non positive value %q", v)
+	}
+	return t, nil
+}
+
+func invalidTimeout(header string, err error) error {
+	return &perrors.ProxyError{
+		Code:    perrors.InvalidMetadata,
+		Message: fmt.Sprintf("invalid timeout %s: %s", header, err.Error()),
+	}
+}
@@ -0,0 +1,175 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	cases := []struct {
+		name    string
+		headers map[string]string
+		timeout time.Duration
+		invalid bool
+	}{
+		{
+			name:    "none",
+			timeout: 0,
+		},
+		{
+			name:    "grpc timeout",
+			headers: map[string]string{"Grpc-Timeout": "100m"},
+			timeout: 100 * time.Millisecond,
+		},
+		{
+			name:    "grpc timeout in hours",
+			headers: map[string]string{"Grpc-Timeout": "2H"},
+			timeout: 2 * time.Hour,
+		},
+		{
+			name:    "grpc timeout takes precedence",
+			headers: map[string]string{"Grpc-Timeout": "1S", "X-Request-Timeout": "2s"},
+			timeout: time.Second,
+		},
+		{
+			name:    "request timeout duration",
+			headers: map[string]string{"X-Request-Timeout": "1.5s"},
+			timeout: 1500 * time.Millisecond,
+		},
+		{
+			name:    "request timeout seconds",
+			headers: map[string]string{"X-Request-Timeout": "3"},
+			timeout: 3 * time.Second,
+		},
+		{
+			name:    "invalid grpc timeout unit",
+			headers: map[string]string{"Grpc-Timeout": "100x"},
+			invalid: true,
+		},
+		{
+			name:    "grpc timeout too long",
+			headers: map[string]string{"Grpc-Timeout": "123456789S"},
+			invalid: true,
+		},
+		{
+			name:    "negative request timeout",
+			headers: map[string]string{"X-Request-Timeout": "-1s"},
+			invalid: true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := http.Header{}
+			for k, v := range tc.headers {
+				h.Set(k, v)
+			}
+			timeout, err := requestTimeout(h)
+			if got, want := err != nil, tc.invalid; got != want {
+				t.Fatalf("got error %v, want error %t", err, want)
+			}
+			if got, want := timeout, tc.timeout; got != want {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestRPCCallHandlerTimeout(t *testing.T) {
+	cases := []struct {
+		name    string
+		method  string
+		header  string
+		opts    []Option
+		status  int
+		timeout time.Duration
+	}{
+		{
+			name:   "no timeout",
+			method: "method1",
+			status: http.StatusOK,
+		},
+		{
+			name:    "requested",
+			method:  "method1",
+			header:  "5S",
+			status:  http.StatusOK,
+			timeout: 5 * time.Second,
+		},
+		{
+			name:    "default",
+			method:  "method1",
+			opts:    []Option{WithDefaultTimeout(10 * time.Second)},
+			status:  http.StatusOK,
+			timeout: 10 * time.Second,
+		},
+		{
+			name:   "method overrides service and default",
+			method: "method1",
+			opts: []Option{
+				WithDefaultTimeout(10 * time.Second),
+				WithMethodTimeouts(map[string]time.Duration{"svc1": 5 * time.Second, "svc1/method1": 2 * time.Second}),
+			},
+			status:  http.StatusOK,
+			timeout: 2 * time.Second,
+		},
+		{
+			name:   "service overrides default",
+			method: "method2",
+			opts: []Option{
+				WithDefaultTimeout(10 * time.Second),
+				WithMethodTimeouts(map[string]time.Duration{"svc1": 5 * time.Second, "svc1/method1": 2 * time.Second}),
+			},
+			status:  http.StatusOK,
+			timeout: 5 * time.Second,
+		},
+		{
+			name:    "requested is limited by configured",
+			method:  "method1",
+			header:  "1M",
+			opts:    []Option{WithDefaultTimeout(10 * time.Second)},
+			status:  http.StatusOK,
+			timeout: 10 * time.Second,
+		},
+		{
+			name:   "invalid",
+			method: "method1",
+			header: "1y",
+			status: http.StatusBadRequest,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mc := &mockClient{isReady: true}
+			server := New(mc, zap.NewNop(), tc.opts...)
+			req, err := http.NewRequest("POST", "/v1/svc1/"+tc.method, strings.NewReader(`{}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.header != "" {
+				req.Header.Set("Grpc-Timeout", tc.header)
+			}
+			rr := httptest.NewRecorder()
+			start := time.Now()
+			server.RPCCallHandler(mc).ServeHTTP(rr, req)
+
+			if got, want := rr.Code, tc.status; got != want {
+				t.Fatalf("got status %v, want %v", got, want)
+			}
+			if tc.timeout == 0 {
+				if !mc.lastDeadline.IsZero() {
+					t.Fatalf("got deadline %v, want none", mc.lastDeadline)
+				}
+				return
+			}
+			// the deadline is set between start and now
+			if d := mc.lastDeadline.Sub(start); d < tc.timeout || d > tc.timeout+time.Since(start) {
+				t.Fatalf("got timeout %v, want %v", d, tc.timeout)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package http
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gdong42/grpc-mate/metadata"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	grpc_metadata "google.golang.org/grpc/metadata"
+)
+
+const (
+	// defaultWebSocketPingInterval is the default interval between pings sent to WebSocket clients
+	defaultWebSocketPingInterval = 30 * time.Second
+	// webSocketEOF is the text message a WebSocket client sends once it has no more messages
+	webSocketEOF = "EOF"
+	// webSocketStatusBase is added to the gRPC status code of a failed call to make the close code
+	webSocketStatusBase = 4000
+	// maxCloseReason is the maximum length of the reason of a close frame
+	maxCloseReason = 123
+	writeWait      = 10 * time.Second
+)
+
+// invokeWebSocket upgrades the request to a WebSocket connection carrying the messages of a call of
+// any kind, one JSON message per frame in both directions. The client sends an EOF text message
+// once it has no more messages to send. The connection is closed with the gRPC status of the call,
+// as a normal closure on success, or with the status code plus 4000 and the status message on
+// failure. Metadata is taken from the headers of the upgrade request, and pings are sent to detect
+// clients gone away, which cancels the upstream call. Frames are still read once the input is
+// complete, after the first message for methods without client streaming, and discarded, so that
+// the client closing the connection cancels the call too. Upgrades are accepted from the origin of
+// the request host, or from the allowed origins.
+func (s *Server) invokeWebSocket(w http.ResponseWriter, r *http.Request, client GrpcClient, c callee) {
+	md, err := s.metadataConfig.Mapper(c.Service, c.Method).FromHeaders(r.Header)
+	if err != nil {
+		s.returnError(w, err)
+		s.logger.Error("error in handling WebSocket call",
+			zap.String("err", err.Error()))
+		return
+	}
+	timeout, err := s.callTimeout(r, c)
+	if err != nil {
+		s.returnError(w, err)
+		s.logger.Error("error in handling WebSocket call",
+			zap.String("err", err.Error()))
+		return
+	}
+	m, err := client.Method(c.Service, c.Method)
+	if err != nil {
+		s.returnError(w, err)
+		s.logger.Error("error in handling WebSocket call",
+			zap.String("err", err.Error()))
+		return
+	}
+	upgrader := websocket.Upgrader{CheckOrigin: s.checkWebSocketOrigin}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader already replied with an error
+		s.logger.Error("error in upgrading to WebSocket",
+			zap.String("err", err.Error()))
+		return
+	}
+	defer conn.Close()
+
+	// the request context is not canceled when a hijacked connection goes away
+	ctx, cancel := withTimeout(grpc_metadata.NewOutgoingContext(context.Background(), grpc_metadata.MD(md)),
+		timeout)
+	defer cancel()
+
+	// a non-positive ping interval disables pings, and with them the read deadline
+	if s.wsPingInterval > 0 {
+		pongWait := 2 * s.wsPingInterval
+		conn.SetReadDeadline(time.Now().Add(pongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(pongWait))
+		})
+	}
+	// frames are read continuously for control frames to be handled, data frames are passed to
+	// the call on demand until the input is consumed, and discarded afterwards
+	frames := make(chan []byte)
+	consumed := make(chan struct{})
+	var readErr error
+	go func() {
+		defer close(frames)
+		for {
+			_, b, err := conn.ReadMessage()
+			if err != nil {
+				readErr = err
+				cancel()
+				return
+			}
+			select {
+			case frames <- b:
+			case <-consumed:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		var tick <-chan time.Time
+		if s.wsPingInterval > 0 {
+			t := time.NewTicker(s.wsPingInterval)
+			defer t.Stop()
+			tick = t.C
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	var done bool
+	next := func() ([]byte, error) {
+		if done {
+			return nil, io.EOF
+		}
+		b, ok := <-frames
+		if !ok {
+			if readErr != nil {
+				return nil, readErr
+			}
+			return nil, ctx.Err()
+		}
+		if string(b) == webSocketEOF || !m.ClientStreaming {
+			done = true
+			close(consumed)
+		}
+		if string(b) == webSocketEOF {
+			return nil, io.EOF
+		}
+		return b, nil
+	}
+	// the upgrade response is sent before the call, so its metadata is not returned
+	var header, trailer metadata.Metadata
+	err = client.InvokeStream(ctx, c.Service, c.Method, next, nil, &header, &trailer, func(m []byte) error {
+		conn.SetWriteDeadline(time.Now().Add(writeWait))
+		return conn.WriteMessage(websocket.TextMessage, m)
+	})
+	if err != nil {
+		s.logger.Error("error in handling WebSocket call",
+			zap.String("err", err.Error()))
+	}
+	conn.WriteControl(websocket.CloseMessage, closeMessage(err), time.Now().Add(writeWait))
+}
+
+// checkWebSocketOrigin tells if an upgrade request is accepted, when it has no Origin header, like
+// requests of non-browser clients, or comes from the origin of the request host or an allowed one
+func (s *Server) checkWebSocketOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || s.allowsOrigin(origin) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// closeMessage makes the close frame carrying the gRPC status of a call
+func closeMessage(err error) []byte {
+	st := grpcStatus(err)
+	if st.StatusCode == int(codes.OK) {
+		return websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	}
+	reason := st.Message
+	if len(reason) > maxCloseReason {
+		reason = reason[:maxCloseReason]
+		// the reason must stay valid UTF-8
+		for !utf8.ValidString(reason) {
+			reason = reason[:len(reason)-1]
+		}
+	}
+	return websocket.FormatCloseMessage(webSocketStatusBase+st.StatusCode, reason)
+}
@@ -0,0 +1,243 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	perrors "github.com/gdong42/grpc-mate/errors"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+)
+
+func TestRPCCallHandlerWebSocket(t *testing.T) {
+	cases := []struct {
+		name      string
+		messages  []string
+		err       error
+		closeCode int
+		reason    string
+	}{
+		{
+			name:      "success",
+			messages:  []string{`{"id":1}`, `{"id":2}`},
+			closeCode: websocket.CloseNormalClosure,
+		},
+		{
+			name:     "grpc error",
+			messages: []string{`{"id":1}`},
+			err: &perrors.GRPCError{
+				StatusCode: int(codes.NotFound),
+				Message:    "not found",
+			},
+			closeCode: 4005,
+			reason:    "not found",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mc := &mockClient{
+				isReady:       true,
+				streamErr:     tc.err,
+				clientStreams: map[string]bool{"method1": true},
+			}
+			server := New(mc, zap.NewNop())
+			ts := httptest.NewServer(server.router)
+			defer ts.Close()
+
+			header := http.Header{}
+			header.Set("Grpc-Metadata-Foo", "bar")
+			conn, _, err := websocket.DefaultDialer.Dial(
+				"ws"+strings.TrimPrefix(ts.URL, "http")+"/v1/svc1/method1", header)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			for _, m := range tc.messages {
+				if err := conn.WriteMessage(websocket.TextMessage, []byte(m)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, []byte("EOF")); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for {
+				_, b, err := conn.ReadMessage()
+				if err != nil {
+					ce, ok := err.(*websocket.CloseError)
+					if !ok {
+						t.Fatalf("got %v, want a close error", err)
+					}
+					if got, want := ce.Code, tc.closeCode; got != want {
+						t.Fatalf("got %d, want %d", got, want)
+					}
+					if got, want := ce.Text, tc.reason; got != want {
+						t.Fatalf("got %q, want %q", got, want)
+					}
+					break
+				}
+				got = append(got, string(b))
+			}
+			if want := tc.messages; !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+			if got, want := mc.lastMetadata["foo"], []string{"bar"}; !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestRPCCallHandlerWebSocketCloseCancelsCall(t *testing.T) {
+	mc := &mockClient{
+		isReady:  true,
+		streams:  map[string][]string{"method1": nil},
+		canceled: make(chan struct{}),
+	}
+	server := New(mc, zap.NewNop())
+	ts := httptest.NewServer(server.router)
+	defer ts.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial(
+		"ws"+strings.TrimPrefix(ts.URL, "http")+"/v1/svc1/method1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for _, m := range []string{`{"id":1}`, "EOF"} {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(m)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, b, err := conn.ReadMessage(); err != nil || string(b) != `{"id":1}` {
+		t.Fatalf("got %s, %v, want the echoed message", b, err)
+	}
+	closing := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	if err := conn.WriteControl(websocket.CloseMessage, closing, time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-mc.canceled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the upstream call should be canceled")
+	}
+}
+
+func TestRPCCallHandlerWebSocketPing(t *testing.T) {
+	mc := &mockClient{
+		isReady: true,
+	}
+	server := New(mc, zap.NewNop(), WithWebSocketPingInterval(10*time.Millisecond))
+	ts := httptest.NewServer(server.router)
+	defer ts.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial(
+		"ws"+strings.TrimPrefix(ts.URL, "http")+"/v1/svc1/method1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// the call is ended once pinged
+	conn.SetPingHandler(func(data string) error {
+		if err := conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second)); err != nil {
+			return err
+		}
+		return conn.WriteMessage(websocket.TextMessage, []byte("EOF"))
+	})
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+		t.Fatalf("got %v, want a normal closure", err)
+	}
+}
+
+func TestRPCCallHandlerWebSocketPingDisabled(t *testing.T) {
+	mc := &mockClient{
+		isReady: true,
+	}
+	server := New(mc, zap.NewNop(), WithWebSocketPingInterval(0))
+	ts := httptest.NewServer(server.router)
+	defer ts.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial(
+		"ws"+strings.TrimPrefix(ts.URL, "http")+"/v1/svc1/method1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.SetPingHandler(func(string) error {
+		t.Error("no ping should be sent")
+		return nil
+	})
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("EOF")); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+		t.Fatalf("got %v, want a normal closure", err)
+	}
+}
+
+func TestRPCCallHandlerWebSocketOrigin(t *testing.T) {
+	cases := []struct {
+		name   string
+		origin string
+		status int
+	}{
+		{
+			name:   "no origin",
+			status: http.StatusSwitchingProtocols,
+		},
+		{
+			name:   "same origin",
+			origin: "self",
+			status: http.StatusSwitchingProtocols,
+		},
+		{
+			name:   "allowed origin",
+			origin: "https://app.example.com",
+			status: http.StatusSwitchingProtocols,
+		},
+		{
+			name:   "other origin",
+			origin: "https://evil.example.com",
+			status: http.StatusForbidden,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mc := &mockClient{isReady: true}
+			server := New(mc, zap.NewNop(), WithAllowedOrigins([]string{"https://app.example.com"}))
+			ts := httptest.NewServer(server.router)
+			defer ts.Close()
+
+			header := http.Header{}
+			if tc.origin == "self" {
+				header.Set("Origin", ts.URL)
+			} else if tc.origin != "" {
+				header.Set("Origin", tc.origin)
+			}
+			conn, resp, err := websocket.DefaultDialer.Dial(
+				"ws"+strings.TrimPrefix(ts.URL, "http")+"/v1/svc1/method1", header)
+			if conn != nil {
+				conn.Close()
+			}
+			if resp == nil {
+				t.Fatal(err)
+			}
+			if got, want := resp.StatusCode, tc.status; got != want {
+				t.Fatalf("got %d, want %d", got, want)
+			}
+		})
+	}
+}
@@ -1,11 +1,17 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	perrors "github.com/gdong42/grpc-mate/errors"
 	"github.com/gdong42/grpc-mate/http"
+	"github.com/gdong42/grpc-mate/metadata"
 	"github.com/gdong42/grpc-mate/proxy"
 	"go.uber.org/zap"
 
@@ -24,6 +30,61 @@ type EnvConfig struct {
 	GrpcServerPort int `envconfig:"GRPC_MATE_PROXIED_PORT" default:"9090"`
 	// LogLevel the log level, must be INFO, DEBUG, or ERROR, defaults to INFO
 	LogLevel string `envconfig:"GRPC_MATE_LOG_LEVEL" default:"INFO"`
+	// RESTConventions whether to derive RESTful routes from method names for methods without
+	// google.api.http options, defaults to false
+	RESTConventions bool `envconfig:"GRPC_MATE_REST_CONVENTIONS" default:"false"`
+	// SSEKeepAlive the idle interval after which a keepalive comment is sent in Server-Sent Events
+	// streams, 0 disabling them, defaults to 15s
+	SSEKeepAlive time.Duration `envconfig:"GRPC_MATE_SSE_KEEPALIVE" default:"15s"`
+	// WebSocketPingInterval the interval between pings sent to WebSocket clients, 0 disabling them,
+	// defaults to 30s
+	WebSocketPingInterval time.Duration `envconfig:"GRPC_MATE_WEBSOCKET_PING_INTERVAL" default:"30s"`
+	// TrailerHeaderPrefix the prefix of the response headers trailer metadata is returned as, rather
+	// than as HTTP trailers, defaults to none
+	TrailerHeaderPrefix string `envconfig:"GRPC_MATE_TRAILER_HEADER_PREFIX"`
+	// MetadataConfig the path of the JSON file configuring how HTTP headers are mapped to gRPC
+	// metadata and back, defaults to none, in which case only Grpc-Metadata- headers are mapped
+	MetadataConfig string `envconfig:"GRPC_MATE_METADATA_CONFIG"`
+	// DefaultTimeout the timeout of calls to methods without a timeout of their own, defaults to
+	// none
+	DefaultTimeout time.Duration `envconfig:"GRPC_MATE_DEFAULT_TIMEOUT" default:"0s"`
+	// MethodTimeouts the timeouts of calls to services and methods, e.g.
+	// helloworld.Greeter:5s,helloworld.Greeter/SayHello:1s, defaults to none
+	MethodTimeouts map[string]time.Duration `envconfig:"GRPC_MATE_METHOD_TIMEOUTS"`
+	// ProblemJSON whether to return errors as RFC 7807 application/problem+json documents, defaults
+	// to false
+	ProblemJSON bool `envconfig:"GRPC_MATE_PROBLEM_JSON" default:"false"`
+	// HTTPStatusCodes the path of the JSON file overriding the HTTP status codes gRPC status codes
+	// are mapped to, defaults to none
+	HTTPStatusCodes string `envconfig:"GRPC_MATE_HTTP_STATUS_CODES"`
+	// DescriptorCacheTTL the time service descriptors obtained through reflection are cached for, 0
+	// for ever, a negative value disabling the cache, defaults to 5m
+	DescriptorCacheTTL time.Duration `envconfig:"GRPC_MATE_DESCRIPTOR_CACHE_TTL" default:"5m"`
+	// ActuatorWritable whether to enable the actuator endpoints that change the state of grpc-mate,
+	// i.e. the invalidation of the descriptor cache, which are not authenticated, defaults to false
+	ActuatorWritable bool `envconfig:"GRPC_MATE_ACTUATOR_WRITABLE" default:"false"`
+	// SchemaRefreshInterval the interval between checks for upstream schema changes, which are
+	// also checked on reconnection, 0 disabling periodic checks, defaults to 1m
+	SchemaRefreshInterval time.Duration `envconfig:"GRPC_MATE_SCHEMA_REFRESH_INTERVAL" default:"1m"`
+	// Protosets the protoset files describing services, e.g. generated by protoc
+	// --descriptor_set_out, defaults to none
+	Protosets []string `envconfig:"GRPC_MATE_PROTOSETS"`
+	// ProtoDirs the directories of .proto files describing services, defaults to none
+	ProtoDirs []string `envconfig:"GRPC_MATE_PROTO_DIRS"`
+	// ProtoImportPaths the import paths of the .proto files besides their directories, defaults to
+	// none
+	ProtoImportPaths []string `envconfig:"GRPC_MATE_PROTO_IMPORT_PATHS"`
+	// Reflection whether to describe services by reflection, supplemented by protosets and .proto
+	// files if any, or by them only, defaults to true
+	Reflection bool `envconfig:"GRPC_MATE_REFLECTION" default:"true"`
+	// ExposurePolicy the path of the JSON file allowing and denying services and methods by glob
+	// patterns, reloaded on SIGHUP, defaults to none, in which case all of them are exposed
+	ExposurePolicy string `envconfig:"GRPC_MATE_EXPOSURE_POLICY"`
+	// GRPCWebMaxMessageSize the maximum size in bytes of gRPC-Web request messages, defaults to 4 MiB
+	GRPCWebMaxMessageSize int `envconfig:"GRPC_MATE_GRPC_WEB_MAX_MESSAGE_SIZE" default:"4194304"`
+	// AllowedOrigins the origins gRPC-Web calls are allowed from by CORS, and WebSocket calls
+	// besides the origin of the request host, * allowing any, defaults to none
+	AllowedOrigins []string `envconfig:"GRPC_MATE_ALLOWED_ORIGINS"`
 }
 
 func main() {
@@ -49,9 +110,69 @@ func main() {
 	}
 	defer conn.Close()
 
-	proxy := proxy.NewProxy(conn)
+	opts := []proxy.Option{
+		proxy.WithDescriptorCacheTTL(env.DescriptorCacheTTL),
+		proxy.WithLogger(logger),
+	}
+	if env.RESTConventions {
+		opts = append(opts, proxy.WithRouteMapper(http.RESTConventionMapper))
+	}
+	if len(env.Protosets) > 0 || len(env.ProtoDirs) > 0 {
+		source, err := proxy.LoadDescriptorSource(env.Protosets, env.ProtoDirs, env.ProtoImportPaths)
+		if err != nil {
+			logger.Fatal("Could not load descriptors", zap.Error(err))
+		}
+		if env.Reflection {
+			opts = append(opts, proxy.WithDescriptorFiles(source))
+		} else {
+			opts = append(opts, proxy.WithoutReflection(source))
+		}
+	} else if !env.Reflection {
+		logger.Fatal("Protosets or proto files are required without reflection")
+	}
+	if env.ExposurePolicy != "" {
+		policy, err := proxy.LoadPolicy(env.ExposurePolicy)
+		if err != nil {
+			logger.Fatal("Could not load exposure policy", zap.String("path", env.ExposurePolicy), zap.Error(err))
+		}
+		opts = append(opts, proxy.WithPolicy(policy))
+	}
+	proxy := proxy.NewProxy(conn, opts...)
+	go proxy.WatchSchema(context.Background(), env.SchemaRefreshInterval)
+	if env.ExposurePolicy != "" {
+		go reloadPolicy(proxy, env.ExposurePolicy, logger)
+	}
 
-	s := http.New(proxy, logger)
+	httpOpts := []http.Option{
+		http.WithSSEKeepAlive(env.SSEKeepAlive),
+		http.WithWebSocketPingInterval(env.WebSocketPingInterval),
+		http.WithTrailerHeaderPrefix(env.TrailerHeaderPrefix),
+		http.WithDefaultTimeout(env.DefaultTimeout),
+		http.WithMethodTimeouts(env.MethodTimeouts),
+		http.WithGRPCWebMaxMessageSize(env.GRPCWebMaxMessageSize),
+		http.WithAllowedOrigins(env.AllowedOrigins),
+	}
+	if env.MetadataConfig != "" {
+		c, err := metadata.LoadConfig(env.MetadataConfig)
+		if err != nil {
+			logger.Fatal("Could not load metadata config", zap.String("path", env.MetadataConfig), zap.Error(err))
+		}
+		httpOpts = append(httpOpts, http.WithMetadataConfig(c))
+	}
+	if env.ProblemJSON {
+		httpOpts = append(httpOpts, http.WithProblemJSON())
+	}
+	if env.ActuatorWritable {
+		httpOpts = append(httpOpts, http.WithWritableActuator())
+	}
+	if env.HTTPStatusCodes != "" {
+		codes, err := perrors.LoadHTTPStatusCodes(env.HTTPStatusCodes)
+		if err != nil {
+			logger.Fatal("Could not load HTTP status codes", zap.String("path", env.HTTPStatusCodes), zap.Error(err))
+		}
+		httpOpts = append(httpOpts, http.WithHTTPStatusCodes(codes))
+	}
+	s := http.New(proxy, logger, httpOpts...)
 	logger.Info("starting grpc-mate",
 		zap.String("log_level", env.LogLevel),
 		zap.Int("port", env.Port),
@@ -65,3 +186,19 @@ func main() {
 	}
 	s.Serve(ln)
 }
+
+// reloadPolicy reloads the exposure policy of the proxy from the file at path on every SIGHUP,
+// keeping the current one if the file is invalid
+func reloadPolicy(p *proxy.Proxy, path string, logger *zap.Logger) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		policy, err := proxy.LoadPolicy(path)
+		if err != nil {
+			logger.Error("Could not reload exposure policy", zap.String("path", path), zap.Error(err))
+			continue
+		}
+		p.SetPolicy(policy)
+		logger.Info("exposure policy reloaded", zap.String("path", path))
+	}
+}
@@ -0,0 +1,313 @@
+package metadata
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/textproto"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// hopByHopHeaders are never mapped, as they only concern a single HTTP connection
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// Rules are rules mapping HTTP headers to gRPC metadata, and back
+type Rules struct {
+	// Allow lists the headers mapped to metadata keys of the same name, e.g. Authorization to
+	// authorization
+	Allow []string `json:"allow"`
+	// Rename maps headers to the metadata keys they are mapped to, e.g. X-Forwarded-For to
+	// client-ip
+	Rename map[string]string `json:"rename"`
+	// Prefixes maps header name prefixes to the metadata key prefixes they are replaced with, e.g.
+	// X-Mate- to mate-. Grpc-Metadata- headers are always mapped without their prefix.
+	Prefixes map[string]string `json:"prefixes"`
+	// Defaults is static metadata sent upstream along with every call, unless mapped from headers.
+	// Binary values are base64 encoded, like in headers.
+	Defaults map[string][]string `json:"defaults"`
+	// Deny lists the headers and metadata keys never mapped, on top of hop-by-hop headers, whatever
+	// the other rules say
+	Deny []string `json:"deny"`
+}
+
+// Config is the configuration of the mapping between HTTP headers and gRPC metadata
+type Config struct {
+	Rules
+	// Routes maps services, e.g. helloworld.Greeter, and methods, e.g. helloworld.Greeter/SayHello,
+	// to rules that are merged into the default ones for their calls, those of a method after those
+	// of its service. Lists are appended, and entries of maps replace the ones with the same key.
+	Routes map[string]*Rules `json:"routes"`
+
+	mappers map[string]*Mapper
+}
+
+// LoadConfig reads the JSON configuration file at path
+func LoadConfig(path string) (*Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read metadata config")
+	}
+	var c Config
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, errors.Wrap(err, "failed to parse metadata config")
+	}
+	for _, r := range append([]*Rules{&c.Rules}, routeRules(c.Routes)...) {
+		for k, v := range r.Defaults {
+			if _, err := decodeBinary(strings.ToLower(k), v); err != nil {
+				return nil, errors.Wrap(err, "invalid default metadata")
+			}
+		}
+	}
+	c.init()
+	return &c, nil
+}
+
+func routeRules(routes map[string]*Rules) []*Rules {
+	rules := make([]*Rules, 0, len(routes))
+	for _, r := range routes {
+		if r != nil {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+func (c *Config) init() {
+	c.mappers = map[string]*Mapper{"": NewMapper(c.Rules)}
+	for k := range c.Routes {
+		service, method := k, ""
+		if i := strings.Index(k, "/"); i >= 0 {
+			service, method = k[:i], k[i+1:]
+		}
+		c.mappers[k] = NewMapper(c.rules(service, method))
+	}
+}
+
+// rules merges the rules of the service, then those of the method, into the default rules
+func (c *Config) rules(service, method string) Rules {
+	return c.Rules.merge(c.Routes[service]).merge(c.Routes[service+"/"+method])
+}
+
+// Mapper returns the mapper for calls of the method. A nil Config maps only Grpc-Metadata- headers.
+func (c *Config) Mapper(service, method string) *Mapper {
+	if c == nil {
+		return defaultMapper
+	}
+	key := ""
+	if _, ok := c.Routes[service+"/"+method]; ok {
+		key = service + "/" + method
+	} else if _, ok := c.Routes[service]; ok {
+		key = service
+	}
+	// mappers are built upfront by LoadConfig
+	if m, ok := c.mappers[key]; ok {
+		return m
+	}
+	return NewMapper(c.rules(service, method))
+}
+
+// merge returns the rules overridden by o
+func (r Rules) merge(o *Rules) Rules {
+	if o == nil {
+		return r
+	}
+	return Rules{
+		Allow:    append(append([]string{}, r.Allow...), o.Allow...),
+		Rename:   mergeMaps(r.Rename, o.Rename),
+		Prefixes: mergeMaps(r.Prefixes, o.Prefixes),
+		Defaults: mergeDefaults(r.Defaults, o.Defaults),
+		Deny:     append(append([]string{}, r.Deny...), o.Deny...),
+	}
+}
+
+func mergeMaps(a, b map[string]string) map[string]string {
+	m := make(map[string]string, len(a)+len(b))
+	for k, v := range a {
+		m[k] = v
+	}
+	for k, v := range b {
+		m[k] = v
+	}
+	return m
+}
+
+func mergeDefaults(a, b map[string][]string) map[string][]string {
+	m := make(map[string][]string, len(a)+len(b))
+	for k, v := range a {
+		m[k] = v
+	}
+	for k, v := range b {
+		m[k] = v
+	}
+	return m
+}
+
+// prefixRule maps headers starting with header to metadata keys starting with key
+type prefixRule struct {
+	header string
+	key    string
+}
+
+// Mapper maps HTTP headers to gRPC metadata, and back, following rules
+type Mapper struct {
+	allow map[string]bool
+	// rename maps canonical header names to metadata keys, and unrename the other way round
+	rename   map[string]string
+	unrename map[string]string
+	prefixes []prefixRule
+	defaults Metadata
+	// deny holds lowercase header names and metadata keys
+	deny map[string]bool
+}
+
+var defaultMapper = NewMapper(Rules{})
+
+// NewMapper creates a Mapper following the rules
+func NewMapper(r Rules) *Mapper {
+	m := &Mapper{
+		allow:    make(map[string]bool),
+		rename:   make(map[string]string),
+		unrename: make(map[string]string),
+		prefixes: []prefixRule{{header: metadataHeaderPrefix}},
+		defaults: make(Metadata),
+		deny:     make(map[string]bool),
+	}
+	for _, h := range r.Allow {
+		m.allow[strings.ToLower(h)] = true
+	}
+	for h, k := range r.Rename {
+		h, k = textproto.CanonicalMIMEHeaderKey(h), strings.ToLower(k)
+		m.rename[h] = k
+		m.unrename[k] = h
+	}
+	for h, k := range r.Prefixes {
+		m.prefixes = append(m.prefixes, prefixRule{
+			header: textproto.CanonicalMIMEHeaderKey(h),
+			key:    strings.ToLower(k),
+		})
+	}
+	for k, v := range r.Defaults {
+		k = strings.ToLower(k)
+		// invalid values are rejected by LoadConfig
+		if d, err := decodeBinary(k, v); err == nil {
+			m.defaults[k] = d
+		}
+	}
+	for _, h := range append(hopByHopHeaders, r.Deny...) {
+		m.deny[strings.ToLower(h)] = true
+	}
+	return m
+}
+
+// FromHeaders maps HTTP request headers to the metadata sent upstream. It fails if a binary
+// metadata value is not valid base64.
+func (m *Mapper) FromHeaders(headers map[string][]string) (Metadata, error) {
+	// headers listed by Connection are hop-by-hop as well
+	var connection map[string]bool
+	for _, v := range headers["Connection"] {
+		for _, h := range strings.Split(v, ",") {
+			if connection == nil {
+				connection = make(map[string]bool)
+			}
+			connection[strings.ToLower(strings.TrimSpace(h))] = true
+		}
+	}
+	md := make(Metadata, len(headers))
+	for h, v := range headers {
+		h = textproto.CanonicalMIMEHeaderKey(h)
+		if m.deny[strings.ToLower(h)] || connection[strings.ToLower(h)] {
+			continue
+		}
+		k := m.key(h)
+		if k == "" || m.deny[k] {
+			continue
+		}
+		v, err := decodeBinary(k, v)
+		if err != nil {
+			return nil, err
+		}
+		md[k] = append(md[k], v...)
+	}
+	for k, v := range m.defaults {
+		if _, ok := md[k]; !ok && !m.deny[k] {
+			md[k] = v
+		}
+	}
+	return md, nil
+}
+
+// key returns the metadata key a header is mapped to, or an empty string if it is not mapped
+func (m *Mapper) key(h string) string {
+	if k, ok := m.rename[h]; ok {
+		return k
+	}
+	if m.allow[strings.ToLower(h)] {
+		return strings.ToLower(h)
+	}
+	// the longest prefix wins
+	var rule *prefixRule
+	for i, p := range m.prefixes {
+		if len(h) > len(p.header) && strings.HasPrefix(h, p.header) &&
+			(rule == nil || len(p.header) > len(rule.header)) {
+			rule = &m.prefixes[i]
+		}
+	}
+	if rule == nil {
+		return ""
+	}
+	return rule.key + strings.ToLower(strings.TrimPrefix(h, rule.header))
+}
+
+// ToHeaders maps metadata received from upstream to HTTP response headers. Metadata that no rule
+// maps back is returned as Grpc-Metadata- headers.
+func (m *Mapper) ToHeaders(md Metadata) map[string][]string {
+	h := make(map[string][]string, len(md))
+	for k, v := range m.Filter(md) {
+		h[m.header(k)] = v
+	}
+	return h
+}
+
+// header returns the header a metadata key is mapped to
+func (m *Mapper) header(k string) string {
+	if h, ok := m.unrename[k]; ok {
+		return h
+	}
+	if m.allow[k] {
+		return textproto.CanonicalMIMEHeaderKey(k)
+	}
+	var rule *prefixRule
+	for i, p := range m.prefixes {
+		if p.key != "" && len(k) > len(p.key) && strings.HasPrefix(k, p.key) &&
+			(rule == nil || len(p.key) > len(rule.key)) {
+			rule = &m.prefixes[i]
+		}
+	}
+	if rule == nil {
+		return metadataHeaderPrefix + k
+	}
+	return textproto.CanonicalMIMEHeaderKey(rule.header + strings.TrimPrefix(k, rule.key))
+}
+
+// Filter removes the denied keys from the metadata, and base64 encodes binary values, for the
+// metadata to be sent as HTTP headers
+func (m *Mapper) Filter(md Metadata) Metadata {
+	filtered := make(Metadata, len(md))
+	for k, v := range md {
+		if !m.deny[k] {
+			filtered[k] = encodeBinary(k, v)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,192 @@
+package metadata
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var testRules = Rules{
+	Allow:    []string{"Authorization", "X-Request-Id", "Cookie"},
+	Rename:   map[string]string{"Accept-Language": "locale"},
+	Prefixes: map[string]string{"X-Mate-": "mate-"},
+	Defaults: map[string][]string{"origin": {"grpc-mate"}},
+	Deny:     []string{"Cookie"},
+}
+
+func TestMapper_FromHeaders(t *testing.T) {
+	cases := []struct {
+		name     string
+		rules    Rules
+		headers  map[string][]string
+		metadata Metadata
+	}{
+		{
+			name: "no rules",
+			headers: map[string][]string{
+				"Grpc-Metadata-Foo": {"hoge"},
+				"Authorization":     {"Bearer token"},
+			},
+			metadata: Metadata{"foo": {"hoge"}},
+		},
+		{
+			name:  "rules",
+			rules: testRules,
+			headers: map[string][]string{
+				"Grpc-Metadata-Foo": {"hoge"},
+				"Authorization":     {"Bearer token"},
+				"X-Request-Id":      {"1"},
+				"Accept-Language":   {"ja"},
+				"X-Mate-Tenant":     {"acme"},
+				"Cookie":            {"session=1"},
+				"User-Agent":        {"curl"},
+			},
+			metadata: Metadata{
+				"foo":           {"hoge"},
+				"authorization": {"Bearer token"},
+				"x-request-id":  {"1"},
+				"locale":        {"ja"},
+				"mate-tenant":   {"acme"},
+				"origin":        {"grpc-mate"},
+			},
+		},
+		{
+			name:  "defaults are overridden by headers",
+			rules: testRules,
+			headers: map[string][]string{
+				"Grpc-Metadata-Origin": {"browser"},
+			},
+			metadata: Metadata{"origin": {"browser"}},
+		},
+		{
+			name:  "hop-by-hop headers",
+			rules: Rules{Allow: []string{"Connection", "Upgrade", "X-Hop"}},
+			headers: map[string][]string{
+				"Connection": {"Upgrade, X-Hop"},
+				"Upgrade":    {"websocket"},
+				"X-Hop":      {"1"},
+			},
+			metadata: Metadata{},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			md, err := NewMapper(tc.rules).FromHeaders(tc.headers)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := md, tc.metadata; !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestMapper_ToHeaders(t *testing.T) {
+	cases := []struct {
+		name     string
+		rules    Rules
+		metadata Metadata
+		headers  map[string][]string
+	}{
+		{
+			name:     "no rules",
+			metadata: Metadata{"foo": {"hoge"}},
+			headers:  map[string][]string{"Grpc-Metadata-foo": {"hoge"}},
+		},
+		{
+			name:  "rules",
+			rules: testRules,
+			metadata: Metadata{
+				"foo":          {"hoge"},
+				"x-request-id": {"1"},
+				"locale":       {"ja"},
+				"mate-tenant":  {"acme"},
+				"cookie":       {"session=1"},
+			},
+			headers: map[string][]string{
+				"Grpc-Metadata-foo": {"hoge"},
+				"X-Request-Id":      {"1"},
+				"Accept-Language":   {"ja"},
+				"X-Mate-Tenant":     {"acme"},
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := NewMapper(tc.rules).ToHeaders(tc.metadata)
+			if got, want := h, tc.headers; !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metadata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "metadata.json")
+	err = ioutil.WriteFile(path, []byte(`{
+		"allow": ["Authorization"],
+		"routes": {
+			"helloworld.Greeter": {"allow": ["X-Request-Id"]},
+			"helloworld.Greeter/SayHello": {"deny": ["Authorization"]}
+		}
+	}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	headers := map[string][]string{
+		"Authorization": {"Bearer token"},
+		"X-Request-Id":  {"1"},
+	}
+	cases := []struct {
+		name     string
+		service  string
+		method   string
+		metadata Metadata
+	}{
+		{
+			name:     "default",
+			service:  "helloworld.Other",
+			method:   "SayHello",
+			metadata: Metadata{"authorization": {"Bearer token"}},
+		},
+		{
+			name:    "service",
+			service: "helloworld.Greeter",
+			method:  "SayGoodbye",
+			metadata: Metadata{
+				"authorization": {"Bearer token"},
+				"x-request-id":  {"1"},
+			},
+		},
+		{
+			name:     "method",
+			service:  "helloworld.Greeter",
+			method:   "SayHello",
+			metadata: Metadata{"x-request-id": {"1"}},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			md, err := c.Mapper(tc.service, tc.method).FromHeaders(headers)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := md, tc.metadata; !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -1,23 +1,37 @@
 package metadata
 
-import "strings"
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	perrors "github.com/gdong42/grpc-mate/errors"
+)
 
 // This is from an old grpc-gateway (https://github.com/grpc-ecosystem/grpc-gateway) specification
 const metadataHeaderPrefix = "Grpc-Metadata-"
 
+// binaryKeySuffix ends the keys of binary metadata, whose values are base64 encoded in HTTP headers
+const binaryKeySuffix = "-bin"
+
 // Metadata is gRPC metadata sent to and from upstream
 type Metadata map[string][]string
 
-// MetadataFromHeaders extracs headers and convert to Metadata
-func MetadataFromHeaders(raw map[string][]string) Metadata {
+// MetadataFromHeaders extracs headers and convert to Metadata. It fails if a binary metadata value
+// is not valid base64.
+func MetadataFromHeaders(raw map[string][]string) (Metadata, error) {
 	m := make(map[string][]string, len(raw))
 	for rawK, v := range raw {
 		if k := extractGrpcMetadataKey(rawK); k != "" {
 			k = strings.ToLower(k)
+			v, err := decodeBinary(k, v)
+			if err != nil {
+				return nil, err
+			}
 			m[k] = v
 		}
 	}
-	return m
+	return m, nil
 }
 
 func extractGrpcMetadataKey(rawKey string) string {
@@ -27,11 +41,62 @@ func extractGrpcMetadataKey(rawKey string) string {
 	return strings.TrimPrefix(rawKey, metadataHeaderPrefix)
 }
 
-// ToHeaders converts this Metadata to HTTP headers
+// ToHeaders converts this Metadata to HTTP headers, with binary values base64 encoded
 func (m Metadata) ToHeaders() map[string][]string {
 	h := make(map[string][]string, len(m))
 	for k, v := range m {
-		h[metadataHeaderPrefix+k] = v
+		h[metadataHeaderPrefix+k] = encodeBinary(k, v)
 	}
 	return h
 }
+
+// decodeBinary decodes the values of binary metadata, which are base64 encoded in either standard
+// or URL-safe encoding, with or without padding. A header may carry several comma separated values.
+func decodeBinary(key string, values []string) ([]string, error) {
+	if !strings.HasSuffix(key, binaryKeySuffix) {
+		return values, nil
+	}
+	decoded := make([]string, 0, len(values))
+	for _, v := range values {
+		for _, vv := range strings.Split(v, ",") {
+			b, err := decodeBase64(strings.TrimSpace(vv))
+			if err != nil {
+				return nil, &perrors.ProxyError{
+					Code:    perrors.InvalidMetadata,
+					Message: fmt.Sprintf("invalid metadata %s: invalid base64 value %q", key, vv),
+				}
+			}
+			decoded = append(decoded, string(b))
+		}
+	}
+	return decoded, nil
+}
+
+func decodeBase64(v string) ([]byte, error) {
+	encodings := []*base64.Encoding{
+		base64.StdEncoding,
+		base64.URLEncoding,
+		base64.RawStdEncoding,
+		base64.RawURLEncoding,
+	}
+	var err error
+	for _, enc := range encodings {
+		var b []byte
+		if b, err = enc.DecodeString(v); err == nil {
+			return b, nil
+		}
+	}
+	return nil, err
+}
+
+// encodeBinary encodes the values of binary metadata in standard base64
+func encodeBinary(key string, values []string) []string {
+	if !strings.HasSuffix(key, binaryKeySuffix) {
+		return values
+	}
+	encoded := make([]string, len(values))
+	for i, v := range values {
+		encoded[i] = base64.StdEncoding.EncodeToString([]byte(v))
+	}
+	return encoded
+}
@@ -1,8 +1,11 @@
 package metadata
 
 import (
+	"net/textproto"
 	"reflect"
 	"testing"
+
+	perrors "github.com/gdong42/grpc-mate/errors"
 )
 
 func TestMetadataFromHeaders(t *testing.T) {
@@ -10,6 +13,7 @@ func TestMetadataFromHeaders(t *testing.T) {
 		name     string
 		headers  map[string][]string
 		metadata Metadata
+		err      error
 	}{
 		{
 			name: "convert",
@@ -21,11 +25,41 @@ func TestMetadataFromHeaders(t *testing.T) {
 				"foo": {"hoge", "fuga"},
 			},
 		},
+		{
+			name: "binary",
+			headers: map[string][]string{
+				"Grpc-Metadata-Std-Bin":     {"AAH/+A=="},
+				"Grpc-Metadata-Url-Bin":     {"AAH_-A=="},
+				"Grpc-Metadata-Raw-Bin":     {"AAH/+A"},
+				"Grpc-Metadata-Raw-Url-Bin": {"AAH_-A"},
+				"Grpc-Metadata-Multi-Bin":   {"AA==, AQ==", "Ag=="},
+			},
+			metadata: Metadata{
+				"std-bin":     {"\x00\x01\xff\xf8"},
+				"url-bin":     {"\x00\x01\xff\xf8"},
+				"raw-bin":     {"\x00\x01\xff\xf8"},
+				"raw-url-bin": {"\x00\x01\xff\xf8"},
+				"multi-bin":   {"\x00", "\x01", "\x02"},
+			},
+		},
+		{
+			name: "invalid binary",
+			headers: map[string][]string{
+				"Grpc-Metadata-Token-Bin": {"not base64!"},
+			},
+			err: &perrors.ProxyError{
+				Code:    perrors.InvalidMetadata,
+				Message: `invalid metadata token-bin: invalid base64 value "not base64!"`,
+			},
+		},
 	}
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			m := MetadataFromHeaders(tc.headers)
+			m, err := MetadataFromHeaders(tc.headers)
+			if got, want := err, tc.err; !reflect.DeepEqual(got, want) {
+				t.Fatalf("got error %v, want %v", got, want)
+			}
 			if got, want := m, tc.metadata; !reflect.DeepEqual(got, want) {
 				t.Fatalf("got %v, want %v", got, want)
 			}
@@ -33,6 +67,53 @@ func TestMetadataFromHeaders(t *testing.T) {
 	}
 }
 
+func TestMetadata_RoundTrip(t *testing.T) {
+	cases := []struct {
+		name     string
+		metadata Metadata
+	}{
+		{
+			name:     "text",
+			metadata: Metadata{"foo": {"hoge", "fuga"}},
+		},
+		{
+			name: "binary",
+			metadata: Metadata{
+				"x-auth-context-bin": {"\x0a\x05token\x10\xff\x01"},
+				"empty-bin":          {""},
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := MetadataFromHeaders(canonical(tc.metadata.ToHeaders()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := m, tc.metadata; !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %q, want %q", got, want)
+			}
+
+			m, err = defaultMapper.FromHeaders(canonical(defaultMapper.ToHeaders(tc.metadata)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := m, tc.metadata; !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+// canonical canonicalizes header names, like HTTP does
+func canonical(h map[string][]string) map[string][]string {
+	c := make(map[string][]string, len(h))
+	for k, v := range h {
+		c[textproto.CanonicalMIMEHeaderKey(k)] = v
+	}
+	return c
+}
+
 func TestMetadata_ToHeaders(t *testing.T) {
 	cases := []struct {
 		name     string
@@ -48,6 +129,15 @@ func TestMetadata_ToHeaders(t *testing.T) {
 				"foo": {"hoge", "fuga"},
 			},
 		},
+		{
+			name: "binary",
+			headers: map[string][]string{
+				"Grpc-Metadata-token-bin": {"AAH/+A=="},
+			},
+			metadata: Metadata{
+				"token-bin": {"\x00\x01\xff\xf8"},
+			},
+		},
 	}
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -0,0 +1,60 @@
+package openapi
+
+import (
+	"github.com/jhump/protoreflect/desc"
+)
+
+const (
+	// JSONSchemaDialect is the JSON Schema draft JSON Schema documents follow
+	JSONSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+	defsPrefix = "#/$defs/"
+)
+
+// JSONSchema describes the message as a JSON Schema document, which refers to the schemas of the
+// message, and of the messages and enums it uses, held in $defs
+func JSONSchema(md *desc.MessageDescriptor) *Schema {
+	b := newSchemaBuilder(defsPrefix)
+	s := b.messageSchema(md)
+	s.SchemaURI = JSONSchemaDialect
+	s.Title = md.GetFullyQualifiedName()
+	if len(b.schemas) > 0 {
+		s.Defs = b.schemas
+	}
+	toJSONSchema(s)
+	return s
+}
+
+// toJSONSchema replaces the OpenAPI nullable keyword of the schema and its sub schemas with the
+// null type, which JSON Schema has instead
+func toJSONSchema(s *Schema) {
+	if s == nil {
+		return
+	}
+	if s.Nullable {
+		s.Nullable = false
+		if len(s.Enum) == 1 && s.Enum[0] == nil {
+			// google.protobuf.NullValue
+			*s = Schema{Type: "null"}
+		} else {
+			nonNull := *s
+			*s = Schema{AnyOf: []*Schema{&nonNull, {Type: "null"}}}
+		}
+	}
+	toJSONSchema(s.Items)
+	toJSONSchema(s.Not)
+	if sub, ok := s.AdditionalProperties.(*Schema); ok {
+		toJSONSchema(sub)
+	}
+	for _, p := range s.Properties {
+		toJSONSchema(p.Schema)
+	}
+	for _, subs := range [][]*Schema{s.AllOf, s.OneOf, s.AnyOf} {
+		for _, sub := range subs {
+			toJSONSchema(sub)
+		}
+	}
+	for _, d := range s.Defs {
+		toJSONSchema(d)
+	}
+}
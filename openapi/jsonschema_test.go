@@ -0,0 +1,88 @@
+package openapi
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jhump/protoreflect/desc/protoparse"
+)
+
+const fieldBehaviorProto = `syntax = "proto3";
+
+package google.api;
+
+import "google/protobuf/descriptor.proto";
+
+extend google.protobuf.FieldOptions {
+  repeated FieldBehavior field_behavior = 1052;
+}
+
+enum FieldBehavior {
+  FIELD_BEHAVIOR_UNSPECIFIED = 0;
+  OPTIONAL = 1;
+  REQUIRED = 2;
+  OUTPUT_ONLY = 3;
+  INPUT_ONLY = 4;
+  IMMUTABLE = 5;
+}
+`
+
+const treeProto = `syntax = "proto2";
+
+package tree;
+
+import "google/api/field_behavior.proto";
+import "google/protobuf/struct.proto";
+import "google/protobuf/wrappers.proto";
+
+message Node {
+  enum Color {
+    RED = 0;
+    BLACK = 1;
+  }
+  required string key = 1;
+  optional Color color = 2 [(google.api.field_behavior) = REQUIRED];
+  optional Node left = 3;
+  repeated Node children = 4;
+  optional google.protobuf.Int64Value weight = 5;
+  optional google.protobuf.NullValue nothing = 6;
+}
+`
+
+func TestJSONSchema(t *testing.T) {
+	p := protoparse.Parser{
+		Accessor: protoparse.FileContentsFromMap(map[string]string{
+			"google/api/field_behavior.proto": fieldBehaviorProto,
+			"tree.proto":                      treeProto,
+		}),
+	}
+	fds, err := p.ParseFiles("tree.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := JSONSchema(fds[0].FindMessage("tree.Node"))
+
+	want := `{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"$ref": "#/$defs/tree.Node",
+		"title": "tree.Node",
+		"$defs": {
+			"tree.Node": {
+				"type": "object",
+				"properties": {
+					"key": {"type": "string"},
+					"color": {"$ref": "#/$defs/tree.Node.Color"},
+					"left": {"$ref": "#/$defs/tree.Node"},
+					"children": {"type": "array", "items": {"$ref": "#/$defs/tree.Node"}},
+					"weight": {"anyOf": [{"type": "string", "format": "int64"}, {"type": "null"}]},
+					"nothing": {"type": "null"}
+				},
+				"required": ["key", "color"]
+			},
+			"tree.Node.Color": {"type": "string", "enum": ["RED", "BLACK"]}
+		}
+	}`
+	if got, want := roundTrip(t, s), unmarshal(t, want); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
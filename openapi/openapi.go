@@ -0,0 +1,347 @@
+// Package openapi generates OpenAPI 3 documents describing the HTTP API of upstream gRPC methods
+package openapi
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gdong42/grpc-mate/route"
+	dpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/jhump/protoreflect/desc"
+)
+
+const (
+	// Version is the version of the OpenAPI specification documents follow
+	Version = "3.0.3"
+	// DefaultRoutePrefix is the prefix of the default route of every method, e.g.
+	// /v1/helloworld.Greeter/SayHello
+	DefaultRoutePrefix = "/v1/"
+
+	refPrefix = "#/components/schemas/"
+	// errorSchema and problemSchema are the names of the schemas of error responses
+	errorSchema   = "grpc_mate.Error"
+	problemSchema = "grpc_mate.Problem"
+)
+
+// Document is an OpenAPI document
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       *Info               `json:"info"`
+	Tags       []*Tag              `json:"tags,omitempty"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components *Components         `json:"components,omitempty"`
+}
+
+// Info describes the API
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// Tag groups the operations of a service
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem holds the operations of a path by lower case HTTP method, e.g. get
+type PathItem map[string]*Operation
+
+// Operation describes the call of a method through a route
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Tags        []string             `json:"tags,omitempty"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Parameter is a path or query parameter bound into a field of the input message
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody describes the request body
+type RequestBody struct {
+	Content map[string]*MediaType `json:"content"`
+}
+
+// Response describes a response
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// MediaType describes the content of a body of a media type
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components holds the named schemas of messages, enums and errors
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+// Build describes the methods, each through its default route, and the routes of the table
+// mapped to it
+func Build(info *Info, methods []*desc.MethodDescriptor, table *route.Table) *Document {
+	b := newSchemaBuilder(refPrefix)
+	d := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   make(map[string]PathItem),
+	}
+	tagged := make(map[string]bool)
+	for _, md := range methods {
+		svc := md.GetService()
+		if !tagged[svc.GetFullyQualifiedName()] {
+			tagged[svc.GetFullyQualifiedName()] = true
+			d.Tags = append(d.Tags, &Tag{Name: svc.GetFullyQualifiedName(), Description: Comments(svc)})
+		}
+		// bidirectional streaming needs a full duplex connection, which HTTP requests are not
+		if md.IsClientStreaming() && md.IsServerStreaming() {
+			continue
+		}
+		id := svc.GetFullyQualifiedName() + "." + md.GetName()
+		d.addOperation(DefaultRoutePrefix+svc.GetFullyQualifiedName()+"/"+md.GetName(), http.MethodPost,
+			newOperation(b, md, id, "*", ""))
+		n := 0
+		for _, r := range table.Routes() {
+			if r.Service != svc.GetFullyQualifiedName() || r.Method != md.GetName() {
+				continue
+			}
+			n++
+			op := newOperation(b, md, id+"_"+strconv.Itoa(n), r.Body, r.ResponseBody)
+			op.Parameters = append(pathParameters(b, md.GetInputType(), r.Template), op.Parameters...)
+			if r.Body != "*" {
+				op.Parameters = append(op.Parameters, queryParameters(b, md.GetInputType(), r)...)
+			}
+			d.addOperation(r.Template.OpenAPIPath(), r.HTTPMethod, op)
+		}
+	}
+	b.schemas[errorSchema] = &Schema{
+		Type:        "object",
+		Description: "An error, returned by the upstream with a gRPC status code, or by the proxy with an HTTP one",
+		Properties: Properties{
+			{Name: "code", Schema: &Schema{Type: "integer", Format: "int32", Description: "gRPC status code"}},
+			{Name: "status", Schema: &Schema{Type: "integer", Format: "int32", Description: "HTTP status code"}},
+			{Name: "message", Schema: &Schema{Type: "string"}},
+			{Name: "details", Schema: &Schema{Type: "array", Items: wellKnownSchemas["google.protobuf.Any"]()}},
+		},
+	}
+	b.schemas[problemSchema] = &Schema{
+		Type:        "object",
+		Description: "An RFC 7807 problem details document",
+		Properties: Properties{
+			{Name: "type", Schema: &Schema{Type: "string"}},
+			{Name: "title", Schema: &Schema{Type: "string"}},
+			{Name: "status", Schema: &Schema{Type: "integer", Format: "int32"}},
+			{Name: "detail", Schema: &Schema{Type: "string"}},
+			{Name: "grpc_code", Schema: &Schema{Type: "integer", Format: "int32"}},
+			{Name: "details", Schema: &Schema{Type: "array", Items: wellKnownSchemas["google.protobuf.Any"]()}},
+		},
+	}
+	d.Components = &Components{Schemas: b.schemas}
+	return d
+}
+
+// addOperation adds the operation, unless the path already has one for the HTTP method, which
+// takes precedence like earlier routes do, or the HTTP method is a custom one
+func (d *Document) addOperation(path, httpMethod string, op *Operation) {
+	switch httpMethod {
+	case http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete, http.MethodPatch,
+		http.MethodHead, http.MethodOptions, http.MethodTrace:
+	default:
+		return
+	}
+	item, ok := d.Paths[path]
+	if !ok {
+		item = make(PathItem)
+		d.Paths[path] = item
+	}
+	m := strings.ToLower(httpMethod)
+	if _, ok := item[m]; !ok {
+		item[m] = op
+	}
+}
+
+// newOperation describes the call of the method with the request body bound to the body field, "*"
+// for the whole input message, and the response body made of the response body field, or the
+// whole output message if empty
+func newOperation(b *schemaBuilder, md *desc.MethodDescriptor, id, body, responseBody string) *Operation {
+	op := &Operation{
+		OperationID: id,
+		Summary:     md.GetService().GetName() + "." + md.GetName(),
+		Description: Comments(md),
+		Tags:        []string{md.GetService().GetFullyQualifiedName()},
+		Responses: map[string]*Response{
+			"default": {
+				Description: "Error",
+				Content: map[string]*MediaType{
+					"application/json":         {Schema: &Schema{Ref: refPrefix + errorSchema}},
+					"application/problem+json": {Schema: &Schema{Ref: refPrefix + problemSchema}},
+				},
+			},
+		},
+	}
+	if body != "" {
+		input := b.messageSchema(md.GetInputType())
+		if body != "*" {
+			if fd := findField(md.GetInputType(), body); fd != nil {
+				input = b.fieldSchema(fd)
+			}
+		}
+		content := map[string]*MediaType{
+			"application/json": {Schema: input},
+		}
+		// the messages of client streaming calls are sent as a JSON array or newline-delimited JSON
+		if md.IsClientStreaming() {
+			content = map[string]*MediaType{
+				"application/json":     {Schema: &Schema{Type: "array", Items: input}},
+				"application/x-ndjson": {Schema: input},
+			}
+		}
+		op.RequestBody = &RequestBody{Content: content}
+	}
+
+	output := b.messageSchema(md.GetOutputType())
+	if responseBody != "" {
+		if fd := findField(md.GetOutputType(), responseBody); fd != nil {
+			output = b.fieldSchema(fd)
+		}
+	}
+	content := map[string]*MediaType{
+		"application/json": {Schema: output},
+	}
+	// the messages of server streaming calls are received as newline-delimited JSON, or as
+	// Server-Sent Events
+	if md.IsServerStreaming() {
+		content = map[string]*MediaType{
+			"application/x-ndjson": {Schema: output},
+			"text/event-stream":    {Schema: &Schema{Type: "string"}},
+		}
+	}
+	op.Responses["200"] = &Response{
+		Description: "OK",
+		Content:     content,
+	}
+	return op
+}
+
+// pathParameters describes the variables of the template
+func pathParameters(b *schemaBuilder, md *desc.MessageDescriptor, tmpl *route.Template) []*Parameter {
+	var params []*Parameter
+	for _, v := range tmpl.Variables() {
+		p := &Parameter{
+			Name:     v,
+			In:       "path",
+			Required: true,
+			Schema:   &Schema{Type: "string"},
+		}
+		if fd := findField(md, v); fd != nil {
+			p.Description = Comments(fd)
+			p.Schema = b.fieldSchema(fd)
+		}
+		params = append(params, p)
+	}
+	return params
+}
+
+// queryParameters describes the fields of the input message which can be bound from query
+// parameters, i.e. the scalar ones and those of sub messages, but the fields bound from the path or
+// the body
+func queryParameters(b *schemaBuilder, md *desc.MessageDescriptor, r *route.Route) []*Parameter {
+	bound := append(r.Template.Variables(), r.Body)
+	var params []*Parameter
+	var add func(md *desc.MessageDescriptor, prefix string, visited map[string]bool)
+	add = func(md *desc.MessageDescriptor, prefix string, visited map[string]bool) {
+		if visited[md.GetFullyQualifiedName()] {
+			return
+		}
+		visited[md.GetFullyQualifiedName()] = true
+		defer delete(visited, md.GetFullyQualifiedName())
+		for _, fd := range md.GetFields() {
+			name := prefix + fd.GetName()
+			if isBound(name, bound) || fd.IsMap() {
+				continue
+			}
+			mt := fd.GetMessageType()
+			if mt != nil && !isParameterType(mt) {
+				if !fd.IsRepeated() {
+					add(mt, name+".", visited)
+				}
+				continue
+			}
+			params = append(params, &Parameter{
+				Name:        name,
+				In:          "query",
+				Description: Comments(fd),
+				Schema:      b.fieldSchema(fd),
+			})
+		}
+	}
+	add(md, "", make(map[string]bool))
+	return params
+}
+
+// isParameterType tells if the message is a well-known type bound from parameters by its JSON
+// representation
+func isParameterType(md *desc.MessageDescriptor) bool {
+	switch md.GetFullyQualifiedName() {
+	case "google.protobuf.Timestamp", "google.protobuf.Duration", "google.protobuf.FieldMask",
+		"google.protobuf.DoubleValue", "google.protobuf.FloatValue", "google.protobuf.Int64Value",
+		"google.protobuf.UInt64Value", "google.protobuf.Int32Value", "google.protobuf.UInt32Value",
+		"google.protobuf.BoolValue", "google.protobuf.StringValue", "google.protobuf.BytesValue":
+		return true
+	}
+	return false
+}
+
+// isBound tells if the field path is, or is a sub field of, one of the bound field paths
+func isBound(fieldPath string, bound []string) bool {
+	for _, b := range bound {
+		if b != "" && (fieldPath == b || strings.HasPrefix(fieldPath, b+".")) {
+			return true
+		}
+	}
+	return false
+}
+
+// findField finds the field denoted by a dot separated field path of proto or JSON field names
+func findField(md *desc.MessageDescriptor, fieldPath string) *desc.FieldDescriptor {
+	names := strings.Split(fieldPath, ".")
+	for i, name := range names {
+		fd := md.FindFieldByName(name)
+		if fd == nil {
+			fd = findFieldByJSONName(md, name)
+		}
+		if fd == nil {
+			return nil
+		}
+		if i == len(names)-1 {
+			return fd
+		}
+		if fd.GetType() != dpb.FieldDescriptorProto_TYPE_MESSAGE || fd.IsRepeated() {
+			return nil
+		}
+		md = fd.GetMessageType()
+	}
+	return nil
+}
+
+func findFieldByJSONName(md *desc.MessageDescriptor, name string) *desc.FieldDescriptor {
+	for _, fd := range md.GetFields() {
+		if fd.GetJSONName() == name {
+			return fd
+		}
+	}
+	return nil
+}
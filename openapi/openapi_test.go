@@ -0,0 +1,260 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/gdong42/grpc-mate/route"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+)
+
+const shopProto = `syntax = "proto3";
+
+package shop;
+
+import "google/protobuf/timestamp.proto";
+import "google/protobuf/wrappers.proto";
+
+// Shop sells items
+service Shop {
+  // GetItem gets an item
+  rpc GetItem(GetItemRequest) returns (Item);
+  rpc ListItems(ListItemsRequest) returns (stream Item);
+  rpc UpdateItem(UpdateItemRequest) returns (Item);
+  rpc Chat(stream Item) returns (stream Item);
+}
+
+// Item is an item for sale
+message Item {
+  enum Kind {
+    KIND_UNSPECIFIED = 0;
+    BOOK = 1;
+  }
+  // name is the name of the item
+  string name = 1;
+  int64 price = 2;
+  Kind kind = 3;
+  map<string, int32> stock = 4;
+  google.protobuf.Timestamp created = 5;
+  google.protobuf.StringValue note = 6;
+  oneof discount {
+    int32 percent = 7;
+    string code = 8;
+  }
+  repeated Item related = 9;
+  bytes image = 10;
+}
+
+message GetItemRequest {
+  string name = 1;
+}
+
+message ListItemsRequest {
+  message Filter {
+    Item.Kind kind = 1;
+    Filter and = 2;
+  }
+  string shop = 1;
+  Filter filter = 2;
+  repeated string tags = 3;
+}
+
+message UpdateItemRequest {
+  Item item = 1;
+}
+`
+
+func parseShop(t *testing.T) *desc.FileDescriptor {
+	t.Helper()
+	p := protoparse.Parser{
+		Accessor:              protoparse.FileContentsFromMap(map[string]string{"shop.proto": shopProto}),
+		IncludeSourceCodeInfo: true,
+	}
+	fds, err := p.ParseFiles("shop.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fds[0]
+}
+
+func newRoute(t *testing.T, httpMethod, tmpl, method, body string) *route.Route {
+	t.Helper()
+	tp, err := route.ParseTemplate(tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &route.Route{
+		HTTPMethod: httpMethod,
+		Template:   tp,
+		Service:    "shop.Shop",
+		Method:     method,
+		Body:       body,
+	}
+}
+
+// roundTrip marshals and unmarshals the value, to compare it with JSON literals
+func roundTrip(t *testing.T, v interface{}) interface{} {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out interface{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func unmarshal(t *testing.T, s string) interface{} {
+	t.Helper()
+	var out interface{}
+	if err := json.Unmarshal([]byte(s), &out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestBuild(t *testing.T) {
+	fd := parseShop(t)
+	svc := fd.FindService("shop.Shop")
+	table := route.NewTable([]*route.Route{
+		newRoute(t, http.MethodGet, "/v1/{name=items/*}", "GetItem", ""),
+		newRoute(t, http.MethodGet, "/v1/shops/{shop}/items", "ListItems", ""),
+		newRoute(t, http.MethodPatch, "/v1/{item.name=items/*}", "UpdateItem", "item"),
+		newRoute(t, "ARCHIVE", "/v1/{name=items/*}:archive", "GetItem", "*"),
+	})
+	d := Build(&Info{Title: "shop", Version: "1"}, svc.GetMethods(), table)
+
+	var paths []string
+	for p := range d.Paths {
+		paths = append(paths, p)
+	}
+	wantPaths := map[string][]string{
+		"/v1/shop.Shop/GetItem":    {"post"},
+		"/v1/shop.Shop/ListItems":  {"post"},
+		"/v1/shop.Shop/UpdateItem": {"post"},
+		"/v1/{name}":               {"get"},
+		"/v1/shops/{shop}/items":   {"get"},
+		"/v1/{item.name}":          {"patch"},
+	}
+	if got, want := len(d.Paths), len(wantPaths); got != want {
+		t.Fatalf("got paths %v, want %d", paths, want)
+	}
+	for p, methods := range wantPaths {
+		for _, m := range methods {
+			if d.Paths[p][m] == nil {
+				t.Errorf("got no %s operation of %s", m, p)
+			}
+		}
+	}
+	if got, want := d.Tags, []*Tag{{Name: "shop.Shop", Description: "Shop sells items"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got tags %v, want %v", got, want)
+	}
+
+	cases := []struct {
+		name string
+		got  interface{}
+		want string
+	}{
+		{
+			name: "message",
+			got:  d.Components.Schemas["shop.Item"],
+			want: `{
+				"type": "object",
+				"description": "Item is an item for sale",
+				"properties": {
+					"name": {"type": "string", "description": "name is the name of the item"},
+					"price": {"type": "string", "format": "int64"},
+					"kind": {"$ref": "#/components/schemas/shop.Item.Kind"},
+					"stock": {"type": "object", "additionalProperties": {"type": "integer", "format": "int32"}},
+					"created": {"type": "string", "format": "date-time"},
+					"note": {"type": "string", "nullable": true},
+					"percent": {"type": "integer", "format": "int32"},
+					"code": {"type": "string"},
+					"related": {"type": "array", "items": {"$ref": "#/components/schemas/shop.Item"}},
+					"image": {"type": "string", "format": "byte"}
+				},
+				"oneOf": [
+					{"required": ["percent"]},
+					{"required": ["code"]},
+					{"not": {"anyOf": [{"required": ["percent"]}, {"required": ["code"]}]}}
+				]
+			}`,
+		},
+		{
+			name: "enum",
+			got:  d.Components.Schemas["shop.Item.Kind"],
+			want: `{"type": "string", "enum": ["KIND_UNSPECIFIED", "BOOK"]}`,
+		},
+		{
+			name: "default route",
+			got:  d.Paths["/v1/shop.Shop/GetItem"]["post"],
+			want: `{
+				"operationId": "shop.Shop.GetItem",
+				"summary": "Shop.GetItem",
+				"description": "GetItem gets an item",
+				"tags": ["shop.Shop"],
+				"requestBody": {"content": {"application/json": {"schema": {"$ref": "#/components/schemas/shop.GetItemRequest"}}}},
+				"responses": {
+					"200": {"description": "OK", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/shop.Item"}}}},
+					"default": {"description": "Error", "content": {
+						"application/json": {"schema": {"$ref": "#/components/schemas/grpc_mate.Error"}},
+						"application/problem+json": {"schema": {"$ref": "#/components/schemas/grpc_mate.Problem"}}
+					}}
+				}
+			}`,
+		},
+		{
+			name: "path and query parameters of a server streaming method",
+			got:  d.Paths["/v1/shops/{shop}/items"]["get"].Parameters,
+			want: `[
+				{"name": "shop", "in": "path", "required": true, "schema": {"type": "string"}},
+				{"name": "filter.kind", "in": "query", "schema": {"$ref": "#/components/schemas/shop.Item.Kind"}},
+				{"name": "tags", "in": "query", "schema": {"type": "array", "items": {"type": "string"}}}
+			]`,
+		},
+		{
+			name: "server streaming response",
+			got:  d.Paths["/v1/shops/{shop}/items"]["get"].Responses["200"],
+			want: `{"description": "OK", "content": {
+				"application/x-ndjson": {"schema": {"$ref": "#/components/schemas/shop.Item"}},
+				"text/event-stream": {"schema": {"type": "string"}}
+			}}`,
+		},
+		{
+			name: "body field",
+			got:  d.Paths["/v1/{item.name}"]["patch"].RequestBody,
+			want: `{"content": {"application/json": {"schema": {"$ref": "#/components/schemas/shop.Item"}}}}`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got, want := roundTrip(t, tc.got), unmarshal(t, tc.want); !reflect.DeepEqual(got, want) {
+				b, _ := json.Marshal(tc.got)
+				t.Fatalf("got %s, want %s", b, tc.want)
+			}
+		})
+	}
+}
+
+func TestJSONToYAML(t *testing.T) {
+	got, err := JSONToYAML([]byte(`{"openapi":"3.0.3","paths":{"/v1/{name}":{"get":{"operationId":"a"}}},"info":{"version":"1"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `openapi: 3.0.3
+paths:
+  /v1/{name}:
+    get:
+      operationId: a
+info:
+  version: "1"
+`
+	if string(got) != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,305 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	dpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/jhump/protoreflect/desc"
+	"google.golang.org/genproto/googleapis/api/annotations"
+)
+
+// Schema is an OpenAPI schema object, a subset of JSON Schema, or a JSON Schema document
+type Schema struct {
+	SchemaURI            string        `json:"$schema,omitempty"`
+	Ref                  string        `json:"$ref,omitempty"`
+	Title                string        `json:"title,omitempty"`
+	Type                 string        `json:"type,omitempty"`
+	Format               string        `json:"format,omitempty"`
+	Pattern              string        `json:"pattern,omitempty"`
+	Description          string        `json:"description,omitempty"`
+	Nullable             bool          `json:"nullable,omitempty"`
+	Enum                 []interface{} `json:"enum,omitempty"`
+	Items                *Schema       `json:"items,omitempty"`
+	Properties           Properties    `json:"properties,omitempty"`
+	AdditionalProperties interface{}   `json:"additionalProperties,omitempty"`
+	Required             []string      `json:"required,omitempty"`
+	AllOf                []*Schema     `json:"allOf,omitempty"`
+	OneOf                []*Schema     `json:"oneOf,omitempty"`
+	AnyOf                []*Schema     `json:"anyOf,omitempty"`
+	Not                  *Schema       `json:"not,omitempty"`
+	// Defs holds the named schemas of JSON Schema documents
+	Defs map[string]*Schema `json:"$defs,omitempty"`
+}
+
+// Property is a named property of an object schema
+type Property struct {
+	Name   string
+	Schema *Schema
+}
+
+// Properties are the properties of an object schema, which are marshaled into a JSON object in
+// order, e.g. the order fields are declared in
+type Properties []*Property
+
+// MarshalJSON marshals the properties into a JSON object keyed by name
+func (p Properties) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, prop := range p {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		name, err := json.Marshal(prop.Name)
+		if err != nil {
+			return nil, err
+		}
+		s, err := json.Marshal(prop.Schema)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(name)
+		b.WriteByte(':')
+		b.Write(s)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// UnmarshalJSON unmarshals the properties from a JSON object, in order
+func (p *Properties) UnmarshalJSON(b []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if t, err := dec.Token(); err != nil {
+		return err
+	} else if t != json.Delim('{') {
+		return fmt.Errorf("properties must be an object")
+	}
+	props := Properties{}
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		prop := &Property{Name: t.(string)}
+		if err := dec.Decode(&prop.Schema); err != nil {
+			return err
+		}
+		props = append(props, prop)
+	}
+	*p = props
+	return nil
+}
+
+// wellKnownSchemas describes the well-known types by their JSON representation, rather than as the
+// messages they are
+var wellKnownSchemas = map[string]func() *Schema{
+	"google.protobuf.Timestamp": func() *Schema { return &Schema{Type: "string", Format: "date-time"} },
+	"google.protobuf.Duration": func() *Schema {
+		return &Schema{Type: "string", Pattern: `^-?[0-9]+(\.[0-9]{1,9})?s$`}
+	},
+	"google.protobuf.FieldMask":   func() *Schema { return &Schema{Type: "string"} },
+	"google.protobuf.Struct":      func() *Schema { return &Schema{Type: "object", AdditionalProperties: true} },
+	"google.protobuf.Value":       func() *Schema { return &Schema{} },
+	"google.protobuf.ListValue":   func() *Schema { return &Schema{Type: "array", Items: &Schema{}} },
+	"google.protobuf.DoubleValue": func() *Schema { return &Schema{Type: "number", Format: "double", Nullable: true} },
+	"google.protobuf.FloatValue":  func() *Schema { return &Schema{Type: "number", Format: "float", Nullable: true} },
+	"google.protobuf.Int64Value":  func() *Schema { return &Schema{Type: "string", Format: "int64", Nullable: true} },
+	"google.protobuf.UInt64Value": func() *Schema { return &Schema{Type: "string", Format: "uint64", Nullable: true} },
+	"google.protobuf.Int32Value":  func() *Schema { return &Schema{Type: "integer", Format: "int32", Nullable: true} },
+	"google.protobuf.UInt32Value": func() *Schema { return &Schema{Type: "integer", Format: "uint32", Nullable: true} },
+	"google.protobuf.BoolValue":   func() *Schema { return &Schema{Type: "boolean", Nullable: true} },
+	"google.protobuf.StringValue": func() *Schema { return &Schema{Type: "string", Nullable: true} },
+	"google.protobuf.BytesValue":  func() *Schema { return &Schema{Type: "string", Format: "byte", Nullable: true} },
+	"google.protobuf.Any": func() *Schema {
+		return &Schema{
+			Type:                 "object",
+			Properties:           Properties{{Name: "@type", Schema: &Schema{Type: "string"}}},
+			AdditionalProperties: true,
+		}
+	},
+}
+
+// schemaBuilder derives schemas from message and enum descriptors, following the proto3 JSON
+// mapping. Messages and enums are described once as named schemas, which others refer to.
+type schemaBuilder struct {
+	refPrefix string
+	schemas   map[string]*Schema
+}
+
+func newSchemaBuilder(refPrefix string) *schemaBuilder {
+	return &schemaBuilder{
+		refPrefix: refPrefix,
+		schemas:   make(map[string]*Schema),
+	}
+}
+
+// messageSchema returns the schema of the well-known type, or a reference to the named schema of
+// the message, which is described on first use
+func (b *schemaBuilder) messageSchema(md *desc.MessageDescriptor) *Schema {
+	name := md.GetFullyQualifiedName()
+	if wk, ok := wellKnownSchemas[name]; ok {
+		return wk()
+	}
+	if _, ok := b.schemas[name]; !ok {
+		s := &Schema{
+			Type:        "object",
+			Description: Comments(md),
+		}
+		// added before its fields are described, for recursive messages to refer to it
+		b.schemas[name] = s
+		for _, fd := range md.GetFields() {
+			s.Properties = append(s.Properties, &Property{
+				Name:   fd.GetJSONName(),
+				Schema: b.describedField(fd),
+			})
+			if isRequired(fd) {
+				s.Required = append(s.Required, fd.GetJSONName())
+			}
+		}
+		for _, od := range md.GetOneOfs() {
+			s.AllOf = append(s.AllOf, oneOfSchema(od))
+		}
+		// a single oneof needs no allOf
+		if len(s.AllOf) == 1 {
+			s.OneOf, s.AllOf = s.AllOf[0].OneOf, nil
+		}
+	}
+	return &Schema{Ref: b.refPrefix + name}
+}
+
+// oneOfSchema requires at most one field of the oneof to be set
+func oneOfSchema(od *desc.OneOfDescriptor) *Schema {
+	s := &Schema{}
+	none := &Schema{}
+	for _, fd := range od.GetChoices() {
+		required := &Schema{Required: []string{fd.GetJSONName()}}
+		s.OneOf = append(s.OneOf, required)
+		none.AnyOf = append(none.AnyOf, &Schema{Required: []string{fd.GetJSONName()}})
+	}
+	s.OneOf = append(s.OneOf, &Schema{Not: none})
+	return s
+}
+
+// enumSchema returns a reference to the named schema of the enum, which is described on first use
+func (b *schemaBuilder) enumSchema(ed *desc.EnumDescriptor) *Schema {
+	name := ed.GetFullyQualifiedName()
+	if name == "google.protobuf.NullValue" {
+		return &Schema{Nullable: true, Enum: []interface{}{nil}}
+	}
+	if _, ok := b.schemas[name]; !ok {
+		s := &Schema{
+			Type:        "string",
+			Description: Comments(ed),
+		}
+		for _, v := range ed.GetValues() {
+			s.Enum = append(s.Enum, v.GetName())
+		}
+		b.schemas[name] = s
+	}
+	return &Schema{Ref: b.refPrefix + name}
+}
+
+// describedField returns the schema of the field along with its comments, which are put aside a
+// reference to the schema of its type, as siblings of $ref are ignored
+func (b *schemaBuilder) describedField(fd *desc.FieldDescriptor) *Schema {
+	s := b.fieldSchema(fd)
+	c := Comments(fd)
+	if c == "" {
+		return s
+	}
+	if s.Ref != "" {
+		return &Schema{AllOf: []*Schema{s}, Description: c}
+	}
+	s.Description = c
+	return s
+}
+
+// fieldSchema returns the schema of the value of the field
+func (b *schemaBuilder) fieldSchema(fd *desc.FieldDescriptor) *Schema {
+	if fd.IsMap() {
+		return &Schema{
+			Type:                 "object",
+			AdditionalProperties: b.valueSchema(fd.GetMapValueType()),
+		}
+	}
+	s := b.valueSchema(fd)
+	if fd.IsRepeated() {
+		return &Schema{Type: "array", Items: s}
+	}
+	return s
+}
+
+// valueSchema returns the schema of a single value of the field
+func (b *schemaBuilder) valueSchema(fd *desc.FieldDescriptor) *Schema {
+	switch fd.GetType() {
+	case dpb.FieldDescriptorProto_TYPE_MESSAGE, dpb.FieldDescriptorProto_TYPE_GROUP:
+		return b.messageSchema(fd.GetMessageType())
+	case dpb.FieldDescriptorProto_TYPE_ENUM:
+		return b.enumSchema(fd.GetEnumType())
+	case dpb.FieldDescriptorProto_TYPE_DOUBLE:
+		return &Schema{Type: "number", Format: "double"}
+	case dpb.FieldDescriptorProto_TYPE_FLOAT:
+		return &Schema{Type: "number", Format: "float"}
+	case dpb.FieldDescriptorProto_TYPE_INT32, dpb.FieldDescriptorProto_TYPE_SINT32,
+		dpb.FieldDescriptorProto_TYPE_SFIXED32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case dpb.FieldDescriptorProto_TYPE_UINT32, dpb.FieldDescriptorProto_TYPE_FIXED32:
+		return &Schema{Type: "integer", Format: "uint32"}
+	// 64-bit integers are strings in JSON, as they do not fit into a number
+	case dpb.FieldDescriptorProto_TYPE_INT64, dpb.FieldDescriptorProto_TYPE_SINT64,
+		dpb.FieldDescriptorProto_TYPE_SFIXED64:
+		return &Schema{Type: "string", Format: "int64"}
+	case dpb.FieldDescriptorProto_TYPE_UINT64, dpb.FieldDescriptorProto_TYPE_FIXED64:
+		return &Schema{Type: "string", Format: "uint64"}
+	case dpb.FieldDescriptorProto_TYPE_BOOL:
+		return &Schema{Type: "boolean"}
+	case dpb.FieldDescriptorProto_TYPE_BYTES:
+		return &Schema{Type: "string", Format: "byte"}
+	default:
+		return &Schema{Type: "string"}
+	}
+}
+
+// isRequired tells if the field is a proto2 required one, or is annotated with the REQUIRED
+// google.api.field_behavior
+func isRequired(fd *desc.FieldDescriptor) bool {
+	if fd.IsRequired() {
+		return true
+	}
+	opts := fd.GetFieldOptions()
+	if opts == nil || !proto.HasExtension(opts, annotations.E_FieldBehavior) {
+		return false
+	}
+	ext, err := proto.GetExtension(opts, annotations.E_FieldBehavior)
+	if err != nil {
+		return false
+	}
+	behaviors, _ := ext.([]annotations.FieldBehavior)
+	for _, b := range behaviors {
+		if b == annotations.FieldBehavior_REQUIRED {
+			return true
+		}
+	}
+	return false
+}
+
+// Comments returns the leading comments of the element, or its trailing ones, when its file has
+// source info, which files obtained by reflection usually do not
+func Comments(d desc.Descriptor) string {
+	loc := d.GetSourceInfo()
+	if loc == nil {
+		return ""
+	}
+	c := loc.GetLeadingComments()
+	if strings.TrimSpace(c) == "" {
+		c = loc.GetTrailingComments()
+	}
+	lines := strings.Split(strings.TrimSpace(c), "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimSpace(l)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,21 @@
+package openapi
+
+import (
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// JSONToYAML converts a JSON document, e.g. a marshaled Document, into YAML, keeping the order of
+// object keys
+func JSONToYAML(b []byte) ([]byte, error) {
+	// JSON is YAML, which is unmarshaled into ordered maps
+	var doc yaml.MapSlice
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, errors.Wrap(err, "failed to parse JSON")
+	}
+	y, err := yaml.Marshal(doc)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal YAML")
+	}
+	return y, nil
+}
@@ -0,0 +1,212 @@
+package proxy
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fullstorydev/grpcurl"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/pkg/errors"
+)
+
+// serviceResolver resolves and lists the upstream services, like grpcreflect.Client does
+type serviceResolver interface {
+	ResolveService(serviceName string) (*desc.ServiceDescriptor, error)
+	ListServices() ([]string, error)
+}
+
+// LoadDescriptorSource loads the descriptors of the protoset files, which hold FileDescriptorSet
+// protos, and of the .proto files found in the proto directories, which are parsed with the
+// directories and the import paths as import paths
+func LoadDescriptorSource(protosets, protoDirs, importPaths []string) (grpcurl.DescriptorSource, error) {
+	var sources descriptorSources
+	if len(protosets) > 0 {
+		s, err := grpcurl.DescriptorSourceFromProtoSets(protosets...)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, s)
+	}
+	if len(protoDirs) > 0 {
+		var files []string
+		for _, dir := range protoDirs {
+			f, err := protoFiles(dir)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, f...)
+		}
+		p := protoparse.Parser{
+			ImportPaths: append(append([]string{}, protoDirs...), importPaths...),
+			// comments describe the methods and types in the OpenAPI document
+			IncludeSourceCodeInfo: true,
+		}
+		fds, err := p.ParseFiles(files...)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not parse proto files")
+		}
+		s, err := grpcurl.DescriptorSourceFromFileDescriptors(fds...)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, s)
+	}
+	if len(sources) == 1 {
+		return sources[0], nil
+	}
+	return sources, nil
+}
+
+// protoFiles finds the .proto files in the directory and its sub directories, by their path
+// relative to the directory
+func protoFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, ".proto") {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not find proto files in "+dir)
+	}
+	return files, nil
+}
+
+// descriptorSources finds descriptors in the first of its sources that has them
+type descriptorSources []grpcurl.DescriptorSource
+
+func (s descriptorSources) ListServices() ([]string, error) {
+	var (
+		services []string
+		lastErr  error
+		listed   bool
+	)
+	seen := make(map[string]bool)
+	for _, source := range s {
+		names, err := source.ListServices()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		listed = true
+		for _, name := range names {
+			if !seen[name] {
+				seen[name] = true
+				services = append(services, name)
+			}
+		}
+	}
+	if !listed {
+		return nil, lastErr
+	}
+	sort.Strings(services)
+	return services, nil
+}
+
+func (s descriptorSources) FindSymbol(fullyQualifiedName string) (desc.Descriptor, error) {
+	var lastErr error
+	for _, source := range s {
+		d, err := source.FindSymbol(fullyQualifiedName)
+		if err == nil {
+			return d, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+func (s descriptorSources) AllExtensionsForType(typeName string) ([]*desc.FieldDescriptor, error) {
+	var exts []*desc.FieldDescriptor
+	seen := make(map[string]bool)
+	for _, source := range s {
+		fds, err := source.AllExtensionsForType(typeName)
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			if !seen[fd.GetFullyQualifiedName()] {
+				seen[fd.GetFullyQualifiedName()] = true
+				exts = append(exts, fd)
+			}
+		}
+	}
+	return exts, nil
+}
+
+// sourceResolver resolves services from a descriptor source
+type sourceResolver struct {
+	source grpcurl.DescriptorSource
+}
+
+func (r *sourceResolver) ResolveService(serviceName string) (*desc.ServiceDescriptor, error) {
+	d, err := r.source.FindSymbol(serviceName)
+	if err != nil {
+		return nil, err
+	}
+	sd, ok := d.(*desc.ServiceDescriptor)
+	if !ok {
+		return nil, errors.Errorf("%s is not a service", serviceName)
+	}
+	return sd, nil
+}
+
+func (r *sourceResolver) ListServices() ([]string, error) {
+	services, err := r.source.ListServices()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(services)
+	return services, nil
+}
+
+// fallbackResolver resolves services by reflection, and from descriptor files when reflection does
+// not know them, or is not implemented by the upstream
+type fallbackResolver struct {
+	reflection serviceResolver
+	files      serviceResolver
+}
+
+func (r *fallbackResolver) ResolveService(serviceName string) (*desc.ServiceDescriptor, error) {
+	sd, err := r.reflection.ResolveService(serviceName)
+	if err == nil {
+		return sd, nil
+	}
+	if sd, ferr := r.files.ResolveService(serviceName); ferr == nil {
+		return sd, nil
+	}
+	return nil, err
+}
+
+func (r *fallbackResolver) ListServices() ([]string, error) {
+	services, err := r.reflection.ListServices()
+	files, ferr := r.files.ListServices()
+	if ferr != nil {
+		return services, err
+	}
+	if err != nil {
+		return files, nil
+	}
+	seen := make(map[string]bool, len(services))
+	for _, name := range services {
+		seen[name] = true
+	}
+	for _, name := range files {
+		if !seen[name] {
+			services = append(services, name)
+		}
+	}
+	sort.Strings(services)
+	return services, nil
+}
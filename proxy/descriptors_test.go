@@ -0,0 +1,157 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/fullstorydev/grpcurl"
+	"github.com/gdong42/grpc-mate/metadata"
+	"github.com/gdong42/grpc-mate/proxy/test"
+	"github.com/golang/protobuf/proto"
+	dpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+func TestLoadDescriptorSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "descriptors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name string, b []byte) {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fd := test.NewFileDescriptor(t, test.File)
+	set := &dpb.FileDescriptorSet{}
+	for _, dep := range fd.GetDependencies() {
+		set.File = append(set.File, dep.AsFileDescriptorProto())
+	}
+	set.File = append(set.File, fd.AsFileDescriptorProto())
+	b, err := proto.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write("test.protoset", b)
+	write("protos/hello/greeter.proto", []byte(`syntax = "proto3";
+package hello;
+import "common/messages.proto";
+service Greeter {
+  rpc SayHello (common.HelloRequest) returns (common.HelloReply);
+}`))
+	write("imports/common/messages.proto", []byte(`syntax = "proto3";
+package common;
+message HelloRequest { string name = 1; }
+message HelloReply { string message = 1; }`))
+
+	cases := []struct {
+		name        string
+		protosets   []string
+		protoDirs   []string
+		importPaths []string
+		services    []string
+		invalid     bool
+	}{
+		{
+			name:      "protoset",
+			protosets: []string{filepath.Join(dir, "test.protoset")},
+			services:  []string{test.TestService},
+		},
+		{
+			name:        "proto files",
+			protoDirs:   []string{filepath.Join(dir, "protos")},
+			importPaths: []string{filepath.Join(dir, "imports")},
+			services:    []string{"hello.Greeter"},
+		},
+		{
+			name:        "both",
+			protosets:   []string{filepath.Join(dir, "test.protoset")},
+			protoDirs:   []string{filepath.Join(dir, "protos")},
+			importPaths: []string{filepath.Join(dir, "imports")},
+			services:    []string{test.TestService, "hello.Greeter"},
+		},
+		{
+			name:      "missing import",
+			protoDirs: []string{filepath.Join(dir, "protos")},
+			invalid:   true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			source, err := LoadDescriptorSource(tc.protosets, tc.protoDirs, tc.importPaths)
+			if got, want := err != nil, tc.invalid; got != want {
+				t.Fatalf("got error %v, want error %t", err, want)
+			}
+			if tc.invalid {
+				return
+			}
+			services, err := (&sourceResolver{source: source}).ListServices()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := services, tc.services; !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestDescriptorFiles(t *testing.T) {
+	files, err := grpcurl.DescriptorSourceFromFileDescriptors(test.NewFileDescriptor(t, test.File))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name       string
+		reflection test.Reflection
+		opt        Option
+	}{
+		{
+			name: "offline",
+			opt:  WithoutReflection(files),
+		},
+		{
+			name: "files supplementing missing reflection",
+			opt:  WithDescriptorFiles(files),
+		},
+		{
+			name:       "files supplementing reflection",
+			reflection: test.ReflectionV1Alpha,
+			opt:        WithDescriptorFiles(files),
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cc, stop := test.StartTestServerWithReflection(t, tc.reflection)
+			defer stop()
+			p := NewProxy(cc, tc.opt)
+
+			var header, trailer metadata.Metadata
+			if _, err := p.Invoke(context.Background(), test.TestService, test.EmptyCall, []byte("{}"), nil,
+				&header, &trailer); err != nil {
+				t.Fatal(err)
+			}
+			b, err := p.Introspect()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var r IntrospectionResponse
+			if err := json.Unmarshal(b, &r); err != nil {
+				t.Fatal(err)
+			}
+			if len(r.Services) == 0 || len(r.Types) == 0 {
+				t.Fatalf("got %s, want services and types", b)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package proxy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fullstorydev/grpcurl"
+	perrors "github.com/gdong42/grpc-mate/errors"
+	"github.com/golang/protobuf/proto"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/pkg/errors"
+)
+
+// resolveDetails makes the details of gRPC errors resolvable from the upstream descriptors, so that
+// details of types defined by the upstream are rendered as JSON too
+func (p *Proxy) resolveDetails(err error) error {
+	if e, ok := errors.Cause(err).(*perrors.GRPCError); ok && len(e.Details) > 0 {
+		if _, descSource := p.descriptors(); descSource != nil {
+			e.Resolver = &detailResolver{source: descSource}
+		}
+	}
+	return err
+}
+
+// detailResolver resolves the types of error details from a descriptor source
+type detailResolver struct {
+	source grpcurl.DescriptorSource
+}
+
+func (r *detailResolver) Resolve(typeURL string) (proto.Message, error) {
+	name := typeURL[strings.LastIndex(typeURL, "/")+1:]
+	d, err := r.source.FindSymbol(name)
+	if err != nil {
+		return nil, err
+	}
+	md, ok := d.(*desc.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a message type", name)
+	}
+	return dynamic.NewMessage(md), nil
+}
@@ -0,0 +1,40 @@
+package proxy
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/fullstorydev/grpcurl"
+	perrors "github.com/gdong42/grpc-mate/errors"
+	"github.com/gdong42/grpc-mate/proxy/test"
+	"github.com/golang/protobuf/proto"
+	any "github.com/golang/protobuf/ptypes/any"
+	"google.golang.org/grpc/test/grpc_testing"
+)
+
+func TestResolveDetails(t *testing.T) {
+	fd := test.NewFileDescriptor(t, test.File)
+	source, err := grpcurl.DescriptorSourceFromFileDescriptors(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Proxy{descSource: source}
+
+	payload, err := proto.Marshal(&grpc_testing.Payload{Body: []byte("hello")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &perrors.GRPCError{
+		StatusCode: 3,
+		Message:    "invalid",
+		Details:    []*any.Any{{TypeUrl: "type.googleapis.com/grpc.testing.Payload", Value: payload}},
+	}
+	b, err := json.Marshal(p.resolveDetails(e))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"code":3,"message":"invalid","details":[{"@type":"type.googleapis.com/grpc.testing.Payload","body":"aGVsbG8="}]}`
+	if got := string(b); got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
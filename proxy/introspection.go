@@ -0,0 +1,275 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	perrors "github.com/gdong42/grpc-mate/errors"
+	"github.com/gdong42/grpc-mate/openapi"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/pkg/errors"
+)
+
+// introspection is the rendered introspection of the exposed services, which is cached until the
+// upstream schema, the descriptors or the policy change
+type introspection struct {
+	// hash identifies the descriptors of the exposed methods
+	hash string
+	all  []byte
+	// services holds the introspection of every service, restricted to the types it uses
+	services map[string][]byte
+	// types holds the type and enum elements by name
+	types map[string][]byte
+}
+
+// Introspect performs instrospection on this gRPC server, and obtains all services and methods
+// information
+func (p *Proxy) Introspect() ([]byte, error) {
+	in, err := p.introspect()
+	if err != nil {
+		return nil, err
+	}
+	return in.all, nil
+}
+
+// IntrospectService performs introspection of the service, like Introspect but for its methods and
+// the types they use only
+func (p *Proxy) IntrospectService(serviceName string) ([]byte, error) {
+	in, err := p.introspect()
+	if err != nil {
+		return nil, err
+	}
+	b, ok := in.services[serviceName]
+	if !ok {
+		return nil, &perrors.ProxyError{
+			Code:    perrors.ServiceUnresolvable,
+			Message: fmt.Sprintf("the service %s was not found", serviceName),
+		}
+	}
+	return b, nil
+}
+
+// IntrospectType returns the element of the message or enum type, used by the exposed methods,
+// like those listed by Introspect
+func (p *Proxy) IntrospectType(typeName string) ([]byte, error) {
+	in, err := p.introspect()
+	if err != nil {
+		return nil, err
+	}
+	b, ok := in.types[typeName]
+	if !ok {
+		return nil, &perrors.ProxyError{
+			Code:    perrors.TypeNotFound,
+			Message: fmt.Sprintf("the type %s was not found", typeName),
+		}
+	}
+	return b, nil
+}
+
+// DescriptorHash returns a hash of the descriptors of the exposed methods, which changes whenever
+// the introspection does, e.g. for HTTP entity tags
+func (p *Proxy) DescriptorHash() (string, error) {
+	in, err := p.introspect()
+	if err != nil {
+		return "", err
+	}
+	return in.hash, nil
+}
+
+// introspect returns the cached introspection, or builds it on first use, after the upstream
+// schema, the descriptors or the policy changed
+func (p *Proxy) introspect() (*introspection, error) {
+	if !p.IsReady() {
+		return nil, &perrors.ProxyError{
+			Code:    perrors.UpstreamConnFailure,
+			Message: "service down",
+		}
+	}
+	p.mu.RLock()
+	in, reflector, policy := p.introspection, p.reflector, p.policy
+	p.mu.RUnlock()
+	if in != nil {
+		return in, nil
+	}
+	in, err := p.buildIntrospection()
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// the introspection is kept unless the schema or the policy changed while it was built
+	if p.introspection == nil && p.reflector == reflector && p.policy == policy {
+		p.introspection = in
+	}
+	return in, nil
+}
+
+// buildIntrospection describes the exposed services, their methods and the types they use, and
+// renders them all at once
+func (p *Proxy) buildIntrospection() (*introspection, error) {
+	reflector, descSource := p.descriptors()
+	s, err := reflector.ListServices()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list services")
+	}
+	table, err := p.Routes()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build routes")
+	}
+	var reflectionService string
+	if !p.offline {
+		reflectionService = p.reflectStub.Service()
+	}
+	ses := make([]*serviceElement, 0, len(s))
+	// typeDscs holds the exposed message types, along with the types of their fields, by name, and
+	// svcTypes those of every service
+	typeDscs := make(map[string]*desc.MessageDescriptor)
+	svcTypes := make(map[string]map[string]*desc.MessageDescriptor)
+	files := make(map[string]*desc.FileDescriptor)
+	var exposed []string
+	hasPolicy := p.getPolicy() != nil
+	for _, svc := range s {
+		mds, err := reflector.DescribeService(svc)
+		if err != nil {
+			return nil, err
+		}
+		// services whose methods are all hidden by the policy are hidden too
+		if len(mds) == 0 && hasPolicy {
+			continue
+		}
+		types := make(map[string]*desc.MessageDescriptor)
+		methods := make([]*methodElement, len(mds))
+		for j, m := range mds {
+			methods[j] = resolveMethodElement(svc, m, table)
+			addTypes(types, m.AsProtoreflectDescriptor().GetInputType())
+			addTypes(types, m.AsProtoreflectDescriptor().GetOutputType())
+			exposed = append(exposed, svc+"/"+m.GetName())
+		}
+		se := &serviceElement{
+			Name:    svc,
+			Methods: methods,
+		}
+		if len(mds) > 0 {
+			sd := mds[0].AsProtoreflectDescriptor().GetService()
+			se.File = sd.GetFile().GetName()
+			se.Package = sd.GetFile().GetPackage()
+			se.Comments = openapi.Comments(sd)
+			se.Deprecated = sd.GetServiceOptions().GetDeprecated()
+			addFile(files, sd.GetFile())
+		}
+		ses = append(ses, se)
+		svcTypes[svc] = types
+		for k, md := range types {
+			typeDscs[k] = md
+		}
+	}
+
+	in := &introspection{
+		services: make(map[string][]byte, len(ses)),
+		types:    make(map[string][]byte),
+	}
+	if in.hash, err = descriptorHash(files, exposed, reflectionService); err != nil {
+		return nil, err
+	}
+	typeElems := make(map[string]*typeElement, len(typeDscs))
+	enumElems := make(map[string]*enumElement)
+	for k, md := range typeDscs {
+		te, err := resolveTypeElement(k, md, descSource)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to resolve type "+k)
+		}
+		typeElems[k] = te
+		for _, fd := range md.GetFields() {
+			if ed := fd.GetEnumType(); ed != nil && enumElems[ed.GetFullyQualifiedName()] == nil {
+				enumElems[ed.GetFullyQualifiedName()] = resolveEnumElement(ed)
+			}
+		}
+	}
+	// render describes the services along with the types, and their enums, sorted by name
+	render := func(services []*serviceElement, types map[string]*desc.MessageDescriptor) ([]byte, error) {
+		r := &IntrospectionResponse{
+			Reflection: reflectionService,
+			Services:   services,
+			Types:      make([]*typeElement, 0, len(types)),
+			Enums:      []*enumElement{},
+		}
+		enums := make(map[string]bool)
+		for _, k := range sortedKeys(types) {
+			r.Types = append(r.Types, typeElems[k])
+			for _, fd := range types[k].GetFields() {
+				if ed := fd.GetEnumType(); ed != nil {
+					enums[ed.GetFullyQualifiedName()] = true
+				}
+			}
+		}
+		names := make([]string, 0, len(enums))
+		for k := range enums {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		for _, k := range names {
+			r.Enums = append(r.Enums, enumElems[k])
+		}
+		js, err := json.Marshal(r)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to marshal output JSON")
+		}
+		return js, nil
+	}
+	if in.all, err = render(ses, typeDscs); err != nil {
+		return nil, err
+	}
+	for _, se := range ses {
+		if in.services[se.Name], err = render([]*serviceElement{se}, svcTypes[se.Name]); err != nil {
+			return nil, err
+		}
+	}
+	for k, te := range typeElems {
+		if in.types[k], err = json.Marshal(te); err != nil {
+			return nil, errors.Wrap(err, "failed to marshal output JSON")
+		}
+	}
+	for k, ee := range enumElems {
+		if in.types[k], err = json.Marshal(ee); err != nil {
+			return nil, errors.Wrap(err, "failed to marshal output JSON")
+		}
+	}
+	return in, nil
+}
+
+// descriptorHash hashes the files describing the exposed services, the full names of the exposed
+// methods, as the policy may hide some of them, and the reflection service
+func descriptorHash(files map[string]*desc.FileDescriptor, methods []string, reflectionService string) (string, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	// sorted for the hash not to depend on the order services are listed in
+	sort.Strings(names)
+	sort.Strings(methods)
+	h := sha256.New()
+	for _, name := range names {
+		fp, err := fingerprint(files[name].AsFileDescriptorProto())
+		if err != nil {
+			return "", err
+		}
+		h.Write([]byte(fp))
+	}
+	for _, m := range methods {
+		h.Write([]byte(m + "\n"))
+	}
+	h.Write([]byte(reflectionService))
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func sortedKeys(types map[string]*desc.MessageDescriptor) []string {
+	keys := make([]string, 0, len(types))
+	for k := range types {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,136 @@
+package proxy
+
+import (
+	"encoding/json"
+	"testing"
+
+	perrors "github.com/gdong42/grpc-mate/errors"
+	"github.com/gdong42/grpc-mate/proxy/test"
+	"github.com/pkg/errors"
+)
+
+func TestIntrospectServiceAndType(t *testing.T) {
+	cc, stop := test.StartTestServer(t)
+	defer stop()
+	p := NewProxy(cc)
+
+	cases := []struct {
+		name       string
+		introspect func() ([]byte, error)
+		code       perrors.Code
+		want       func(t *testing.T, b []byte)
+	}{
+		{
+			name:       "service",
+			introspect: func() ([]byte, error) { return p.IntrospectService(test.TestService) },
+			want: func(t *testing.T, b []byte) {
+				var r IntrospectionResponse
+				if err := json.Unmarshal(b, &r); err != nil {
+					t.Fatal(err)
+				}
+				if len(r.Services) != 1 || r.Services[0].Name != test.TestService {
+					t.Fatalf("got services %s, want %s only", b, test.TestService)
+				}
+				types := make(map[string]bool)
+				for _, te := range r.Types {
+					types[te.Name] = true
+				}
+				if !types[test.UnaryCallInputMsgName] || !types[test.MessageName] {
+					t.Errorf("got types %s, want %s and %s", b, test.UnaryCallInputMsgName, test.MessageName)
+				}
+				if len(r.Enums) != 1 || r.Enums[0].Name != "grpc.testing.PayloadType" {
+					t.Errorf("got enums %s, want grpc.testing.PayloadType", b)
+				}
+			},
+		},
+		{
+			name:       "unknown service",
+			introspect: func() ([]byte, error) { return p.IntrospectService(test.NotFoundService) },
+			code:       perrors.ServiceUnresolvable,
+		},
+		{
+			name:       "message type",
+			introspect: func() ([]byte, error) { return p.IntrospectType(test.MessageName) },
+			want: func(t *testing.T, b []byte) {
+				var te typeElement
+				if err := json.Unmarshal(b, &te); err != nil {
+					t.Fatal(err)
+				}
+				if te.Name != test.MessageName || len(te.Fields) == 0 || te.Template == nil {
+					t.Errorf("got %s, want the fields and template of %s", b, test.MessageName)
+				}
+			},
+		},
+		{
+			name:       "enum type",
+			introspect: func() ([]byte, error) { return p.IntrospectType("grpc.testing.PayloadType") },
+			want: func(t *testing.T, b []byte) {
+				var ee enumElement
+				if err := json.Unmarshal(b, &ee); err != nil {
+					t.Fatal(err)
+				}
+				if len(ee.Values) == 0 || ee.Values[0].Name != "COMPRESSABLE" {
+					t.Errorf("got %s, want the values of grpc.testing.PayloadType", b)
+				}
+			},
+		},
+		{
+			name:       "unknown type",
+			introspect: func() ([]byte, error) { return p.IntrospectType("grpc.testing.Unknown") },
+			code:       perrors.TypeNotFound,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := tc.introspect()
+			if tc.want == nil {
+				if e, ok := errors.Cause(err).(*perrors.ProxyError); !ok || e.Code != tc.code {
+					t.Fatalf("got error %v, want code %d", err, tc.code)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tc.want(t, b)
+		})
+	}
+}
+
+func TestIntrospectionCache(t *testing.T) {
+	cc, stop := test.StartTestServer(t)
+	defer stop()
+	p := NewProxy(cc)
+
+	introspect := func() ([]byte, string) {
+		b, err := p.Introspect()
+		if err != nil {
+			t.Fatal(err)
+		}
+		hash, err := p.DescriptorHash()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b, hash
+	}
+
+	b, hash := introspect()
+	cached, cachedHash := introspect()
+	if &cached[0] != &b[0] || cachedHash != hash {
+		t.Fatalf("got introspection rebuilt, want it cached")
+	}
+
+	p.InvalidateDescriptors()
+	rebuilt, rebuiltHash := introspect()
+	if &rebuilt[0] == &b[0] {
+		t.Fatalf("got cached introspection, want it rebuilt once descriptors are invalidated")
+	}
+	if rebuiltHash != hash || string(rebuilt) != string(b) {
+		t.Errorf("got hash %s, want the same hash %s for the same descriptors", rebuiltHash, hash)
+	}
+
+	p.SetPolicy(&Policy{Deny: []string{test.TestService + "/" + test.UnaryCall}})
+	if _, denyHash := introspect(); denyHash == hash {
+		t.Errorf("got hash %s, want another one once the policy hides a method", denyHash)
+	}
+}
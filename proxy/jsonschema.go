@@ -0,0 +1,72 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+
+	perrors "github.com/gdong42/grpc-mate/errors"
+	"github.com/gdong42/grpc-mate/openapi"
+	"github.com/gdong42/grpc-mate/proxy/reflection"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/pkg/errors"
+)
+
+// JSONSchema describes the message type, used by the exposed methods, as a JSON Schema document
+func (p *Proxy) JSONSchema(typeName string) ([]byte, error) {
+	if !p.IsReady() {
+		return nil, &perrors.ProxyError{
+			Code:    perrors.UpstreamConnFailure,
+			Message: "service down",
+		}
+	}
+	types, err := exposedTypes(p.getReflector())
+	if err != nil {
+		return nil, err
+	}
+	md, ok := types[typeName]
+	if !ok {
+		return nil, &perrors.ProxyError{
+			Code:    perrors.TypeNotFound,
+			Message: fmt.Sprintf("the message type %s was not found", typeName),
+		}
+	}
+	js, err := json.Marshal(openapi.JSONSchema(md))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal output JSON")
+	}
+	return js, nil
+}
+
+// exposedTypes finds the input and output types of the methods the reflector exposes, along with
+// the message types of their fields, by name, so that types of hidden methods are not described
+func exposedTypes(r reflection.Reflector) (map[string]*desc.MessageDescriptor, error) {
+	services, err := r.ListServices()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list services")
+	}
+	types := make(map[string]*desc.MessageDescriptor)
+	for _, svc := range services {
+		mds, err := r.DescribeService(svc)
+		if err != nil {
+			return nil, err
+		}
+		for _, md := range mds {
+			addTypes(types, md.AsProtoreflectDescriptor().GetInputType())
+			addTypes(types, md.AsProtoreflectDescriptor().GetOutputType())
+		}
+	}
+	return types, nil
+}
+
+// addTypes adds the message type, and the message types of its fields, to types by name
+func addTypes(types map[string]*desc.MessageDescriptor, md *desc.MessageDescriptor) {
+	if _, ok := types[md.GetFullyQualifiedName()]; ok {
+		return
+	}
+	types[md.GetFullyQualifiedName()] = md
+	for _, fd := range md.GetFields() {
+		if mt := fd.GetMessageType(); mt != nil {
+			addTypes(types, mt)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package proxy
+
+import (
+	"encoding/json"
+	"testing"
+
+	perrors "github.com/gdong42/grpc-mate/errors"
+	"github.com/gdong42/grpc-mate/proxy/test"
+	"github.com/pkg/errors"
+)
+
+func TestJSONSchema(t *testing.T) {
+	cc, stop := test.StartTestServer(t)
+	defer stop()
+
+	cases := []struct {
+		name     string
+		policy   *Policy
+		typeName string
+		found    bool
+	}{
+		{
+			name:     "input type",
+			typeName: test.UnaryCallInputMsgName,
+			found:    true,
+		},
+		{
+			name:     "type of a field",
+			typeName: test.MessageName,
+			found:    true,
+		},
+		{
+			name:     "type of a hidden method",
+			policy:   &Policy{Deny: []string{test.TestService + "/" + test.UnaryCall}},
+			typeName: test.UnaryCallInputMsgName,
+		},
+		{
+			name:     "unknown type",
+			typeName: "grpc.testing.Unknown",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := NewProxy(cc, WithPolicy(tc.policy))
+			b, err := p.JSONSchema(tc.typeName)
+			if !tc.found {
+				if e, ok := errors.Cause(err).(*perrors.ProxyError); !ok || e.Code != perrors.TypeNotFound {
+					t.Fatalf("got error %v, want type not found", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var s struct {
+				Ref  string                     `json:"$ref"`
+				Defs map[string]json.RawMessage `json:"$defs"`
+			}
+			if err := json.Unmarshal(b, &s); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := s.Ref, "#/$defs/"+tc.typeName; got != want {
+				t.Errorf("got $ref %s, want %s", got, want)
+			}
+			if _, ok := s.Defs[tc.typeName]; !ok {
+				t.Errorf("got $defs %s, want %s", b, tc.typeName)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package proxy
+
+import (
+	"encoding/json"
+
+	perrors "github.com/gdong42/grpc-mate/errors"
+	"github.com/gdong42/grpc-mate/openapi"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/pkg/errors"
+)
+
+// OpenAPI describes the exposed methods and their routes as an OpenAPI 3 document in JSON, versioned
+// by the hash of the upstream schema once loaded
+func (p *Proxy) OpenAPI() ([]byte, error) {
+	if !p.IsReady() {
+		return nil, &perrors.ProxyError{
+			Code:    perrors.UpstreamConnFailure,
+			Message: "service down",
+		}
+	}
+	reflector, _ := p.descriptors()
+	services, err := reflector.ListServices()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list services")
+	}
+	table, err := p.Routes()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build routes")
+	}
+	var methods []*desc.MethodDescriptor
+	for _, svc := range services {
+		mds, err := reflector.DescribeService(svc)
+		if err != nil {
+			return nil, err
+		}
+		for _, md := range mds {
+			methods = append(methods, md.AsProtoreflectDescriptor())
+		}
+	}
+	info := &openapi.Info{
+		Title:   "grpc-mate",
+		Version: "latest",
+	}
+	p.mu.RLock()
+	if p.schema != nil {
+		info.Version = p.schema.hash[:12]
+	}
+	p.mu.RUnlock()
+	js, err := json.Marshal(openapi.Build(info, methods, table))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal output JSON")
+	}
+	return js, nil
+}
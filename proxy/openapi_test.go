@@ -0,0 +1,39 @@
+package proxy
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/gdong42/grpc-mate/openapi"
+	"github.com/gdong42/grpc-mate/proxy/test"
+)
+
+func TestOpenAPI(t *testing.T) {
+	cc, stop := test.StartTestServer(t)
+	defer stop()
+	p := NewProxy(cc, WithPolicy(&Policy{Deny: []string{"grpc.reflection.*"}}))
+
+	b, err := p.OpenAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var d openapi.Document
+	if err := json.Unmarshal(b, &d); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := d.Paths["/v1/"+test.TestService+"/"+test.EmptyCall]; !ok {
+		t.Errorf("got paths %v, want the default route of %s", d.Paths, test.EmptyCall)
+	}
+	// bidirectional streaming methods cannot be called by HTTP requests
+	if _, ok := d.Paths["/v1/"+test.TestService+"/"+test.FullDuplexCall]; ok {
+		t.Errorf("got the default route of %s, want none", test.FullDuplexCall)
+	}
+	for path := range d.Paths {
+		if path == "/v1/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo" {
+			t.Errorf("got %s, want it hidden by the policy", path)
+		}
+	}
+	if _, ok := d.Components.Schemas[test.UnaryCallInputMsgName]; !ok {
+		t.Errorf("got no schema of %s", test.UnaryCallInputMsgName)
+	}
+}
@@ -0,0 +1,172 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	perrors "github.com/gdong42/grpc-mate/errors"
+	"github.com/gdong42/grpc-mate/proxy/reflection"
+	"github.com/gdong42/grpc-mate/route"
+	"github.com/pkg/errors"
+)
+
+// Policy decides which upstream methods are exposed, by glob patterns on fully qualified service
+// names, e.g. grpc.health.*, or on full method names, e.g. helloworld.Greeter/Say*. A method is
+// exposed if it matches an allow pattern, or if there is none, and no deny pattern. Patterns are
+// matched by path.Match, so that * matches dots but not the slash between service and method.
+type Policy struct {
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+}
+
+// LoadPolicy reads the JSON file at path, e.g. {"deny": ["grpc.reflection.*", "grpc.health.*"]}
+func LoadPolicy(path string) (*Policy, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read exposure policy")
+	}
+	var p Policy
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, errors.Wrap(err, "failed to parse exposure policy")
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Validate checks the syntax of the patterns
+func (p *Policy) Validate() error {
+	for _, pattern := range append(append([]string{}, p.Allow...), p.Deny...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return errors.Errorf("invalid pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+// Exposes tells if the method of the service is exposed. A nil policy exposes all methods.
+func (p *Policy) Exposes(serviceName, methodName string) bool {
+	if p == nil {
+		return true
+	}
+	if len(p.Allow) > 0 && !matchAny(p.Allow, serviceName, methodName) {
+		return false
+	}
+	return !matchAny(p.Deny, serviceName, methodName)
+}
+
+// hides tells if no method of the service can be exposed, i.e. if the service is denied as a whole,
+// or if no allow pattern matches it
+func (p *Policy) hides(serviceName string) bool {
+	if p == nil {
+		return false
+	}
+	for _, pattern := range p.Deny {
+		if !strings.Contains(pattern, "/") && match(pattern, serviceName) {
+			return true
+		}
+	}
+	if len(p.Allow) == 0 {
+		return false
+	}
+	for _, pattern := range p.Allow {
+		if i := strings.Index(pattern, "/"); i >= 0 {
+			pattern = pattern[:i]
+		}
+		if match(pattern, serviceName) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchAny tells if any of the patterns matches the service, or the method if it has a slash
+func matchAny(patterns []string, serviceName, methodName string) bool {
+	for _, pattern := range patterns {
+		name := serviceName
+		if strings.Contains(pattern, "/") {
+			name = serviceName + "/" + methodName
+		}
+		if match(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func match(pattern, name string) bool {
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
+
+// policyReflector hides the services and methods the policy does not expose, which are then not
+// found
+type policyReflector struct {
+	reflection.Reflector
+	policy *Policy
+}
+
+// withPolicy applies the policy to the reflector, unless it is nil
+func withPolicy(r reflection.Reflector, p *Policy) reflection.Reflector {
+	if p == nil {
+		return r
+	}
+	return &policyReflector{Reflector: r, policy: p}
+}
+
+func (r *policyReflector) CreateInvocation(serviceName, methodName string, input []byte,
+	params *route.Params) (*reflection.MethodInvocation, error) {
+
+	if !r.policy.Exposes(serviceName, methodName) {
+		return nil, methodNotExposed(methodName)
+	}
+	return r.Reflector.CreateInvocation(serviceName, methodName, input, params)
+}
+
+func (r *policyReflector) ResolveMethod(serviceName, methodName string) (*reflection.MethodDescriptor, error) {
+	if !r.policy.Exposes(serviceName, methodName) {
+		return nil, methodNotExposed(methodName)
+	}
+	return r.Reflector.ResolveMethod(serviceName, methodName)
+}
+
+func (r *policyReflector) ListServices() ([]string, error) {
+	services, err := r.Reflector.ListServices()
+	if err != nil {
+		return nil, err
+	}
+	var exposed []string
+	for _, svc := range services {
+		if !r.policy.hides(svc) {
+			exposed = append(exposed, svc)
+		}
+	}
+	return exposed, nil
+}
+
+func (r *policyReflector) DescribeService(serviceName string) ([]*reflection.MethodDescriptor, error) {
+	mds, err := r.Reflector.DescribeService(serviceName)
+	if err != nil {
+		return nil, err
+	}
+	var exposed []*reflection.MethodDescriptor
+	for _, md := range mds {
+		if r.policy.Exposes(serviceName, md.GetName()) {
+			exposed = append(exposed, md)
+		}
+	}
+	return exposed, nil
+}
+
+// methodNotExposed is the error of calls to methods the policy does not expose, which are answered
+// like those to methods that do not exist
+func methodNotExposed(methodName string) error {
+	return &perrors.ProxyError{
+		Code:    perrors.MethodNotFound,
+		Message: fmt.Sprintf("the method %s was not found", methodName),
+	}
+}
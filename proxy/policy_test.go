@@ -0,0 +1,141 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	perrors "github.com/gdong42/grpc-mate/errors"
+	"github.com/gdong42/grpc-mate/metadata"
+	"github.com/gdong42/grpc-mate/proxy/test"
+	"github.com/pkg/errors"
+)
+
+func TestPolicyExposes(t *testing.T) {
+	cases := []struct {
+		name    string
+		policy  *Policy
+		service string
+		method  string
+		want    bool
+		hidden  bool
+	}{
+		{
+			name:    "no policy",
+			service: "grpc.health.v1.Health",
+			method:  "Check",
+			want:    true,
+		},
+		{
+			name:    "denied service",
+			policy:  &Policy{Deny: []string{"grpc.health.*"}},
+			service: "grpc.health.v1.Health",
+			method:  "Check",
+			want:    false,
+			hidden:  true,
+		},
+		{
+			name:    "denied method",
+			policy:  &Policy{Deny: []string{"helloworld.Greeter/Delete*"}},
+			service: "helloworld.Greeter",
+			method:  "DeleteGreeting",
+			want:    false,
+		},
+		{
+			name:    "other method of a denied method service",
+			policy:  &Policy{Deny: []string{"helloworld.Greeter/Delete*"}},
+			service: "helloworld.Greeter",
+			method:  "SayHello",
+			want:    true,
+		},
+		{
+			name:    "allowed service",
+			policy:  &Policy{Allow: []string{"helloworld.*"}},
+			service: "helloworld.Greeter",
+			method:  "SayHello",
+			want:    true,
+		},
+		{
+			name:    "service not allowed",
+			policy:  &Policy{Allow: []string{"helloworld.*"}},
+			service: "admin.Admin",
+			method:  "Shutdown",
+			want:    false,
+			hidden:  true,
+		},
+		{
+			name:    "method not allowed",
+			policy:  &Policy{Allow: []string{"helloworld.Greeter/SayHello"}},
+			service: "helloworld.Greeter",
+			method:  "DeleteGreeting",
+			want:    false,
+		},
+		{
+			name:    "allowed but denied",
+			policy:  &Policy{Allow: []string{"helloworld.*"}, Deny: []string{"*/Delete*"}},
+			service: "helloworld.Greeter",
+			method:  "DeleteGreeting",
+			want:    false,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got, want := tc.policy.Exposes(tc.service, tc.method), tc.want; got != want {
+				t.Errorf("got exposed %t, want %t", got, want)
+			}
+			if got, want := tc.policy.hides(tc.service), tc.hidden; got != want {
+				t.Errorf("got hidden %t, want %t", got, want)
+			}
+		})
+	}
+}
+
+func TestPolicyValidate(t *testing.T) {
+	if err := (&Policy{Allow: []string{"helloworld.*"}, Deny: []string{"[a-"}}).Validate(); err == nil {
+		t.Fatal("got no error, want invalid pattern error")
+	}
+}
+
+func TestProxyPolicy(t *testing.T) {
+	cc, stop := test.StartTestServer(t)
+	defer stop()
+	p := NewProxy(cc, WithPolicy(&Policy{Deny: []string{"grpc.reflection.*", test.TestService + "/Empty*"}}))
+
+	b, err := p.Introspect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r IntrospectionResponse
+	if err := json.Unmarshal(b, &r); err != nil {
+		t.Fatal(err)
+	}
+	var services, methods []string
+	for _, s := range r.Services {
+		services = append(services, s.Name)
+		for _, m := range s.Methods {
+			methods = append(methods, m.Name)
+		}
+	}
+	if got, want := services, []string{test.TestService}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got services %v, want %v", got, want)
+	}
+	for _, m := range methods {
+		if m == test.EmptyCall {
+			t.Errorf("got method %s, want it hidden", m)
+		}
+	}
+
+	var header, trailer metadata.Metadata
+	_, err = p.Invoke(context.Background(), test.TestService, test.EmptyCall, []byte("{}"), nil, &header, &trailer)
+	if e, ok := errors.Cause(err).(*perrors.ProxyError); !ok || e.Code != perrors.MethodNotFound {
+		t.Fatalf("got error %v, want method not found", err)
+	}
+
+	// once reloaded, the method is exposed again
+	p.SetPolicy(&Policy{Deny: []string{"grpc.reflection.*"}})
+	if _, err := p.Invoke(context.Background(), test.TestService, test.EmptyCall, []byte("{}"), nil,
+		&header, &trailer); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -3,15 +3,23 @@ package proxy
 import (
 	"context"
 	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/fullstorydev/grpcurl"
 	perrors "github.com/gdong42/grpc-mate/errors"
 	"github.com/gdong42/grpc-mate/metadata"
+	"github.com/gdong42/grpc-mate/openapi"
 	"github.com/gdong42/grpc-mate/proxy/reflection"
 	"github.com/gdong42/grpc-mate/proxy/stub"
+	"github.com/gdong42/grpc-mate/route"
+	"github.com/jhump/protoreflect/desc"
 	"github.com/jhump/protoreflect/dynamic/grpcdynamic"
 	"github.com/jhump/protoreflect/grpcreflect"
 	"github.com/pkg/errors"
+	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/connectivity"
 	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
@@ -19,22 +27,166 @@ import (
 
 // Proxy performs upstream invocation as a dynamic gRPC client using reflection
 type Proxy struct {
-	cc         *grpc.ClientConn
-	reflector  reflection.Reflector
-	stub       stub.Stub
-	descSource grpcurl.DescriptorSource
+	cc          *grpc.ClientConn
+	stub        stub.Stub
+	reflectStub *serverReflectionClient
+	logger      *zap.Logger
+
+	mapper         route.Mapper
+	reflectionOpts []reflection.Option
+	// files holds descriptors supplementing reflection, or replacing it when offline
+	files   grpcurl.DescriptorSource
+	offline bool
+
+	// mu guards the descriptors of the upstream schema, which are swapped when it changes
+	mu            sync.RWMutex
+	reflector     reflection.Reflector
+	descSource    grpcurl.DescriptorSource
+	reflectClient *grpcreflect.Client
+	routes        *route.Table
+	introspection *introspection
+	schema        *schema
+	policy        *Policy
+}
+
+// Option configures a Proxy
+type Option func(*Proxy)
+
+// WithRouteMapper derives routes by the mapper for methods without google.api.http options
+func WithRouteMapper(m route.Mapper) Option {
+	return func(p *Proxy) {
+		p.mapper = m
+	}
+}
+
+// WithDescriptorCacheTTL sets the time service descriptors obtained by reflection are cached for,
+// forever if ttl is 0. A negative ttl disables the cache.
+func WithDescriptorCacheTTL(ttl time.Duration) Option {
+	return func(p *Proxy) {
+		p.reflectionOpts = append(p.reflectionOpts, reflection.WithCacheTTL(ttl))
+	}
+}
+
+// WithDescriptorFiles describes services by the descriptors of the source, e.g. loaded by
+// LoadDescriptorSource, when reflection does not know them or is not implemented by the upstream
+func WithDescriptorFiles(source grpcurl.DescriptorSource) Option {
+	return func(p *Proxy) {
+		p.files = source
+	}
+}
+
+// WithoutReflection describes services by the descriptors of the source only, for upstreams which
+// do not implement reflection
+func WithoutReflection(source grpcurl.DescriptorSource) Option {
+	return func(p *Proxy) {
+		p.files = source
+		p.offline = true
+	}
+}
+
+// WithPolicy exposes only the methods the policy exposes
+func WithPolicy(policy *Policy) Option {
+	return func(p *Proxy) {
+		p.policy = policy
+	}
+}
+
+// WithLogger sets the logger of the proxy, which logs upstream schema changes
+func WithLogger(l *zap.Logger) Option {
+	return func(p *Proxy) {
+		p.logger = l
+	}
 }
 
 // NewProxy creates a new gRPC client
-func NewProxy(conn *grpc.ClientConn) *Proxy {
-	ctx := context.Background()
-	rc := grpcreflect.NewClient(ctx, rpb.NewServerReflectionClient(conn))
-	return &Proxy{
-		cc:         conn,
-		reflector:  reflection.NewReflector(rc),
-		stub:       stub.NewStub(grpcdynamic.NewStub(conn)),
-		descSource: grpcurl.DescriptorSourceFromServer(ctx, rc),
+func NewProxy(conn *grpc.ClientConn, opts ...Option) *Proxy {
+	p := &Proxy{
+		cc:          conn,
+		stub:        stub.NewStub(grpcdynamic.NewStub(conn)),
+		reflectStub: newServerReflectionClient(conn),
+		logger:      zap.NewNop(),
 	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	p.reflector = p.newReflector()
+	if p.offline {
+		p.descSource = p.files
+	} else {
+		p.reflectClient = grpcreflect.NewClient(context.Background(), p.reflectStub)
+		p.descSource = p.newDescSource(p.reflectClient)
+	}
+	return p
+}
+
+// newReflector creates a reflector with an empty descriptor cache, which resolves services by
+// reflection, descriptor files, or both
+func (p *Proxy) newReflector() reflection.Reflector {
+	var resolver serviceResolver = &reflectClient{stub: p.reflectStub}
+	if p.offline {
+		resolver = &sourceResolver{source: p.files}
+	} else if p.files != nil {
+		resolver = &fallbackResolver{reflection: resolver, files: &sourceResolver{source: p.files}}
+	}
+	return reflection.NewReflector(resolver, p.reflectionOpts...)
+}
+
+// newDescSource creates a descriptor source from the reflection client, supplemented by the
+// descriptor files if any
+func (p *Proxy) newDescSource(rc *grpcreflect.Client) grpcurl.DescriptorSource {
+	server := grpcurl.DescriptorSourceFromServer(context.Background(), rc)
+	if p.files == nil {
+		return server
+	}
+	return descriptorSources{server, p.files}
+}
+
+// descriptors returns the reflector of the current upstream schema, which hides the methods the
+// policy does not expose, and its descriptor source
+func (p *Proxy) descriptors() (reflection.Reflector, grpcurl.DescriptorSource) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return withPolicy(p.reflector, p.policy), p.descSource
+}
+
+// SetPolicy replaces the policy deciding which methods are exposed, nil exposing all of them. The
+// route table and the introspection are rebuilt on next use.
+func (p *Proxy) SetPolicy(policy *Policy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.policy = policy
+	p.routes = nil
+	p.introspection = nil
+}
+
+func (p *Proxy) getPolicy() *Policy {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.policy
+}
+
+func (p *Proxy) getReflector() reflection.Reflector {
+	r, _ := p.descriptors()
+	return r
+}
+
+// reflectClient performs reflection with a new grpcreflect.Client every time, which caches the
+// descriptors it fetches forever, so that the reflector descriptor cache is the only one and
+// services are reflected again once they expire from it
+type reflectClient struct {
+	stub rpb.ServerReflectionClient
+}
+
+func (c *reflectClient) ResolveService(serviceName string) (*desc.ServiceDescriptor, error) {
+	rc := grpcreflect.NewClient(context.Background(), c.stub)
+	defer rc.Reset()
+	return rc.ResolveService(serviceName)
+}
+
+func (c *reflectClient) ListServices() ([]string, error) {
+	rc := grpcreflect.NewClient(context.Background(), c.stub)
+	defer rc.Reset()
+	return rc.ListServices()
 }
 
 // IsReady checks the connectivity to the upstream
@@ -43,128 +195,354 @@ func (p *Proxy) IsReady() bool {
 	return s == connectivity.Ready
 }
 
-// Invoke performs the gRPC call after doing reflection to obtain type information
+// Invoke performs the gRPC call after doing reflection to obtain type information. The input
+// message is built from the JSON message, and from the request parameters if params is not nil.
+// The output message is returned in JSON, or only its field params binds to the response body.
 func (p *Proxy) Invoke(ctx context.Context,
 	serviceName, methodName string,
 	message []byte,
-	md *metadata.Metadata,
+	params *route.Params,
+	header, trailer *metadata.Metadata,
 ) ([]byte, error) {
-	invocation, err := p.reflector.CreateInvocation(serviceName, methodName, message)
+	invocation, err := p.getReflector().CreateInvocation(serviceName, methodName, message, params)
 	if err != nil {
 		return nil, err
 	}
 
-	outputMsg, err := p.stub.InvokeRPC(ctx, invocation, md)
+	outputMsg, err := p.stub.InvokeRPC(ctx, invocation, header, trailer)
 	if err != nil {
-		return nil, err
+		return nil, p.resolveDetails(err)
+	}
+	return marshalOutput(outputMsg, params)
+}
+
+// marshalOutput marshals the output message into JSON, or only the field the response body is
+// bound to by params, if any
+func marshalOutput(outputMsg reflection.Message, params *route.Params) ([]byte, error) {
+	var m []byte
+	var err error
+	if params != nil && params.ResponseBodyField != "" {
+		m, err = outputMsg.MarshalFieldJSON(params.ResponseBodyField)
+	} else {
+		m, err = outputMsg.MarshalJSON()
 	}
-	m, err := outputMsg.MarshalJSON()
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to marshal output JSON")
 	}
-	return m, err
+	return m, nil
 }
 
-// Introspect performs instrospection on this gRPC server, and obtains all services and methods
-// information
-func (p *Proxy) Introspect() ([]byte, error) {
-	if !p.IsReady() {
-		return nil, &perrors.ProxyError{
-			Code:    perrors.UpstreamConnFailure,
-			Message: "service down",
+// InvokeServerStream performs the server streaming gRPC call like Invoke, and passes every output
+// message in JSON to onMessage as it arrives. The upstream stream is canceled when ctx is done or
+// when onMessage returns an error.
+func (p *Proxy) InvokeServerStream(ctx context.Context,
+	serviceName, methodName string,
+	message []byte,
+	params *route.Params,
+	header, trailer *metadata.Metadata,
+	onMessage func([]byte) error,
+) error {
+	invocation, err := p.getReflector().CreateInvocation(serviceName, methodName, message, params)
+	if err != nil {
+		return err
+	}
+
+	err = p.stub.InvokeServerStream(ctx, invocation, header, trailer, func(outputMsg reflection.Message) error {
+		m, err := marshalOutput(outputMsg, params)
+		if err != nil {
+			return err
 		}
+		return onMessage(m)
+	})
+	return p.resolveDetails(err)
+}
+
+// InvokeClientStream performs the client streaming gRPC call, sending an input message built from
+// every JSON message returned by next until it returns io.EOF, and returns the output message in
+// JSON. Input messages are built like in Invoke.
+func (p *Proxy) InvokeClientStream(ctx context.Context,
+	serviceName, methodName string,
+	next func() ([]byte, error),
+	params *route.Params,
+	header, trailer *metadata.Metadata,
+) ([]byte, error) {
+	methodDesc, err := p.getReflector().ResolveMethod(serviceName, methodName)
+	if err != nil {
+		return nil, err
 	}
-	s, err := p.reflector.ListServices()
+
+	outputMsg, err := p.stub.InvokeClientStream(ctx, methodDesc, header, trailer, func() (reflection.Message, error) {
+		message, err := next()
+		if err != nil {
+			return nil, err
+		}
+		return methodDesc.CreateInputMessage(message, params)
+	})
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to list services")
+		return nil, p.resolveDetails(err)
 	}
-	ses := make([]*serviceElement, len(s))
-	// typeDscs holds a message name to MessageDescriptor mappings without duplicates
-	typeDscs := make(map[string]*reflection.MessageDescriptor)
-	r := &IntrospectionResponse{
-		Services: ses,
+	return marshalOutput(outputMsg, params)
+}
+
+// InvokeStream performs the gRPC call of any kind as a stream of messages in both directions. Input
+// messages are built from the JSON messages returned by next until it returns io.EOF, like in
+// Invoke, only the first one being sent for methods without client streaming. Every output message
+// in JSON is passed to onMessage as it arrives. The upstream call is canceled when ctx is done or
+// when next or onMessage returns another error.
+func (p *Proxy) InvokeStream(ctx context.Context,
+	serviceName, methodName string,
+	next func() ([]byte, error),
+	params *route.Params,
+	header, trailer *metadata.Metadata,
+	onMessage func([]byte) error,
+) error {
+	methodDesc, err := p.getReflector().ResolveMethod(serviceName, methodName)
+	if err != nil {
+		return err
 	}
-	for i, svc := range s {
-		mds, err := p.reflector.DescribeService(svc)
+	nextMsg := func() (reflection.Message, error) {
+		message, err := next()
 		if err != nil {
 			return nil, err
 		}
-		methods := make([]*methodElement, len(mds))
-		for j, m := range mds {
-			methods[j] = resolveMethodElement(svc, m, typeDscs)
-		}
-		se := &serviceElement{
-			Name:    svc,
-			Methods: methods,
+		return methodDesc.CreateInputMessage(message, params)
+	}
+	send := func(outputMsg reflection.Message) error {
+		m, err := marshalOutput(outputMsg, params)
+		if err != nil {
+			return err
 		}
-		r.Services[i] = se
+		return onMessage(m)
 	}
 
-	var types []*typeElement
-	for k, v := range typeDscs {
-		te, err := resolveTypeElement(k, v, p.descSource)
+	if methodDesc.IsClientStreaming() && methodDesc.IsServerStreaming() {
+		return p.resolveDetails(p.stub.InvokeBidiStream(ctx, methodDesc, header, trailer, nextMsg, send))
+	}
+	if methodDesc.IsClientStreaming() {
+		outputMsg, err := p.stub.InvokeClientStream(ctx, methodDesc, header, trailer, nextMsg)
 		if err != nil {
-			return nil, errors.Wrap(err, "failed to resolve type "+k)
+			return p.resolveDetails(err)
+		}
+		return send(outputMsg)
+	}
+	inputMsg, err := nextMsg()
+	if err == io.EOF {
+		return &perrors.ProxyError{
+			Code:    perrors.MessageTypeMismatch,
+			Message: "no input message was sent",
 		}
-		types = append(types, te)
 	}
-	r.Types = types
-	js, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	invocation := &reflection.MethodInvocation{
+		MethodDescriptor: methodDesc,
+		Message:          inputMsg,
+	}
+	if methodDesc.IsServerStreaming() {
+		return p.resolveDetails(p.stub.InvokeServerStream(ctx, invocation, header, trailer, send))
+	}
+	outputMsg, err := p.stub.InvokeRPC(ctx, invocation, header, trailer)
+	if err != nil {
+		return p.resolveDetails(err)
+	}
+	return send(outputMsg)
+}
+
+// InvalidateDescriptors removes the services from the descriptor cache, or all of them if none is
+// given, so that they are reflected again on their next call. The route table and the introspection
+// are rebuilt on next use as well.
+func (p *Proxy) InvalidateDescriptors(serviceNames ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.reflector.Invalidate(serviceNames...)
+	p.routes = nil
+	p.introspection = nil
+}
+
+// DescriptorCacheStats returns the statistics of the descriptor cache in JSON
+func (p *Proxy) DescriptorCacheStats() ([]byte, error) {
+	js, err := json.Marshal(p.getReflector().CacheStats())
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to marshal output JSON")
 	}
 	return js, nil
 }
 
-func resolveTypeElement(typeName string, md *reflection.MessageDescriptor,
+func resolveTypeElement(typeName string, md *desc.MessageDescriptor,
 	descSource grpcurl.DescriptorSource) (*typeElement, error) {
 
-	tmpl, err := md.MakeTemplate(descSource)
+	tmpl, err := reflection.NewMessageDescriptor(md).MakeTemplate(descSource)
 	if err != nil {
 		return nil, err
 	}
 	var t *json.RawMessage
 	err = json.Unmarshal([]byte(tmpl), &t)
-	return &typeElement{
-		Name:     typeName,
-		Template: t,
-	}, err
+	te := &typeElement{
+		Name:       typeName,
+		File:       md.GetFile().GetName(),
+		Package:    md.GetFile().GetPackage(),
+		Comments:   openapi.Comments(md),
+		Deprecated: md.GetMessageOptions().GetDeprecated(),
+		MapEntry:   md.IsMapEntry(),
+		Template:   t,
+		Schema:     "/actuator/schemas/" + typeName,
+	}
+	for _, fd := range md.GetFields() {
+		te.Fields = append(te.Fields, resolveFieldElement(fd))
+	}
+	return te, err
 }
 
-func resolveMethodElement(svc string, md *reflection.MethodDescriptor,
-	types map[string]*reflection.MessageDescriptor) *methodElement {
+func resolveFieldElement(fd *desc.FieldDescriptor) *fieldElement {
+	fe := &fieldElement{
+		Name:       fd.GetName(),
+		Number:     fd.GetNumber(),
+		Type:       strings.ToLower(strings.TrimPrefix(fd.GetType().String(), "TYPE_")),
+		Label:      strings.ToLower(strings.TrimPrefix(fd.GetLabel().String(), "LABEL_")),
+		JSONName:   fd.GetJSONName(),
+		Map:        fd.IsMap(),
+		Comments:   openapi.Comments(fd),
+		Deprecated: fd.GetFieldOptions().GetDeprecated(),
+	}
+	if mt := fd.GetMessageType(); mt != nil {
+		fe.TypeName = mt.GetFullyQualifiedName()
+	} else if et := fd.GetEnumType(); et != nil {
+		fe.TypeName = et.GetFullyQualifiedName()
+	}
+	if od := fd.GetOneOf(); od != nil {
+		fe.OneOf = od.GetName()
+	}
+	return fe
+}
+
+func resolveEnumElement(ed *desc.EnumDescriptor) *enumElement {
+	ee := &enumElement{
+		Name:       ed.GetFullyQualifiedName(),
+		File:       ed.GetFile().GetName(),
+		Package:    ed.GetFile().GetPackage(),
+		Comments:   openapi.Comments(ed),
+		Deprecated: ed.GetEnumOptions().GetDeprecated(),
+	}
+	for _, vd := range ed.GetValues() {
+		ee.Values = append(ee.Values, &enumValueElement{
+			Name:       vd.GetName(),
+			Number:     vd.GetNumber(),
+			Comments:   openapi.Comments(vd),
+			Deprecated: vd.GetEnumValueOptions().GetDeprecated(),
+		})
+	}
+	return ee
+}
 
-	inType := md.GetInputType()
-	outType := md.GetOutputType()
-	types[inType.GetFullyQualifiedName()] = inType
-	types[outType.GetFullyQualifiedName()] = outType
-	return &methodElement{
-		Name:       md.GetName(),
-		InputType:  inType.GetFullyQualifiedName(),
-		OutputType: outType.GetFullyQualifiedName(),
-		Route:      "/" + svc + "/" + md.GetName(),
+func resolveMethodElement(svc string, md *reflection.MethodDescriptor, table *route.Table) *methodElement {
+	pd := md.AsProtoreflectDescriptor()
+	me := &methodElement{
+		Name:             md.GetName(),
+		InputType:        pd.GetInputType().GetFullyQualifiedName(),
+		OutputType:       pd.GetOutputType().GetFullyQualifiedName(),
+		ClientStreaming:  pd.IsClientStreaming(),
+		ServerStreaming:  pd.IsServerStreaming(),
+		IdempotencyLevel: pd.GetMethodOptions().GetIdempotencyLevel().String(),
+		Comments:         openapi.Comments(pd),
+		Deprecated:       pd.GetMethodOptions().GetDeprecated(),
+		Route:            "/" + svc + "/" + md.GetName(),
 	}
+	// methods with routes, from google.api.http options or the route mapper, report their
+	// primary path template as route, and all bindings including additional ones
+	for _, r := range table.Routes() {
+		if r.Service != svc || r.Method != md.GetName() {
+			continue
+		}
+		if len(me.Bindings) == 0 {
+			me.Route = r.Template.String()
+		}
+		me.Bindings = append(me.Bindings, r.String())
+	}
+	return me
 }
 
 // IntrospectionResponse represents a introspection response
 type IntrospectionResponse struct {
-	Services []*serviceElement `json:"services"`
-	Types    []*typeElement    `json:"types"`
+	// Reflection is the reflection service the upstream is described by, if not offline
+	Reflection string            `json:"reflection,omitempty"`
+	Services   []*serviceElement `json:"services"`
+	// Types are the input and output types of the methods, and the message types of their fields
+	Types []*typeElement `json:"types"`
+	// Enums are the enum types of the fields of Types
+	Enums []*enumElement `json:"enums"`
 }
 
+// The comments of the elements are their leading, or trailing, proto comments, which descriptors
+// only carry when loaded from .proto files
+
 type serviceElement struct {
-	Name    string           `json:"name"`
-	Methods []*methodElement `json:"methods"`
+	Name       string           `json:"name"`
+	File       string           `json:"file,omitempty"`
+	Package    string           `json:"package,omitempty"`
+	Comments   string           `json:"comments,omitempty"`
+	Deprecated bool             `json:"deprecated,omitempty"`
+	Methods    []*methodElement `json:"methods"`
 }
 
 type methodElement struct {
-	Name       string `json:"name"`
-	InputType  string `json:"input"`
-	OutputType string `json:"output"`
-	Route      string `json:"route"`
+	Name            string `json:"name"`
+	InputType       string `json:"input"`
+	OutputType      string `json:"output"`
+	ClientStreaming bool   `json:"client_streaming"`
+	ServerStreaming bool   `json:"server_streaming"`
+	// IdempotencyLevel is the idempotency_level option, e.g. NO_SIDE_EFFECTS
+	IdempotencyLevel string   `json:"idempotency_level"`
+	Comments         string   `json:"comments,omitempty"`
+	Deprecated       bool     `json:"deprecated,omitempty"`
+	Route            string   `json:"route"`
+	Bindings         []string `json:"bindings,omitempty"`
 }
 
 type typeElement struct {
-	Name     string           `json:"name"`
+	Name       string `json:"name"`
+	File       string `json:"file"`
+	Package    string `json:"package"`
+	Comments   string `json:"comments,omitempty"`
+	Deprecated bool   `json:"deprecated,omitempty"`
+	// MapEntry tells if the type is the synthetic entry type of a map field
+	MapEntry bool             `json:"map_entry,omitempty"`
+	Fields   []*fieldElement  `json:"fields"`
 	Template *json.RawMessage `json:"template"`
+	// Schema is the path of the JSON Schema of the type
+	Schema string `json:"schema"`
+}
+
+type fieldElement struct {
+	Name   string `json:"name"`
+	Number int32  `json:"number"`
+	// Type is the lower case type of the field, e.g. string, message or enum
+	Type string `json:"type"`
+	// TypeName is the fully qualified name of the message or enum type of the field
+	TypeName string `json:"type_name,omitempty"`
+	// Label is optional, required or repeated
+	Label    string `json:"label"`
+	JSONName string `json:"json_name"`
+	// OneOf is the name of the oneof the field is a member of
+	OneOf string `json:"oneof,omitempty"`
+	// Map tells if the field is a map, whose TypeName is the map entry type
+	Map        bool   `json:"map,omitempty"`
+	Comments   string `json:"comments,omitempty"`
+	Deprecated bool   `json:"deprecated,omitempty"`
+}
+
+type enumElement struct {
+	Name       string              `json:"name"`
+	File       string              `json:"file"`
+	Package    string              `json:"package"`
+	Comments   string              `json:"comments,omitempty"`
+	Deprecated bool                `json:"deprecated,omitempty"`
+	Values     []*enumValueElement `json:"values"`
+}
+
+type enumValueElement struct {
+	Name       string `json:"name"`
+	Number     int32  `json:"number"`
+	Comments   string `json:"comments,omitempty"`
+	Deprecated bool   `json:"deprecated,omitempty"`
 }
@@ -2,13 +2,27 @@ package proxy
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 
+	perrors "github.com/gdong42/grpc-mate/errors"
 	"github.com/gdong42/grpc-mate/metadata"
 	"github.com/gdong42/grpc-mate/proxy/reflection"
 	"github.com/gdong42/grpc-mate/proxy/stub"
 	"github.com/gdong42/grpc-mate/proxy/test"
+	"github.com/gdong42/grpc-mate/route"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/jhump/protoreflect/grpcreflect"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
 	_ "google.golang.org/grpc/test/grpc_testing"
 )
 
@@ -42,13 +56,13 @@ func TestInvoke(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		p := NewProxy(cc)
 		ctx := context.Background()
-		md := make(metadata.Metadata)
+		var header, trailer metadata.Metadata
 
 		p.stub = stub.NewStub(&test.MockGrpcdynamicStub{})
 		fd := test.NewFileDescriptor(t, test.File)
 		p.reflector = reflection.NewReflector(&test.MockGrpcreflectClient{FileDescriptor: fd})
 
-		_, err := p.Invoke(ctx, test.TestService, test.EmptyCall, []byte("{}"), &md)
+		_, err := p.Invoke(ctx, test.TestService, test.EmptyCall, []byte("{}"), nil, &header, &trailer)
 		if err != nil {
 			t.Fatalf("err should be nil, got %s", err.Error())
 		}
@@ -57,12 +71,12 @@ func TestInvoke(t *testing.T) {
 	t.Run("reflector fails", func(t *testing.T) {
 		p := NewProxy(cc)
 		ctx := context.Background()
-		md := make(metadata.Metadata)
+		var header, trailer metadata.Metadata
 
 		p.stub = stub.NewStub(&test.MockGrpcdynamicStub{})
 		p.reflector = reflection.NewReflector(&test.MockGrpcreflectClient{})
 
-		_, err := p.Invoke(ctx, test.NotFoundService, test.EmptyCall, []byte("{}"), &md)
+		_, err := p.Invoke(ctx, test.NotFoundService, test.EmptyCall, []byte("{}"), nil, &header, &trailer)
 		if err == nil {
 			t.Fatalf("err should be not nil")
 		}
@@ -71,19 +85,293 @@ func TestInvoke(t *testing.T) {
 	t.Run("invoking RPC returns error", func(t *testing.T) {
 		p := NewProxy(cc)
 		ctx := context.Background()
-		md := make(metadata.Metadata)
+		var header, trailer metadata.Metadata
 
 		p.stub = stub.NewStub(&test.MockGrpcdynamicStub{})
 		fd := test.NewFileDescriptor(t, test.File)
 		p.reflector = reflection.NewReflector(&test.MockGrpcreflectClient{FileDescriptor: fd})
 
-		_, err := p.Invoke(ctx, test.TestService, test.UnaryCall, []byte("{}"), &md)
+		_, err := p.Invoke(ctx, test.TestService, test.UnaryCall, []byte("{}"), nil, &header, &trailer)
 		if err == nil {
 			t.Fatalf("err should be not nil")
 		}
 	})
 }
 
+func TestInvokeMetadata(t *testing.T) {
+	cc, stop := test.StartTestServer(t)
+	defer stop()
+	p := NewProxy(cc)
+	fd := test.NewFileDescriptor(t, test.File)
+	p.reflector = reflection.NewReflector(&test.MockGrpcreflectClient{FileDescriptor: fd})
+
+	var header, trailer metadata.Metadata
+	if _, err := p.Invoke(context.Background(), test.TestService, test.EmptyCall, []byte("{}"), nil,
+		&header, &trailer); err != nil {
+		t.Fatalf("err should be nil, got %s", err.Error())
+	}
+	if got, want := get(header, "x-header"), "header"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if got, want := get(trailer, "x-trailer"), "trailer"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestInvokeErrorDetails(t *testing.T) {
+	cc, stop := test.StartTestServer(t)
+	defer stop()
+	p := NewProxy(cc)
+	fd := test.NewFileDescriptor(t, test.File)
+	p.reflector = reflection.NewReflector(&test.MockGrpcreflectClient{FileDescriptor: fd})
+
+	cases := []struct {
+		code    codes.Code
+		details []string
+	}{
+		{
+			code:    codes.Unavailable,
+			details: []string{"google.rpc.RetryInfo"},
+		},
+		{
+			code:    codes.ResourceExhausted,
+			details: []string{"google.rpc.QuotaFailure", "google.rpc.RetryInfo"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.code.String(), func(t *testing.T) {
+			var header, trailer metadata.Metadata
+			_, err := p.Invoke(context.Background(), test.TestService, test.UnaryCall,
+				[]byte(fmt.Sprintf(`{"responseSize":%d}`, tc.code)), nil, &header, &trailer)
+			e, ok := err.(*perrors.GRPCError)
+			if !ok {
+				t.Fatalf("got %#v, want a gRPC error", err)
+			}
+			if got, want := codes.Code(e.StatusCode), tc.code; got != want {
+				t.Fatalf("got code %v, want %v", got, want)
+			}
+			var details []string
+			for _, d := range e.Details {
+				name, err := ptypes.AnyMessageName(d)
+				if err != nil {
+					t.Fatal(err)
+				}
+				details = append(details, name)
+			}
+			if got, want := details, tc.details; !reflect.DeepEqual(got, want) {
+				t.Fatalf("got details %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestInvokeUnreachableUpstream(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.Close()
+	cc, err := grpc.Dial(ln.Addr().String(), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+	p := NewProxy(cc)
+	fd := test.NewFileDescriptor(t, test.File)
+	p.reflector = reflection.NewReflector(&test.MockGrpcreflectClient{FileDescriptor: fd})
+
+	var header, trailer metadata.Metadata
+	_, err = p.Invoke(context.Background(), test.TestService, test.EmptyCall, []byte("{}"), nil,
+		&header, &trailer)
+	e, ok := err.(*perrors.ProxyError)
+	if !ok {
+		t.Fatalf("got %#v, want a proxy error", err)
+	}
+	if got, want := e.Code, perrors.UpstreamConnFailure; got != want {
+		t.Fatalf("got code %v, want %v", got, want)
+	}
+}
+
+func TestInvokeServerStream(t *testing.T) {
+	cc, err := grpc.Dial("localhost:5000", grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	p := NewProxy(cc)
+	var header, trailer metadata.Metadata
+
+	p.stub = stub.NewStub(&test.MockGrpcdynamicStub{})
+	fd := test.NewFileDescriptor(t, test.File)
+	p.reflector = reflection.NewReflector(&test.MockGrpcreflectClient{FileDescriptor: fd})
+
+	var got []string
+	err = p.InvokeServerStream(context.Background(), test.TestService, test.StreamingOutputCall,
+		[]byte(`{"responseParameters":[{"size":1},{"size":2}]}`), nil, &header, &trailer,
+		func(m []byte) error {
+			got = append(got, string(m))
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("err should be nil, got %s", err.Error())
+	}
+	want := []string{
+		`{"payload":{"body":"AA=="}}`,
+		`{"payload":{"body":"AAA="}}`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestInvokeClientStream(t *testing.T) {
+	cc, err := grpc.Dial("localhost:5000", grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	p := NewProxy(cc)
+	var header, trailer metadata.Metadata
+
+	p.stub = stub.NewStub(&test.MockGrpcdynamicStub{})
+	fd := test.NewFileDescriptor(t, test.File)
+	p.reflector = reflection.NewReflector(&test.MockGrpcreflectClient{FileDescriptor: fd})
+
+	requests := []string{`{"payload":{"body":"AA=="}}`, `{"payload":{"body":"AAA="}}`}
+	got, err := p.InvokeClientStream(context.Background(), test.TestService, test.StreamingInputCall,
+		func() ([]byte, error) {
+			if len(requests) == 0 {
+				return nil, io.EOF
+			}
+			m := requests[0]
+			requests = requests[1:]
+			return []byte(m), nil
+		}, nil, &header, &trailer)
+	if err != nil {
+		t.Fatalf("err should be nil, got %s", err.Error())
+	}
+	if want := `{"aggregatedPayloadSize":3}`; string(got) != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestInvokeResponseBody(t *testing.T) {
+	cc, err := grpc.Dial("localhost:5000", grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	p := NewProxy(cc)
+	p.stub = stub.NewStub(&test.MockGrpcdynamicStub{})
+	fd := test.NewFileDescriptor(t, test.File)
+	p.reflector = reflection.NewReflector(&test.MockGrpcreflectClient{FileDescriptor: fd})
+
+	t.Run("server streaming", func(t *testing.T) {
+		var header, trailer metadata.Metadata
+		var got []string
+		err := p.InvokeServerStream(context.Background(), test.TestService, test.StreamingOutputCall,
+			[]byte(`{"responseParameters":[{"size":1},{"size":2}]}`),
+			&route.Params{BodyField: "*", ResponseBodyField: "payload"}, &header, &trailer,
+			func(m []byte) error {
+				got = append(got, string(m))
+				return nil
+			})
+		if err != nil {
+			t.Fatalf("err should be nil, got %s", err.Error())
+		}
+		if want := []string{`{"body":"AA=="}`, `{"body":"AAA="}`}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("client streaming", func(t *testing.T) {
+		var header, trailer metadata.Metadata
+		requests := []string{`{"payload":{"body":"AA=="}}`, `{"payload":{"body":"AAA="}}`}
+		got, err := p.InvokeClientStream(context.Background(), test.TestService, test.StreamingInputCall,
+			func() ([]byte, error) {
+				if len(requests) == 0 {
+					return nil, io.EOF
+				}
+				m := requests[0]
+				requests = requests[1:]
+				return []byte(m), nil
+			}, &route.Params{BodyField: "*", ResponseBodyField: "aggregated_payload_size"}, &header, &trailer)
+		if err != nil {
+			t.Fatalf("err should be nil, got %s", err.Error())
+		}
+		if want := `3`; string(got) != want {
+			t.Fatalf("got %s, want %s", got, want)
+		}
+	})
+}
+
+func TestInvokeStream(t *testing.T) {
+	cc, err := grpc.Dial("localhost:5000", grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	cases := []struct {
+		name      string
+		method    string
+		requests  []string
+		responses []string
+	}{
+		{
+			name:      "unary",
+			method:    test.EmptyCall,
+			requests:  []string{`{}`},
+			responses: []string{`{}`},
+		},
+		{
+			name:      "server streaming",
+			method:    test.StreamingOutputCall,
+			requests:  []string{`{"responseParameters":[{"size":1},{"size":2}]}`},
+			responses: []string{`{"payload":{"body":"AA=="}}`, `{"payload":{"body":"AAA="}}`},
+		},
+		{
+			name:      "client streaming",
+			method:    test.StreamingInputCall,
+			requests:  []string{`{"payload":{"body":"AA=="}}`, `{"payload":{"body":"AAA="}}`},
+			responses: []string{`{"aggregatedPayloadSize":3}`},
+		},
+		{
+			name:      "bidi streaming",
+			method:    test.FullDuplexCall,
+			requests:  []string{`{"responseParameters":[{"size":1}]}`, `{"responseParameters":[{"size":2}]}`},
+			responses: []string{`{"payload":{"body":"AA=="}}`, `{"payload":{"body":"AAA="}}`},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := NewProxy(cc)
+			var header, trailer metadata.Metadata
+
+			p.stub = stub.NewStub(&test.MockGrpcdynamicStub{})
+			fd := test.NewFileDescriptor(t, test.File)
+			p.reflector = reflection.NewReflector(&test.MockGrpcreflectClient{FileDescriptor: fd})
+
+			requests := tc.requests
+			var got []string
+			err := p.InvokeStream(context.Background(), test.TestService, tc.method,
+				func() ([]byte, error) {
+					if len(requests) == 0 {
+						return nil, io.EOF
+					}
+					m := requests[0]
+					requests = requests[1:]
+					return []byte(m), nil
+				}, nil, &header, &trailer,
+				func(m []byte) error {
+					got = append(got, string(m))
+					return nil
+				})
+			if err != nil {
+				t.Fatalf("err should be nil, got %s", err.Error())
+			}
+			if !reflect.DeepEqual(got, tc.responses) {
+				t.Fatalf("got %v, want %v", got, tc.responses)
+			}
+		})
+	}
+}
+
 func TestIntrospect(t *testing.T) {
 	cc, err := grpc.Dial("localhost:5000", grpc.WithInsecure())
 	if err != nil {
@@ -100,3 +388,282 @@ func TestIntrospect(t *testing.T) {
 		t.Fatalf("err should not be nil, got %s", err.Error())
 	}
 }
+
+const libraryProto = `syntax = "proto3";
+
+package library;
+
+// Library lends books
+service Library {
+  // GetBook gets a book
+  rpc GetBook(GetBookRequest) returns (Book) {
+    option idempotency_level = NO_SIDE_EFFECTS;
+  }
+  rpc WatchBooks(GetBookRequest) returns (stream Book) {
+    option deprecated = true;
+  }
+}
+
+message GetBookRequest {
+  string book_name = 1;
+}
+
+// Book is a book
+message Book {
+  // State is the state of a book
+  enum State {
+    STATE_UNSPECIFIED = 0;
+    LENT = 1 [deprecated = true];
+  }
+  string name = 1;
+  State state = 2;
+  map<string, string> labels = 3;
+  oneof owner {
+    string library = 4;
+    string person = 5 [deprecated = true];
+  }
+  repeated Book related = 6;
+}
+`
+
+func TestIntrospectElements(t *testing.T) {
+	dir, err := ioutil.TempDir("", "introspect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "library.proto"), []byte(libraryProto), 0644); err != nil {
+		t.Fatal(err)
+	}
+	files, err := LoadDescriptorSource(nil, []string{dir}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc, stop := test.StartTestServerWithReflection(t, 0)
+	defer stop()
+	p := NewProxy(cc, WithoutReflection(files))
+
+	b, err := p.Introspect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r IntrospectionResponse
+	if err := json.Unmarshal(b, &r); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Services) != 1 {
+		t.Fatalf("got services %s, want library.Library", b)
+	}
+	svc := r.Services[0]
+	for _, m := range svc.Methods {
+		m.Bindings = nil
+	}
+	types := make(map[string]*typeElement)
+	for _, te := range r.Types {
+		te.Template = nil
+		types[te.Name] = te
+	}
+
+	cases := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{
+			name: "service",
+			got:  svc.Comments + "," + svc.File + "," + svc.Package,
+			want: "Library lends books,library.proto,library",
+		},
+		{
+			name: "unary method",
+			got:  svc.Methods[0],
+			want: &methodElement{
+				Name:             "GetBook",
+				InputType:        "library.GetBookRequest",
+				OutputType:       "library.Book",
+				IdempotencyLevel: "NO_SIDE_EFFECTS",
+				Comments:         "GetBook gets a book",
+				Route:            "/library.Library/GetBook",
+			},
+		},
+		{
+			name: "deprecated server streaming method",
+			got:  svc.Methods[1],
+			want: &methodElement{
+				Name:             "WatchBooks",
+				InputType:        "library.GetBookRequest",
+				OutputType:       "library.Book",
+				ServerStreaming:  true,
+				IdempotencyLevel: "IDEMPOTENCY_UNKNOWN",
+				Deprecated:       true,
+				Route:            "/library.Library/WatchBooks",
+			},
+		},
+		{
+			name: "types",
+			got:  len(r.Types),
+			want: 3,
+		},
+		{
+			name: "map entry",
+			got:  types["library.Book.LabelsEntry"].MapEntry,
+			want: true,
+		},
+		{
+			name: "type",
+			got:  types["library.Book"],
+			want: &typeElement{
+				Name:     "library.Book",
+				File:     "library.proto",
+				Package:  "library",
+				Comments: "Book is a book",
+				Fields: []*fieldElement{
+					{Name: "name", Number: 1, Type: "string", Label: "optional", JSONName: "name"},
+					{Name: "state", Number: 2, Type: "enum", TypeName: "library.Book.State", Label: "optional",
+						JSONName: "state"},
+					{Name: "labels", Number: 3, Type: "message", TypeName: "library.Book.LabelsEntry",
+						Label: "repeated", JSONName: "labels", Map: true},
+					{Name: "library", Number: 4, Type: "string", Label: "optional", JSONName: "library",
+						OneOf: "owner"},
+					{Name: "person", Number: 5, Type: "string", Label: "optional", JSONName: "person",
+						OneOf: "owner", Deprecated: true},
+					{Name: "related", Number: 6, Type: "message", TypeName: "library.Book", Label: "repeated",
+						JSONName: "related"},
+				},
+				Schema: "/actuator/schemas/library.Book",
+			},
+		},
+		{
+			name: "enums",
+			got:  r.Enums,
+			want: []*enumElement{
+				{
+					Name:     "library.Book.State",
+					File:     "library.proto",
+					Package:  "library",
+					Comments: "State is the state of a book",
+					Values: []*enumValueElement{
+						{Name: "STATE_UNSPECIFIED"},
+						{Name: "LENT", Number: 1, Deprecated: true},
+					},
+				},
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got, want := tc.got, tc.want; !reflect.DeepEqual(got, want) {
+				g, _ := json.Marshal(got)
+				w, _ := json.Marshal(want)
+				t.Fatalf("got %s, want %s", g, w)
+			}
+		})
+	}
+}
+
+func TestInvalidateDescriptors(t *testing.T) {
+	cc, stop := test.StartTestServer(t)
+	defer stop()
+	p := NewProxy(cc)
+
+	invoke := func() {
+		var header, trailer metadata.Metadata
+		if _, err := p.Invoke(context.Background(), test.TestService, test.EmptyCall, []byte("{}"), nil,
+			&header, &trailer); err != nil {
+			t.Fatalf("err should be nil, got %s", err.Error())
+		}
+	}
+	stats := func() reflection.CacheStats {
+		var s reflection.CacheStats
+		b, err := p.DescriptorCacheStats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(b, &s); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	invoke()
+	invoke()
+	if got, want := stats(), (reflection.CacheStats{Hits: 1, Misses: 1, Services: 1}); got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	p.InvalidateDescriptors(test.TestService)
+	if got, want := stats(), (reflection.CacheStats{Hits: 1, Misses: 1}); got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	invoke()
+	if got, want := stats(), (reflection.CacheStats{Hits: 1, Misses: 2, Services: 1}); got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+// BenchmarkResolveMethod measures the overhead reflection adds to every call: with the descriptor
+// cache, without it, and without it but with the file cache of a long lived grpcreflect.Client,
+// which was how methods used to be resolved
+func BenchmarkResolveMethod(b *testing.B) {
+	cc, stop := test.StartTestServer(b)
+	defer stop()
+	rc := grpcreflect.NewClient(context.Background(), rpb.NewServerReflectionClient(cc))
+	defer rc.Reset()
+
+	cases := []struct {
+		name      string
+		reflector reflection.Reflector
+	}{
+		{
+			name:      "descriptor cache",
+			reflector: NewProxy(cc).reflector,
+		},
+		{
+			name:      "no cache",
+			reflector: NewProxy(cc, WithDescriptorCacheTTL(-1)).reflector,
+		},
+		{
+			name:      "grpcreflect cache",
+			reflector: reflection.NewReflector(rc, reflection.WithCacheTTL(-1)),
+		},
+	}
+	for _, tc := range cases {
+		b.Run(tc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := tc.reflector.ResolveMethod(test.TestService, test.EmptyCall); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkInvoke measures unary calls with and without the descriptor cache
+func BenchmarkInvoke(b *testing.B) {
+	cc, stop := test.StartTestServer(b)
+	defer stop()
+
+	cases := []struct {
+		name  string
+		proxy *Proxy
+	}{
+		{
+			name:  "descriptor cache",
+			proxy: NewProxy(cc),
+		},
+		{
+			name:  "no cache",
+			proxy: NewProxy(cc, WithDescriptorCacheTTL(-1)),
+		},
+	}
+	for _, tc := range cases {
+		b.Run(tc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				var header, trailer metadata.Metadata
+				if _, err := tc.proxy.Invoke(context.Background(), test.TestService, test.EmptyCall, []byte("{}"),
+					nil, &header, &trailer); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
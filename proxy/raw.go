@@ -0,0 +1,112 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	perrors "github.com/gdong42/grpc-mate/errors"
+	"github.com/gdong42/grpc-mate/metadata"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// InvokeRaw forwards the call of the method, named /service/method, to the upstream as it is,
+// without reflection nor conversion. Every message returned by next, in the protobuf wire format,
+// is sent until it returns io.EOF, then every message received is passed to onMessage. The header
+// metadata received is stored into header before the first message is passed, and the trailer
+// metadata into trailer before returning.
+func (p *Proxy) InvokeRaw(ctx context.Context,
+	fullMethod string,
+	next func() ([]byte, error),
+	header, trailer *metadata.Metadata,
+	onMessage func([]byte) error,
+) error {
+	if svc, method := splitFullMethod(fullMethod); !p.getPolicy().Exposes(svc, method) {
+		return methodNotExposed(method)
+	}
+	// canceling the context cancels the upstream stream when returning early
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	desc := &grpc.StreamDesc{
+		ServerStreams: true,
+		ClientStreams: true,
+	}
+	stream, err := p.cc.NewStream(ctx, desc, fullMethod, grpc.ForceCodec(rawCodec{}))
+	if err != nil {
+		return grpcError(err)
+	}
+	defer func() {
+		*trailer = metadata.Metadata(stream.Trailer())
+	}()
+	for {
+		m, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		// io.EOF means the upstream ended the call, whose status is then received below
+		if err := stream.SendMsg(m); err == io.EOF {
+			break
+		} else if err != nil {
+			return grpcError(err)
+		}
+	}
+	if err := stream.CloseSend(); err != nil {
+		return grpcError(err)
+	}
+	// a failed call may have no header, its status is then received below
+	if h, err := stream.Header(); err == nil {
+		*header = metadata.Metadata(h)
+	}
+	for {
+		var m []byte
+		if err := stream.RecvMsg(&m); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return grpcError(err)
+		}
+		if err := onMessage(m); err != nil {
+			return err
+		}
+	}
+}
+
+// splitFullMethod splits the full method name /service/method into the service and method names
+func splitFullMethod(fullMethod string) (string, string) {
+	name := strings.TrimPrefix(fullMethod, "/")
+	i := strings.LastIndex(name, "/")
+	if i < 0 {
+		return name, ""
+	}
+	return name[:i], name[i+1:]
+}
+
+// grpcError converts the error of a gRPC call into a GRPCError
+func grpcError(err error) error {
+	stat := status.Convert(err)
+	return &perrors.GRPCError{
+		StatusCode: int(stat.Code()),
+		Message:    stat.Message(),
+		Details:    stat.Proto().Details,
+	}
+}
+
+// rawCodec passes messages through as they are in the protobuf wire format
+type rawCodec struct {
+}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	return v.([]byte), nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	*(v.(*[]byte)) = append([]byte(nil), data...)
+	return nil
+}
+
+func (rawCodec) Name() string {
+	return "proto"
+}
@@ -0,0 +1,110 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"testing"
+
+	perrors "github.com/gdong42/grpc-mate/errors"
+	"github.com/gdong42/grpc-mate/metadata"
+	"github.com/gdong42/grpc-mate/proxy/test"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/test/grpc_testing"
+)
+
+func TestInvokeRaw(t *testing.T) {
+	cc, stop := test.StartTestServer(t)
+	defer stop()
+	p := NewProxy(cc)
+
+	cases := []struct {
+		name      string
+		method    string
+		requests  []proto.Message
+		responses []proto.Message
+		header    string
+		trailer   string
+		error
+	}{
+		{
+			name:      "unary",
+			method:    "/grpc.testing.TestService/EmptyCall",
+			requests:  []proto.Message{&grpc_testing.Empty{}},
+			responses: []proto.Message{&grpc_testing.Empty{}},
+			header:    "header",
+			trailer:   "trailer",
+		},
+		{
+			name:   "server streaming",
+			method: "/grpc.testing.TestService/StreamingOutputCall",
+			requests: []proto.Message{&grpc_testing.StreamingOutputCallRequest{
+				ResponseParameters: []*grpc_testing.ResponseParameters{{Size: 1}, {Size: 2}},
+			}},
+			responses: []proto.Message{
+				&grpc_testing.StreamingOutputCallResponse{Payload: &grpc_testing.Payload{Body: []byte{0}}},
+				&grpc_testing.StreamingOutputCallResponse{Payload: &grpc_testing.Payload{Body: []byte{0, 0}}},
+			},
+		},
+		{
+			name:      "grpc error",
+			method:    "/grpc.testing.TestService/UnaryCall",
+			requests:  []proto.Message{&grpc_testing.SimpleRequest{}},
+			responses: []proto.Message{},
+			error: &perrors.GRPCError{
+				StatusCode: int(codes.Unimplemented),
+				Message:    "unary unimplemented",
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			requests := tc.requests
+			var header, trailer metadata.Metadata
+			got := []proto.Message{}
+			err := p.InvokeRaw(context.Background(), tc.method,
+				func() ([]byte, error) {
+					if len(requests) == 0 {
+						return nil, io.EOF
+					}
+					m := requests[0]
+					requests = requests[1:]
+					return proto.Marshal(m)
+				},
+				&header, &trailer,
+				func(b []byte) error {
+					m := reflect.New(reflect.TypeOf(tc.responses[0]).Elem()).Interface().(proto.Message)
+					if err := proto.Unmarshal(b, m); err != nil {
+						return err
+					}
+					got = append(got, m)
+					return nil
+				})
+			if want := tc.error; !reflect.DeepEqual(err, want) {
+				t.Fatalf("got %#v, want %#v", err, want)
+			}
+			if len(got) != len(tc.responses) {
+				t.Fatalf("got %v, want %v", got, tc.responses)
+			}
+			for i := range got {
+				if !proto.Equal(got[i], tc.responses[i]) {
+					t.Fatalf("got %v, want %v", got[i], tc.responses[i])
+				}
+			}
+			if got, want := get(header, "x-header"), tc.header; got != want {
+				t.Fatalf("got %q, want %q", got, want)
+			}
+			if got, want := get(trailer, "x-trailer"), tc.trailer; got != want {
+				t.Fatalf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func get(md metadata.Metadata, key string) string {
+	if len(md[key]) == 0 {
+		return ""
+	}
+	return md[key][0]
+}
@@ -0,0 +1,127 @@
+package proxy
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	reflectionV1Service      = "grpc.reflection.v1.ServerReflection"
+	reflectionV1AlphaService = "grpc.reflection.v1alpha.ServerReflection"
+)
+
+// reflectionInfoStreamDesc describes the ServerReflectionInfo method of both reflection services
+var reflectionInfoStreamDesc = grpc.StreamDesc{
+	StreamName:    "ServerReflectionInfo",
+	ServerStreams: true,
+	ClientStreams: true,
+}
+
+// serverReflectionClient calls the grpc.reflection.v1 reflection service of the upstream, or the
+// v1alpha one if the upstream does not implement v1. Both services have the same messages, so v1
+// is called with the v1alpha ones.
+type serverReflectionClient struct {
+	cc      *grpc.ClientConn
+	v1alpha rpb.ServerReflectionClient
+
+	mu sync.Mutex
+	// service is the reflection service in use, empty until the upstream is probed
+	service string
+}
+
+func newServerReflectionClient(cc *grpc.ClientConn) *serverReflectionClient {
+	return &serverReflectionClient{
+		cc:      cc,
+		v1alpha: rpb.NewServerReflectionClient(cc),
+	}
+}
+
+func (c *serverReflectionClient) ServerReflectionInfo(ctx context.Context,
+	opts ...grpc.CallOption) (rpb.ServerReflection_ServerReflectionInfoClient, error) {
+
+	service, err := c.probe(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if service == reflectionV1AlphaService {
+		return c.v1alpha.ServerReflectionInfo(ctx, opts...)
+	}
+	return c.v1ReflectionInfo(ctx, opts...)
+}
+
+// Service returns the reflection service in use, probing the upstream if not done yet, or an empty
+// string if the upstream cannot be probed
+func (c *serverReflectionClient) Service() string {
+	service, _ := c.probe(context.Background())
+	return service
+}
+
+// reset makes the upstream probed again on next use, as it may have changed
+func (c *serverReflectionClient) reset() {
+	c.mu.Lock()
+	c.service = ""
+	c.mu.Unlock()
+}
+
+// probe finds the reflection service the upstream implements by listing services, with v1 first,
+// and falling back to v1alpha if v1 is unimplemented
+func (c *serverReflectionClient) probe(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.service != "" {
+		return c.service, nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := c.v1ReflectionInfo(ctx)
+	if err == nil {
+		err = stream.Send(&rpb.ServerReflectionRequest{
+			MessageRequest: &rpb.ServerReflectionRequest_ListServices{},
+		})
+	}
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	switch status.Code(err) {
+	case codes.OK:
+		c.service = reflectionV1Service
+	case codes.Unimplemented:
+		c.service = reflectionV1AlphaService
+	default:
+		return "", err
+	}
+	return c.service, nil
+}
+
+func (c *serverReflectionClient) v1ReflectionInfo(ctx context.Context,
+	opts ...grpc.CallOption) (rpb.ServerReflection_ServerReflectionInfoClient, error) {
+
+	stream, err := c.cc.NewStream(ctx, &reflectionInfoStreamDesc, "/"+reflectionV1Service+"/ServerReflectionInfo",
+		opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &reflectionInfoClient{stream}, nil
+}
+
+// reflectionInfoClient is a ServerReflectionInfo stream of the v1 reflection service
+type reflectionInfoClient struct {
+	grpc.ClientStream
+}
+
+func (x *reflectionInfoClient) Send(m *rpb.ServerReflectionRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *reflectionInfoClient) Recv() (*rpb.ServerReflectionResponse, error) {
+	m := new(rpb.ServerReflectionResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
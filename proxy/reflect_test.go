@@ -0,0 +1,61 @@
+package proxy
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/gdong42/grpc-mate/proxy/test"
+)
+
+func TestServerReflectionClient(t *testing.T) {
+	cases := []struct {
+		name       string
+		reflection test.Reflection
+		service    string
+	}{
+		{
+			name:       "v1alpha",
+			reflection: test.ReflectionV1Alpha,
+			service:    "grpc.reflection.v1alpha.ServerReflection",
+		},
+		{
+			name:       "v1",
+			reflection: test.ReflectionV1,
+			service:    "grpc.reflection.v1.ServerReflection",
+		},
+		{
+			name:       "v1 preferred",
+			reflection: test.ReflectionV1 | test.ReflectionV1Alpha,
+			service:    "grpc.reflection.v1.ServerReflection",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cc, stop := test.StartTestServerWithReflection(t, tc.reflection)
+			defer stop()
+			p := NewProxy(cc)
+
+			m, err := p.Method(test.TestService, test.EmptyCall)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := m.Name, test.EmptyCall; got != want {
+				t.Fatalf("got %s, want %s", got, want)
+			}
+			b, err := p.Introspect()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var r IntrospectionResponse
+			if err := json.Unmarshal(b, &r); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := r.Reflection, tc.service; got != want {
+				t.Fatalf("got %s, want %s", got, want)
+			}
+			if len(r.Types) == 0 {
+				t.Fatal("got no types, want some")
+			}
+		})
+	}
+}
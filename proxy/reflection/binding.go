@@ -0,0 +1,312 @@
+package reflection
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	dpb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+
+	perrors "github.com/gdong42/grpc-mate/errors"
+	"github.com/gdong42/grpc-mate/route"
+)
+
+// wrapperTypeNames are the well-known wrapper types, which are bound from parameters like the
+// scalar type they wrap
+var wrapperTypeNames = map[string]struct{}{
+	"google.protobuf.DoubleValue": {},
+	"google.protobuf.FloatValue":  {},
+	"google.protobuf.Int64Value":  {},
+	"google.protobuf.UInt64Value": {},
+	"google.protobuf.Int32Value":  {},
+	"google.protobuf.UInt32Value": {},
+	"google.protobuf.BoolValue":   {},
+	"google.protobuf.StringValue": {},
+	"google.protobuf.BytesValue":  {},
+}
+
+// stringTypeNames are the well-known types whose JSON representation is a string, which are
+// bound from parameters by their JSON representation
+var stringTypeNames = map[string]struct{}{
+	"google.protobuf.Timestamp": {},
+	"google.protobuf.Duration":  {},
+	"google.protobuf.FieldMask": {},
+}
+
+// bindRequest builds the message from the request body and parameters
+func bindRequest(msg *dynamic.Message, body []byte, params *route.Params) error {
+	if err := bindBody(msg, body, params.BodyField); err != nil {
+		return err
+	}
+	if params.BodyField != "*" {
+		keys := make([]string, 0, len(params.QueryParams))
+		for k := range params.QueryParams {
+			keys = append(keys, k)
+		}
+		// sorted for errors to be deterministic
+		sort.Strings(keys)
+		for _, k := range keys {
+			if isBoundByPath(k, params) {
+				continue
+			}
+			if err := bindParam(msg, k, params.QueryParams[k]); err != nil {
+				return err
+			}
+		}
+	}
+	for k, v := range params.PathParams {
+		if err := bindParam(msg, k, []string{v}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isBoundByPath tells if the query parameter is a field, or a sub field, of the body field or a
+// path variable, in which case it is not bound
+func isBoundByPath(key string, params *route.Params) bool {
+	bound := []string{params.BodyField}
+	for k := range params.PathParams {
+		bound = append(bound, k)
+	}
+	for _, b := range bound {
+		if b != "" && (key == b || strings.HasPrefix(key, b+".")) {
+			return true
+		}
+	}
+	return false
+}
+
+func bindBody(msg *dynamic.Message, body []byte, bodyField string) error {
+	if bodyField == "" || len(strings.TrimSpace(string(body))) == 0 {
+		return nil
+	}
+	if bodyField == "*" {
+		return unmarshalJSON(msg, body)
+	}
+	parent, fd, err := resolveFieldPath(msg, bodyField)
+	if err != nil {
+		return err
+	}
+	// the body is the JSON value of the field, so it is unmarshaled into the parent wrapped
+	// into an object keyed by the JSON name of the field
+	tmp := dynamic.NewMessage(parent.GetMessageDescriptor())
+	wrapped := []byte(`{"` + fd.GetJSONName() + `":` + string(body) + `}`)
+	if err := unmarshalJSON(tmp, wrapped); err != nil {
+		return err
+	}
+	return parent.TrySetField(fd, tmp.GetField(fd))
+}
+
+func unmarshalJSON(msg *dynamic.Message, b []byte) error {
+	if err := msg.UnmarshalJSON(b); err != nil {
+		return &perrors.ProxyError{
+			Code:    perrors.MessageTypeMismatch,
+			Message: "input JSON does not match messageImpl type",
+		}
+	}
+	return nil
+}
+
+// bindParam sets the values of a parameter into the field denoted by the field path
+func bindParam(msg *dynamic.Message, fieldPath string, values []string) error {
+	parent, fd, err := resolveFieldPath(msg, fieldPath)
+	if err != nil {
+		return err
+	}
+	if fd.IsMap() {
+		return invalidParameter(fieldPath, "map fields cannot be bound from parameters")
+	}
+	if !fd.IsRepeated() && len(values) > 1 {
+		return invalidParameter(fieldPath, "only a single value is allowed")
+	}
+	for _, v := range values {
+		val, err := parseParam(fd, v)
+		if err != nil {
+			return invalidParameter(fieldPath, err.Error())
+		}
+		if fd.IsRepeated() {
+			err = parent.TryAddRepeatedField(fd, val)
+		} else {
+			err = parent.TrySetField(fd, val)
+		}
+		if err != nil {
+			return invalidParameter(fieldPath, err.Error())
+		}
+	}
+	return nil
+}
+
+// resolveFieldPath finds the field denoted by a dot separated field path made of proto or JSON
+// field names, and returns it with the message it belongs to. Intermediate messages are created
+// when absent.
+func resolveFieldPath(msg *dynamic.Message, fieldPath string) (*dynamic.Message, *desc.FieldDescriptor, error) {
+	names := strings.Split(fieldPath, ".")
+	for i, name := range names {
+		fd := msg.FindFieldDescriptorByName(name)
+		if fd == nil {
+			fd = msg.FindFieldDescriptorByJSONName(name)
+		}
+		if fd == nil {
+			return nil, nil, invalidParameter(fieldPath,
+				fmt.Sprintf("message type %s has no field named %s", msg.GetMessageDescriptor().GetFullyQualifiedName(), name))
+		}
+		if i == len(names)-1 {
+			return msg, fd, nil
+		}
+		if fd.GetMessageType() == nil || fd.IsRepeated() {
+			return nil, nil, invalidParameter(fieldPath, fmt.Sprintf("field %s is not a singular message", name))
+		}
+		sub, err := subMessage(msg, fd)
+		if err != nil {
+			return nil, nil, invalidParameter(fieldPath, err.Error())
+		}
+		msg = sub
+	}
+	return nil, nil, invalidParameter(fieldPath, "empty field path")
+}
+
+// subMessage returns the message held by a message field, creating it if absent
+func subMessage(msg *dynamic.Message, fd *desc.FieldDescriptor) (*dynamic.Message, error) {
+	if msg.HasField(fd) {
+		v, err := msg.TryGetField(fd)
+		if err != nil {
+			return nil, err
+		}
+		if sub, ok := v.(*dynamic.Message); ok {
+			return sub, nil
+		}
+		sub := dynamic.NewMessage(fd.GetMessageType())
+		if err := sub.ConvertFrom(v.(proto.Message)); err != nil {
+			return nil, err
+		}
+		return sub, msg.TrySetField(fd, sub)
+	}
+	sub := dynamic.NewMessage(fd.GetMessageType())
+	return sub, msg.TrySetField(fd, sub)
+}
+
+// parseParam converts a parameter value into the type of the field
+func parseParam(fd *desc.FieldDescriptor, v string) (interface{}, error) {
+	switch fd.GetType() {
+	case dpb.FieldDescriptorProto_TYPE_STRING:
+		return v, nil
+	case dpb.FieldDescriptorProto_TYPE_BOOL:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bool value %q", v)
+		}
+		return b, nil
+	case dpb.FieldDescriptorProto_TYPE_INT32, dpb.FieldDescriptorProto_TYPE_SINT32, dpb.FieldDescriptorProto_TYPE_SFIXED32:
+		i, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid int32 value %q", v)
+		}
+		return int32(i), nil
+	case dpb.FieldDescriptorProto_TYPE_INT64, dpb.FieldDescriptorProto_TYPE_SINT64, dpb.FieldDescriptorProto_TYPE_SFIXED64:
+		i, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid int64 value %q", v)
+		}
+		return i, nil
+	case dpb.FieldDescriptorProto_TYPE_UINT32, dpb.FieldDescriptorProto_TYPE_FIXED32:
+		i, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid uint32 value %q", v)
+		}
+		return uint32(i), nil
+	case dpb.FieldDescriptorProto_TYPE_UINT64, dpb.FieldDescriptorProto_TYPE_FIXED64:
+		i, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid uint64 value %q", v)
+		}
+		return i, nil
+	case dpb.FieldDescriptorProto_TYPE_FLOAT:
+		f, err := strconv.ParseFloat(v, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid float value %q", v)
+		}
+		return float32(f), nil
+	case dpb.FieldDescriptorProto_TYPE_DOUBLE:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid double value %q", v)
+		}
+		return f, nil
+	case dpb.FieldDescriptorProto_TYPE_BYTES:
+		return parseBytes(v)
+	case dpb.FieldDescriptorProto_TYPE_ENUM:
+		return parseEnum(fd.GetEnumType(), v)
+	default:
+		return parseMessage(fd.GetMessageType(), v)
+	}
+}
+
+// parseBytes decodes base64 values, either in standard or URL-safe encoding, with or without padding
+func parseBytes(v string) ([]byte, error) {
+	encodings := []*base64.Encoding{
+		base64.StdEncoding,
+		base64.URLEncoding,
+		base64.RawStdEncoding,
+		base64.RawURLEncoding,
+	}
+	for _, enc := range encodings {
+		if b, err := enc.DecodeString(v); err == nil {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid base64 value %q", v)
+}
+
+// parseEnum accepts enum values by name or by number
+func parseEnum(ed *desc.EnumDescriptor, v string) (int32, error) {
+	if vd := ed.FindValueByName(v); vd != nil {
+		return vd.GetNumber(), nil
+	}
+	if i, err := strconv.ParseInt(v, 10, 32); err == nil {
+		if vd := ed.FindValueByNumber(int32(i)); vd != nil {
+			return vd.GetNumber(), nil
+		}
+	}
+	names := make([]string, len(ed.GetValues()))
+	for i, vd := range ed.GetValues() {
+		names[i] = vd.GetName()
+	}
+	return 0, fmt.Errorf("invalid value %q of enum %s, must be one of %s",
+		v, ed.GetFullyQualifiedName(), strings.Join(names, ", "))
+}
+
+// parseMessage only accepts well-known types that have a scalar representation
+func parseMessage(md *desc.MessageDescriptor, v string) (*dynamic.Message, error) {
+	fqn := md.GetFullyQualifiedName()
+	msg := dynamic.NewMessage(md)
+	if _, ok := wrapperTypeNames[fqn]; ok {
+		fd := md.FindFieldByName("value")
+		val, err := parseParam(fd, v)
+		if err != nil {
+			return nil, err
+		}
+		return msg, msg.TrySetField(fd, val)
+	}
+	if _, ok := stringTypeNames[fqn]; ok {
+		js, _ := json.Marshal(v)
+		if err := msg.UnmarshalJSON(js); err != nil {
+			return nil, fmt.Errorf("invalid %s value %q", fqn, v)
+		}
+		return msg, nil
+	}
+	return nil, fmt.Errorf("message type %s cannot be bound from parameters", fqn)
+}
+
+func invalidParameter(name, reason string) error {
+	return &perrors.ProxyError{
+		Code:    perrors.InvalidParameter,
+		Message: fmt.Sprintf("invalid parameter %s: %s", name, reason),
+	}
+}
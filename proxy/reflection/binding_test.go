@@ -0,0 +1,176 @@
+package reflection
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	perrors "github.com/gdong42/grpc-mate/errors"
+	"github.com/gdong42/grpc-mate/proxy/test"
+	"github.com/gdong42/grpc-mate/route"
+)
+
+func TestReflectorImpl_CreateInvocationWithParams(t *testing.T) {
+	cases := []struct {
+		name       string
+		methodName string
+		input      string
+		params     *route.Params
+		json       string
+		error      *perrors.ProxyError
+	}{
+		{
+			name:       "path params",
+			methodName: "GetBook",
+			params: &route.Params{
+				PathParams: map[string]string{"name": "shelves/1/books/2"},
+			},
+			json: `{"name":"shelves/1/books/2"}`,
+		},
+		{
+			name:       "query params with type coercion",
+			methodName: "ListBooks",
+			params: &route.Params{
+				QueryParams: url.Values{
+					"page_size":        {"10"},
+					"filter.state":     {"PUBLISHED"},
+					"filter.hasAuthor": {"true"},
+					"tags":             {"a", "b"},
+					"published_after":  {"2019-05-01T00:00:00Z"},
+					"min_rating":       {"3"},
+					"cursor":           {"aGVsbG8_"},
+				},
+			},
+			json: `{"pageSize":10,"filter":{"state":"PUBLISHED","hasAuthor":true},"tags":["a","b"],` +
+				`"publishedAfter":"2019-05-01T00:00:00Z","minRating":3,"cursor":"aGVsbG8/"}`,
+		},
+		{
+			name:       "enum by number",
+			methodName: "ListBooks",
+			params: &route.Params{
+				QueryParams: url.Values{"filter.state": {"1"}},
+			},
+			json: `{"filter":{"state":"DRAFT"}}`,
+		},
+		{
+			name:       "path params take precedence over query params",
+			methodName: "ListBooks",
+			params: &route.Params{
+				PathParams:  map[string]string{"parent": "shelves/1"},
+				QueryParams: url.Values{"parent": {"shelves/2"}},
+			},
+			json: `{"parent":"shelves/1"}`,
+		},
+		{
+			name:       "body field",
+			methodName: "UpdateBook",
+			input:      `{"title":"Go","pageCount":"42"}`,
+			params: &route.Params{
+				BodyField:   "book",
+				PathParams:  map[string]string{"book.name": "shelves/1/books/2"},
+				QueryParams: url.Values{"book.title": {"ignored"}},
+			},
+			json: `{"book":{"name":"shelves/1/books/2","title":"Go","pageCount":42}}`,
+		},
+		{
+			name:       "whole body ignores query params",
+			methodName: "ArchiveBook",
+			input:      `{"name":"shelves/1/books/2"}`,
+			params: &route.Params{
+				BodyField:   "*",
+				QueryParams: url.Values{"unknown": {"ignored"}},
+			},
+			json: `{"name":"shelves/1/books/2"}`,
+		},
+		{
+			name:       "invalid number",
+			methodName: "ListBooks",
+			params: &route.Params{
+				QueryParams: url.Values{"page_size": {"ten"}},
+			},
+			error: &perrors.ProxyError{
+				Code:    perrors.InvalidParameter,
+				Message: `invalid parameter page_size: invalid int32 value "ten"`,
+			},
+		},
+		{
+			name:       "invalid enum",
+			methodName: "ListBooks",
+			params: &route.Params{
+				QueryParams: url.Values{"filter.state": {"GONE"}},
+			},
+			error: &perrors.ProxyError{
+				Code: perrors.InvalidParameter,
+				Message: `invalid parameter filter.state: invalid value "GONE" of enum grpcmate.testing.Book.State, ` +
+					`must be one of STATE_UNSPECIFIED, DRAFT, PUBLISHED`,
+			},
+		},
+		{
+			name:       "unknown field",
+			methodName: "ListBooks",
+			params: &route.Params{
+				QueryParams: url.Values{"filter.color": {"red"}},
+			},
+			error: &perrors.ProxyError{
+				Code:    perrors.InvalidParameter,
+				Message: "invalid parameter filter.color: message type grpcmate.testing.ListBooksRequest.Filter has no field named color",
+			},
+		},
+		{
+			name:       "repeated value for singular field",
+			methodName: "ListBooks",
+			params: &route.Params{
+				QueryParams: url.Values{"page_size": {"1", "2"}},
+			},
+			error: &perrors.ProxyError{
+				Code:    perrors.InvalidParameter,
+				Message: "invalid parameter page_size: only a single value is allowed",
+			},
+		},
+		{
+			name:       "map field",
+			methodName: "ListBooks",
+			params: &route.Params{
+				QueryParams: url.Values{"labels": {"a"}},
+			},
+			error: &perrors.ProxyError{
+				Code:    perrors.InvalidParameter,
+				Message: "invalid parameter labels: map fields cannot be bound from parameters",
+			},
+		},
+		{
+			name:       "message field",
+			methodName: "ListBooks",
+			params: &route.Params{
+				QueryParams: url.Values{"filter": {"a"}},
+			},
+			error: &perrors.ProxyError{
+				Code:    perrors.InvalidParameter,
+				Message: "invalid parameter filter: message type grpcmate.testing.ListBooksRequest.Filter cannot be bound from parameters",
+			},
+		},
+	}
+	fd := test.ParseFileDescriptor(t, test.LibraryFile, map[string]string{test.LibraryFile: test.LibraryProto})
+	r := NewReflector(&test.MockGrpcreflectClient{FileDescriptor: fd})
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			i, err := r.CreateInvocation(test.LibraryService, tc.methodName, []byte(tc.input), tc.params)
+			if tc.error != nil {
+				if got, want := err, tc.error; !reflect.DeepEqual(got, error(want)) {
+					t.Fatalf("got %v, want %v", got, want)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			js, err := i.Message.MarshalJSON()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got, want := string(js), tc.json; got != want {
+				t.Fatalf("got %s, want %s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package reflection
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultCacheTTL is the time service descriptors are cached for by default
+const DefaultCacheTTL = 5 * time.Minute
+
+// CacheStats counts the lookups of service descriptors in the descriptor cache
+type CacheStats struct {
+	Hits     uint64 `json:"hits"`
+	Misses   uint64 `json:"misses"`
+	Services int    `json:"services"`
+}
+
+// descriptorCache holds the service descriptors resolved by reflection, along with their methods
+// by name, until they expire or are invalidated. Concurrent misses of a service wait for a single
+// reflection of it.
+type descriptorCache struct {
+	// hits and misses come first to be 64-bit aligned for atomic operations
+	hits, misses uint64
+
+	ttl time.Duration
+	now func() time.Time
+
+	mu       sync.RWMutex
+	services map[string]*cachedService
+	calls    map[string]*resolution
+}
+
+// resolution is a reflection of a service in flight, whose result is shared by the misses of the
+// service until it completes
+type resolution struct {
+	done chan struct{}
+	s    *cachedService
+	err  error
+}
+
+// cachedService is a service descriptor with its methods in order and by name, which are left nil
+// when caching is disabled
+type cachedService struct {
+	desc     *ServiceDescriptor
+	methods  []*MethodDescriptor
+	byName   map[string]*MethodDescriptor
+	expireAt time.Time
+}
+
+func (s *cachedService) getMethods() ([]*MethodDescriptor, error) {
+	if s.methods == nil {
+		return s.desc.GetMethods()
+	}
+	return s.methods, nil
+}
+
+func (s *cachedService) findMethodByName(name string) (*MethodDescriptor, error) {
+	if s.byName == nil {
+		return s.desc.FindMethodByName(name)
+	}
+	m, ok := s.byName[name]
+	if !ok {
+		return nil, methodNotFound(name)
+	}
+	return m, nil
+}
+
+// newDescriptorCache creates a cache whose entries expire after ttl, or never if ttl is 0
+func newDescriptorCache(ttl time.Duration) *descriptorCache {
+	return &descriptorCache{
+		ttl:      ttl,
+		now:      time.Now,
+		services: make(map[string]*cachedService),
+		calls:    make(map[string]*resolution),
+	}
+}
+
+// get returns the cached service, or nil if it is absent or expired
+func (c *descriptorCache) get(serviceName string) *cachedService {
+	c.mu.RLock()
+	s, ok := c.services[serviceName]
+	c.mu.RUnlock()
+	if !ok || (!s.expireAt.IsZero() && !c.now().Before(s.expireAt)) {
+		atomic.AddUint64(&c.misses, 1)
+		return nil
+	}
+	atomic.AddUint64(&c.hits, 1)
+	return s
+}
+
+// load returns the cached service, or resolves and caches it on a miss. Misses of a service being
+// resolved wait for that resolution rather than starting their own.
+func (c *descriptorCache) load(serviceName string,
+	resolve func(serviceName string) (*ServiceDescriptor, error)) (*cachedService, error) {
+
+	if s := c.get(serviceName); s != nil {
+		return s, nil
+	}
+	c.mu.Lock()
+	if r, ok := c.calls[serviceName]; ok {
+		c.mu.Unlock()
+		<-r.done
+		return r.s, r.err
+	}
+	r := &resolution{done: make(chan struct{})}
+	c.calls[serviceName] = r
+	c.mu.Unlock()
+
+	d, err := resolve(serviceName)
+	if err == nil {
+		r.s = c.newCachedService(d)
+	}
+	r.err = err
+	c.mu.Lock()
+	// a resolution which has been invalidated meanwhile may be outdated, and is not cached
+	if c.calls[serviceName] == r {
+		delete(c.calls, serviceName)
+		if r.s != nil {
+			c.services[serviceName] = r.s
+		}
+	}
+	c.mu.Unlock()
+	close(r.done)
+	return r.s, r.err
+}
+
+// newCachedService returns the service descriptor with its methods, expiring after the ttl
+func (c *descriptorCache) newCachedService(d *ServiceDescriptor) *cachedService {
+	methods, _ := d.GetMethods()
+	s := &cachedService{
+		desc:    d,
+		methods: methods,
+		byName:  make(map[string]*MethodDescriptor, len(methods)),
+	}
+	for _, m := range methods {
+		s.byName[m.GetName()] = m
+	}
+	if c.ttl > 0 {
+		s.expireAt = c.now().Add(c.ttl)
+	}
+	return s
+}
+
+// invalidate removes the services from the cache, or all of them if none is given
+func (c *descriptorCache) invalidate(serviceNames ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(serviceNames) == 0 {
+		c.services = make(map[string]*cachedService)
+		c.calls = make(map[string]*resolution)
+		return
+	}
+	for _, name := range serviceNames {
+		delete(c.services, name)
+		delete(c.calls, name)
+	}
+}
+
+func (c *descriptorCache) stats() CacheStats {
+	c.mu.RLock()
+	n := len(c.services)
+	c.mu.RUnlock()
+	return CacheStats{
+		Hits:     atomic.LoadUint64(&c.hits),
+		Misses:   atomic.LoadUint64(&c.misses),
+		Services: n,
+	}
+}
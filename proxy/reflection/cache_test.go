@@ -0,0 +1,166 @@
+package reflection
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	perrors "github.com/gdong42/grpc-mate/errors"
+	"github.com/gdong42/grpc-mate/proxy/test"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/pkg/errors"
+)
+
+// countingClient counts the services resolved by reflection
+type countingClient struct {
+	test.MockGrpcreflectClient
+	resolved int
+}
+
+func (c *countingClient) ResolveService(serviceName string) (*desc.ServiceDescriptor, error) {
+	c.resolved++
+	return c.MockGrpcreflectClient.ResolveService(serviceName)
+}
+
+func TestReflectorImpl_Cache(t *testing.T) {
+	cases := []struct {
+		name     string
+		opts     []Option
+		elapsed  time.Duration
+		resolved int
+		stats    CacheStats
+	}{
+		{
+			name:     "cached",
+			elapsed:  time.Minute,
+			resolved: 1,
+			stats:    CacheStats{Hits: 2, Misses: 1, Services: 1},
+		},
+		{
+			name:     "expired",
+			opts:     []Option{WithCacheTTL(time.Minute)},
+			elapsed:  time.Minute,
+			resolved: 2,
+			stats:    CacheStats{Hits: 1, Misses: 2, Services: 1},
+		},
+		{
+			name:     "never expires",
+			opts:     []Option{WithCacheTTL(0)},
+			elapsed:  time.Hour,
+			resolved: 1,
+			stats:    CacheStats{Hits: 2, Misses: 1, Services: 1},
+		},
+		{
+			name:     "disabled",
+			opts:     []Option{WithCacheTTL(-1)},
+			resolved: 3,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rc := &countingClient{MockGrpcreflectClient: test.MockGrpcreflectClient{
+				FileDescriptor: test.NewFileDescriptor(t, test.File),
+			}}
+			r := NewReflector(rc, tc.opts...).(*reflectorImpl)
+			now := time.Now()
+			if r.cache != nil {
+				r.cache.now = func() time.Time { return now }
+			}
+
+			if _, err := r.ResolveMethod(test.TestService, test.EmptyCall); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := r.DescribeService(test.TestService); err != nil {
+				t.Fatal(err)
+			}
+			now = now.Add(tc.elapsed)
+			if _, err := r.ResolveMethod(test.TestService, test.EmptyCall); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := rc.resolved, tc.resolved; got != want {
+				t.Fatalf("got %d resolutions, want %d", got, want)
+			}
+			if got, want := r.CacheStats(), tc.stats; got != want {
+				t.Fatalf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+// blockingClient counts the services resolved by reflection, which complete once released
+type blockingClient struct {
+	test.MockGrpcreflectClient
+	resolved int32
+	release  chan struct{}
+}
+
+func (c *blockingClient) ResolveService(serviceName string) (*desc.ServiceDescriptor, error) {
+	atomic.AddInt32(&c.resolved, 1)
+	<-c.release
+	return c.MockGrpcreflectClient.ResolveService(serviceName)
+}
+
+func TestReflectorImpl_ConcurrentMisses(t *testing.T) {
+	rc := &blockingClient{
+		MockGrpcreflectClient: test.MockGrpcreflectClient{
+			FileDescriptor: test.NewFileDescriptor(t, test.File),
+		},
+		release: make(chan struct{}),
+	}
+	r := NewReflector(rc)
+
+	const n = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := r.ResolveMethod(test.TestService, test.EmptyCall)
+			errs <- err
+		}()
+	}
+	// release the reflection once every call has missed the cache
+	for r.CacheStats().Misses < n {
+		time.Sleep(time.Millisecond)
+	}
+	close(rc.release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := atomic.LoadInt32(&rc.resolved), int32(1); got != want {
+		t.Fatalf("got %d resolutions, want %d", got, want)
+	}
+	if got, want := r.CacheStats().Services, 1; got != want {
+		t.Fatalf("got %d services, want %d", got, want)
+	}
+}
+
+func TestReflectorImpl_Invalidate(t *testing.T) {
+	rc := &countingClient{MockGrpcreflectClient: test.MockGrpcreflectClient{
+		FileDescriptor: test.NewFileDescriptor(t, test.File),
+	}}
+	r := NewReflector(rc)
+
+	if _, err := r.ResolveMethod(test.TestService, test.NotFoundCall); err == nil {
+		t.Fatal("err should not be nil")
+	} else if e, ok := errors.Cause(err).(*perrors.ProxyError); !ok || e.Code != perrors.MethodNotFound {
+		t.Fatalf("got %v, want method not found", err)
+	}
+	r.Invalidate("other.Service")
+	if _, err := r.ResolveMethod(test.TestService, test.EmptyCall); err != nil {
+		t.Fatal(err)
+	}
+	r.Invalidate()
+	if _, err := r.ResolveMethod(test.TestService, test.EmptyCall); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := rc.resolved, 2; got != want {
+		t.Fatalf("got %d resolutions, want %d", got, want)
+	}
+}
@@ -1,15 +1,21 @@
 package reflection
 
 import (
+	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/fullstorydev/grpcurl"
+	"github.com/golang/protobuf/jsonpb"
 	"github.com/golang/protobuf/proto"
 	"github.com/jhump/protoreflect/desc"
 	"github.com/jhump/protoreflect/dynamic"
 	"github.com/pkg/errors"
+	"google.golang.org/genproto/googleapis/api/annotations"
 
 	perrors "github.com/gdong42/grpc-mate/errors"
+	"github.com/gdong42/grpc-mate/route"
 )
 
 // MethodInvocation contains a method and a message used to invoke an RPC
@@ -21,37 +27,62 @@ type MethodInvocation struct {
 // Reflector performs reflection on the gRPC service to obtain the method
 // type, services and methods
 type Reflector interface {
-	CreateInvocation(serviceName, methodName string, input []byte) (*MethodInvocation, error)
+	CreateInvocation(serviceName, methodName string, input []byte, params *route.Params) (*MethodInvocation, error)
+	ResolveMethod(serviceName, methodName string) (*MethodDescriptor, error)
 	ListServices() ([]string, error)
 	DescribeService(serviceName string) ([]*MethodDescriptor, error)
+	// Invalidate removes the services from the descriptor cache, or all of them if none is given,
+	// so that they are reflected again
+	Invalidate(serviceNames ...string)
+	// CacheStats returns the statistics of the descriptor cache
+	CacheStats() CacheStats
+}
+
+// Option configures a Reflector
+type Option func(*reflectorImpl)
+
+// WithCacheTTL sets the time service descriptors are cached for, forever if ttl is 0. A negative
+// ttl disables the cache, so that reflection is performed every time a service is resolved.
+func WithCacheTTL(ttl time.Duration) Option {
+	return func(r *reflectorImpl) {
+		if ttl < 0 {
+			r.cache = nil
+			return
+		}
+		r.cache = newDescriptorCache(ttl)
+	}
 }
 
-// NewReflector creates a new Reflector from the reflection client
-func NewReflector(rc grpcreflectClient) Reflector {
-	return &reflectorImpl{
-		rc: newReflectionClient(rc),
+// NewReflector creates a new Reflector from the reflection client, which caches service
+// descriptors for DefaultCacheTTL unless configured otherwise
+func NewReflector(rc grpcreflectClient, opts ...Option) Reflector {
+	r := &reflectorImpl{
+		rc:    newReflectionClient(rc),
+		cache: newDescriptorCache(DefaultCacheTTL),
+	}
+	for _, opt := range opts {
+		opt(r)
 	}
+	return r
 }
 
 type reflectorImpl struct {
-	rc *reflectionClient
+	rc    *reflectionClient
+	cache *descriptorCache
 }
 
-// CreateInvocation creates a MethodInvocation by performing reflection
+// CreateInvocation creates a MethodInvocation by performing reflection, with the input message
+// created by CreateInputMessage
 func (r *reflectorImpl) CreateInvocation(serviceName,
 	methodName string,
 	input []byte,
+	params *route.Params,
 ) (*MethodInvocation, error) {
-	serviceDesc, err := r.rc.resolveService(serviceName)
-	if err != nil {
-		return nil, errors.Wrap(err, "service was not found upstream even though it should have been there")
-	}
-	methodDesc, err := serviceDesc.FindMethodByName(methodName)
+	methodDesc, err := r.ResolveMethod(serviceName, methodName)
 	if err != nil {
-		return nil, errors.Wrap(err, "method not found upstream")
+		return nil, err
 	}
-	inputMessage := methodDesc.GetInputType().NewMessage()
-	err = inputMessage.UnmarshalJSON(input)
+	inputMessage, err := methodDesc.CreateInputMessage(input, params)
 	if err != nil {
 		return nil, err
 	}
@@ -61,22 +92,62 @@ func (r *reflectorImpl) CreateInvocation(serviceName,
 	}, nil
 }
 
+// ResolveMethod finds the method of the service by performing reflection, unless the service is
+// cached
+func (r *reflectorImpl) ResolveMethod(serviceName, methodName string) (*MethodDescriptor, error) {
+	s, err := r.resolveService(serviceName)
+	if err != nil {
+		return nil, errors.Wrap(err, "service was not found upstream even though it should have been there")
+	}
+	methodDesc, err := s.findMethodByName(methodName)
+	if err != nil {
+		return nil, errors.Wrap(err, "method not found upstream")
+	}
+	return methodDesc, nil
+}
+
 func (r *reflectorImpl) ListServices() ([]string, error) {
 	return r.rc.listServices()
 }
 
 func (r *reflectorImpl) DescribeService(serviceName string) ([]*MethodDescriptor, error) {
-	serviceDesc, err := r.rc.resolveService(serviceName)
+	s, err := r.resolveService(serviceName)
 	if err != nil {
 		return nil, errors.Wrap(err, "service was not found upstream even though it should have been there")
 	}
-	methodDescs, err := serviceDesc.GetMethods()
+	methodDescs, err := s.getMethods()
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot get methods from service "+serviceName)
 	}
 	return methodDescs, nil
 }
 
+func (r *reflectorImpl) Invalidate(serviceNames ...string) {
+	if r.cache != nil {
+		r.cache.invalidate(serviceNames...)
+	}
+}
+
+func (r *reflectorImpl) CacheStats() CacheStats {
+	if r.cache == nil {
+		return CacheStats{}
+	}
+	return r.cache.stats()
+}
+
+// resolveService returns the service from the cache, or performs reflection to obtain it, caching
+// it if the cache is enabled
+func (r *reflectorImpl) resolveService(serviceName string) (*cachedService, error) {
+	if r.cache != nil {
+		return r.cache.load(serviceName, r.rc.resolveService)
+	}
+	d, err := r.rc.resolveService(serviceName)
+	if err != nil {
+		return nil, err
+	}
+	return &cachedService{desc: d}, nil
+}
+
 // reflectionClient performs reflection to obtain descriptors
 type reflectionClient struct {
 	grpcreflectClient
@@ -152,16 +223,20 @@ func (s *ServiceDescriptor) GetMethods() ([]*MethodDescriptor, error) {
 func (s *ServiceDescriptor) FindMethodByName(name string) (*MethodDescriptor, error) {
 	d := s.ServiceDescriptor.FindMethodByName(name)
 	if d == nil {
-		return nil, &perrors.ProxyError{
-			Code:    perrors.MethodNotFound,
-			Message: fmt.Sprintf("the method %s was not found", name),
-		}
+		return nil, methodNotFound(name)
 	}
 	return &MethodDescriptor{
 		MethodDescriptor: d,
 	}, nil
 }
 
+func methodNotFound(name string) error {
+	return &perrors.ProxyError{
+		Code:    perrors.MethodNotFound,
+		Message: fmt.Sprintf("the method %s was not found", name),
+	}
+}
+
 // MethodDescriptor represents a method type
 type MethodDescriptor struct {
 	*desc.MethodDescriptor
@@ -181,6 +256,23 @@ func (m *MethodDescriptor) GetOutputType() *MessageDescriptor {
 	}
 }
 
+// CreateInputMessage creates an input message of the method. The message is unmarshaled from the
+// JSON input when params is nil, otherwise it is bound from the JSON input and the path and query
+// parameters as described by params.
+func (m *MethodDescriptor) CreateInputMessage(input []byte, params *route.Params) (Message, error) {
+	inputMessage := m.GetInputType().NewMessage()
+	var err error
+	if params == nil {
+		err = inputMessage.UnmarshalJSON(input)
+	} else {
+		err = bindRequest(inputMessage.Message, input, params)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return inputMessage, nil
+}
+
 // AsProtoreflectDescriptor returns the underlying protoreflect method descriptor
 func (m *MethodDescriptor) AsProtoreflectDescriptor() *desc.MethodDescriptor {
 	return m.MethodDescriptor
@@ -191,11 +283,34 @@ func (m *MethodDescriptor) GetName() string {
 	return m.MethodDescriptor.GetName()
 }
 
+// GetHTTPRules returns the google.api.http rule of the method, followed by its additional bindings,
+// or nil if the method has no such option
+func (m *MethodDescriptor) GetHTTPRules() []*annotations.HttpRule {
+	opts := m.MethodDescriptor.GetMethodOptions()
+	if opts == nil || !proto.HasExtension(opts, annotations.E_Http) {
+		return nil
+	}
+	ext, err := proto.GetExtension(opts, annotations.E_Http)
+	if err != nil {
+		return nil
+	}
+	rule, ok := ext.(*annotations.HttpRule)
+	if !ok {
+		return nil
+	}
+	return append([]*annotations.HttpRule{rule}, rule.GetAdditionalBindings()...)
+}
+
 // MessageDescriptor represents a message type
 type MessageDescriptor struct {
 	desc *desc.MessageDescriptor
 }
 
+// NewMessageDescriptor wraps the protoreflect message descriptor
+func NewMessageDescriptor(md *desc.MessageDescriptor) *MessageDescriptor {
+	return &MessageDescriptor{desc: md}
+}
+
 // NewMessage creates a new message from the message descriptor
 func (m *MessageDescriptor) NewMessage() *messageImpl {
 	return &messageImpl{
@@ -208,6 +323,34 @@ func (m *MessageDescriptor) GetFullyQualifiedName() string {
 	return m.desc.GetFullyQualifiedName()
 }
 
+// GetFieldPaths returns the field paths of the message, descending into sub messages up to
+// the given depth, e.g. name, book, book.name for a depth of 2
+func (m *MessageDescriptor) GetFieldPaths(depth int) []string {
+	return fieldPaths(m.desc, "", depth)
+}
+
+// HasFieldPath tells if the field path, e.g. book.name, denotes a field of the message, through
+// singular message fields
+func (m *MessageDescriptor) HasFieldPath(fieldPath string) bool {
+	_, _, err := resolveFieldPath(dynamic.NewMessage(m.desc), fieldPath)
+	return err == nil
+}
+
+func fieldPaths(md *desc.MessageDescriptor, prefix string, depth int) []string {
+	if depth <= 0 {
+		return nil
+	}
+	var paths []string
+	for _, fd := range md.GetFields() {
+		p := prefix + fd.GetName()
+		paths = append(paths, p)
+		if fd.GetMessageType() != nil && !fd.IsRepeated() {
+			paths = append(paths, fieldPaths(fd.GetMessageType(), p+".", depth-1)...)
+		}
+	}
+	return paths
+}
+
 // MakeTemplateMessage makes a message template for this message, to make it easier to
 // create a request to invoke an RPC
 func (m *MessageDescriptor) MakeTemplateMessage(descSource grpcurl.DescriptorSource) proto.Message {
@@ -218,7 +361,9 @@ func (m *MessageDescriptor) MakeTemplateMessage(descSource grpcurl.DescriptorSou
 // create a request to invoke an RPC
 func (m *MessageDescriptor) MakeTemplate(descSource grpcurl.DescriptorSource) (string, error) {
 	tmpl := grpcurl.MakeTemplate(m.desc)
-	_, formatter, err := grpcurl.RequestParserAndFormatterFor(grpcurl.FormatJSON, descSource, true, false, nil)
+	// no request is parsed, but the parser needs a reader
+	_, formatter, err := grpcurl.RequestParserAndFormatterFor(grpcurl.FormatJSON, descSource, true, false,
+		strings.NewReader(""))
 	if err != nil {
 		return "", &perrors.ProxyError{
 			Code:    perrors.Unknown,
@@ -239,6 +384,9 @@ func (m *MessageDescriptor) MakeTemplate(descSource grpcurl.DescriptorSource) (s
 type Message interface {
 	// MarshalJSON marshals the Message into JSON
 	MarshalJSON() ([]byte, error)
+	// MarshalFieldJSON marshals the field denoted by the field path into its JSON value, null if it
+	// has none
+	MarshalFieldJSON(fieldPath string) ([]byte, error)
 	// UnmarshalJSON unmarshals JSON into a Message
 	UnmarshalJSON(b []byte) error
 	// ConvertFrom converts a raw protobuf message into a Message
@@ -263,14 +411,43 @@ func (m *messageImpl) MarshalJSON() ([]byte, error) {
 	return b, nil
 }
 
-func (m *messageImpl) UnmarshalJSON(b []byte) error {
-	if err := m.Message.UnmarshalJSON(b); err != nil {
-		return &perrors.ProxyError{
-			Code:    perrors.MessageTypeMismatch,
-			Message: "input JSON does not match messageImpl type",
+func (m *messageImpl) MarshalFieldJSON(fieldPath string) ([]byte, error) {
+	parent, fd, err := resolveFieldPath(m.Message, fieldPath)
+	if err != nil {
+		return nil, &perrors.ProxyError{
+			Code:    perrors.Unknown,
+			Message: fmt.Sprintf("invalid response body field %s", fieldPath),
+		}
+	}
+	// the field is marshaled alone in a message of the parent type, with its default value when
+	// it is not set, and its JSON value taken from the JSON object
+	tmp := dynamic.NewMessage(parent.GetMessageDescriptor())
+	set := parent.HasField(fd)
+	if set {
+		if err := tmp.TrySetField(fd, parent.GetField(fd)); err != nil {
+			return nil, &perrors.ProxyError{Code: perrors.Unknown, Message: err.Error()}
 		}
 	}
-	return nil
+	b, err := tmp.MarshalJSONPB(&jsonpb.Marshaler{EmitDefaults: !set})
+	var members map[string]json.RawMessage
+	if err == nil {
+		err = json.Unmarshal(b, &members)
+	}
+	if err != nil {
+		return nil, &perrors.ProxyError{
+			Code:    perrors.Unknown,
+			Message: "could not marshal backend response into JSON",
+		}
+	}
+	// fields left out despite EmitDefaults, e.g. unset oneof fields, are null
+	if v, ok := members[fd.GetJSONName()]; ok {
+		return v, nil
+	}
+	return []byte("null"), nil
+}
+
+func (m *messageImpl) UnmarshalJSON(b []byte) error {
+	return unmarshalJSON(m.Message, b)
 }
 
 func (m *messageImpl) ConvertFrom(target proto.Message) error {
@@ -64,7 +64,7 @@ func TestReflectorImpl_CreateInvocation(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			fd := test.NewFileDescriptor(t, test.File)
 			r := NewReflector(&test.MockGrpcreflectClient{FileDescriptor: fd})
-			i, err := r.CreateInvocation(tc.serviceName, tc.methodName, []byte(tc.message))
+			i, err := r.CreateInvocation(tc.serviceName, tc.methodName, []byte(tc.message), nil)
 			if got, want := i == nil, tc.invocationIsNil; got != want {
 				t.Fatalf("got %t, want %t", got, want)
 			}
@@ -262,7 +262,7 @@ func TestMethodDescriptor_GetInputType(t *testing.T) {
 			serviceDesc := ServiceDescriptorFromFileDescriptor(file, tc.serviceName)
 			methodDesc, err := serviceDesc.FindMethodByName(tc.methodName)
 			if err != nil {
-				t.Fatalf(err.Error())
+				t.Fatal(err.Error())
 			}
 			inputMsgDesc := methodDesc.GetInputType()
 			if got, want := inputMsgDesc == nil, tc.descIsNil; got != want {
@@ -292,7 +292,7 @@ func TestMethodDescriptor_GetOutputType(t *testing.T) {
 			serviceDesc := ServiceDescriptorFromFileDescriptor(file, tc.serviceName)
 			methodDesc, err := serviceDesc.FindMethodByName(tc.methodName)
 			if err != nil {
-				t.Fatalf(err.Error())
+				t.Fatal(err.Error())
 			}
 			inputMsgDesc := methodDesc.GetOutputType()
 			if got, want := inputMsgDesc == nil, tc.descIsNil; got != want {
@@ -322,7 +322,7 @@ func TestMethodDescriptor_GetName(t *testing.T) {
 			serviceDesc := ServiceDescriptorFromFileDescriptor(file, tc.serviceName)
 			methodDesc, err := serviceDesc.FindMethodByName(tc.methodName)
 			if err != nil {
-				t.Fatalf(err.Error())
+				t.Fatal(err.Error())
 			}
 
 			if got, want := methodDesc.GetName(), tc.methodName; got != want {
@@ -332,6 +332,57 @@ func TestMethodDescriptor_GetName(t *testing.T) {
 	}
 }
 
+func TestMethodDescriptor_GetHTTPRules(t *testing.T) {
+	cases := []struct {
+		name       string
+		methodName string
+		rules      []string
+	}{
+		{
+			name:       "custom pattern",
+			methodName: "ArchiveBook",
+			rules:      []string{"custom:<kind:\"archive\" path:\"/v1/{name=shelves/*/books/*}:archive\" > body:\"*\" "},
+		},
+		{
+			name:       "additional bindings",
+			methodName: "ListBooks",
+			rules: []string{
+				"get:\"/v1/{parent=shelves/*}/books\" additional_bindings:<get:\"/v1/books\" > ",
+				"get:\"/v1/books\" ",
+			},
+		},
+	}
+	file := test.ParseFileDescriptor(t, test.LibraryFile, map[string]string{test.LibraryFile: test.LibraryProto})
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			serviceDesc := ServiceDescriptorFromFileDescriptor(file, test.LibraryService)
+			methodDesc, err := serviceDesc.FindMethodByName(tc.methodName)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			var rules []string
+			for _, r := range methodDesc.GetHTTPRules() {
+				rules = append(rules, r.String())
+			}
+			if got, want := rules, tc.rules; !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %q, want %q", got, want)
+			}
+		})
+	}
+
+	t.Run("without option", func(t *testing.T) {
+		file := test.NewFileDescriptor(t, test.File)
+		serviceDesc := ServiceDescriptorFromFileDescriptor(file, test.TestService)
+		methodDesc, err := serviceDesc.FindMethodByName(test.EmptyCall)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if got := methodDesc.GetHTTPRules(); got != nil {
+			t.Fatalf("got %v, want nil", got)
+		}
+	})
+}
+
 func TestMessageDescriptor_NewMessage(t *testing.T) {
 	file := test.NewFileDescriptor(t, test.File)
 	serviceDesc := ServiceDescriptorFromFileDescriptor(file, test.TestService)
@@ -340,7 +391,7 @@ func TestMessageDescriptor_NewMessage(t *testing.T) {
 	}
 	methodDesc, err := serviceDesc.FindMethodByName(test.EmptyCall)
 	if err != nil {
-		t.Fatalf(err.Error())
+		t.Fatal(err.Error())
 	}
 	inputMsgDesc := methodDesc.GetInputType()
 	inputMsg := inputMsgDesc.NewMessage()
@@ -357,7 +408,7 @@ func TestMessageDescriptor_GetFullyQualifiedName(t *testing.T) {
 	}
 	methodDesc, err := serviceDesc.FindMethodByName(test.UnaryCall)
 	if err != nil {
-		t.Fatalf(err.Error())
+		t.Fatal(err.Error())
 	}
 	inputMsgDesc := methodDesc.GetInputType()
 	name := inputMsgDesc.GetFullyQualifiedName()
@@ -400,6 +451,71 @@ func TestMessage_MarshalJSON(t *testing.T) {
 	}
 }
 
+func TestMessage_MarshalFieldJSON(t *testing.T) {
+	file := test.NewFileDescriptor(t, test.File)
+	cases := []struct {
+		name      string
+		fieldPath string
+		// unset tells if the payload of the message is left unset
+		unset bool
+		json  string
+		error
+	}{
+		{
+			name:      "message field",
+			fieldPath: "payload",
+			json:      `{"body":"aGVsbG8="}`,
+		},
+		{
+			name:      "sub field",
+			fieldPath: "payload.body",
+			json:      `"aGVsbG8="`,
+		},
+		{
+			name:      "unset field",
+			fieldPath: "username",
+			json:      `""`,
+		},
+		{
+			name:      "unset message field",
+			fieldPath: "payload",
+			unset:     true,
+			json:      `null`,
+		},
+		{
+			name:      "unknown field",
+			fieldPath: "nope",
+			error: &perrors.ProxyError{
+				Code:    perrors.Unknown,
+				Message: "invalid response body field nope",
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			messageDesc := file.FindMessage("grpc.testing.SimpleResponse")
+			if messageDesc == nil {
+				t.Fatal("messageImpl descriptor is nil")
+			}
+			message := messageImpl{
+				Message: dynamic.NewMessage(messageDesc),
+			}
+			if !tc.unset {
+				payload := dynamic.NewMessage(file.FindMessage(test.MessageName))
+				payload.SetFieldByName("body", []byte("hello"))
+				message.Message.SetFieldByName("payload", payload)
+			}
+			j, err := message.MarshalFieldJSON(tc.fieldPath)
+			if got, want := err, tc.error; !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+			if got, want := string(j), tc.json; got != want {
+				t.Fatalf("got %s, want %s", got, want)
+			}
+		})
+	}
+}
+
 func TestMessage_UnmarshalJSON(t *testing.T) {
 	file := test.NewFileDescriptor(t, test.File)
 	cases := []struct {
@@ -0,0 +1,159 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	perrors "github.com/gdong42/grpc-mate/errors"
+	"github.com/gdong42/grpc-mate/proxy/reflection"
+	"github.com/gdong42/grpc-mate/route"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/api/annotations"
+)
+
+// Routes returns the route table built from google.api.http options of all upstream methods.
+// The table is built on first use and cached afterwards, until the upstream schema or the policy
+// changes. Methods the policy does not expose have no routes.
+func (p *Proxy) Routes() (*route.Table, error) {
+	p.mu.RLock()
+	routes, reflector, policy := p.routes, p.reflector, p.policy
+	p.mu.RUnlock()
+	if routes != nil {
+		return routes, nil
+	}
+	if !p.IsReady() {
+		return nil, &perrors.ProxyError{
+			Code:    perrors.UpstreamConnFailure,
+			Message: "service down",
+		}
+	}
+	built, err := buildRoutes(withPolicy(reflector, policy), p.mapper, p.logger)
+	if err != nil {
+		return nil, err
+	}
+	routes = route.NewTable(built)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// the table is kept unless the schema or the policy changed while it was built
+	if p.routes == nil && p.reflector == reflector && p.policy == policy {
+		p.routes = routes
+	}
+	return routes, nil
+}
+
+// Method describes the method of the service
+func (p *Proxy) Method(serviceName, methodName string) (*route.Method, error) {
+	md, err := p.getReflector().ResolveMethod(serviceName, methodName)
+	if err != nil {
+		return nil, err
+	}
+	return newMethod(serviceName, md), nil
+}
+
+// buildRoutes creates routes for every HTTP rule of every method the reflector knows about. Methods
+// without HTTP rules get the routes derived by the mapper, if any, which come after all the routes
+// from HTTP rules so that the latter take precedence. Invalid HTTP rules are logged and skipped, and
+// so are routes with the HTTP method and path pattern of an earlier route, which they would be
+// shadowed by, e.g. the routes derived for methods of different services with the same name.
+func buildRoutes(r reflection.Reflector, mapper route.Mapper, logger *zap.Logger) ([]*route.Route, error) {
+	services, err := r.ListServices()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list services")
+	}
+	var routes, mapped []*route.Route
+	for _, svc := range services {
+		mds, err := r.DescribeService(svc)
+		if err != nil {
+			return nil, err
+		}
+		for _, md := range mds {
+			rules := md.GetHTTPRules()
+			for _, rule := range rules {
+				rt, err := newRoute(svc, md.GetName(), rule)
+				if err == nil && rt.ResponseBody != "" && !md.GetOutputType().HasFieldPath(rt.ResponseBody) {
+					err = fmt.Errorf("response body field %s not found in %s", rt.ResponseBody,
+						md.GetOutputType().GetFullyQualifiedName())
+				}
+				if err != nil {
+					logger.Warn("skipping invalid google.api.http option",
+						zap.String("method", svc+"/"+md.GetName()), zap.Error(err))
+					continue
+				}
+				routes = append(routes, rt)
+			}
+			if len(rules) == 0 && mapper != nil {
+				mapped = append(mapped, mapper(&route.Method{
+					Service:         svc,
+					Name:            md.GetName(),
+					InputFields:     md.GetInputType().GetFieldPaths(2),
+					ClientStreaming: md.IsClientStreaming(),
+					ServerStreaming: md.IsServerStreaming(),
+				})...)
+			}
+		}
+	}
+	return dedupRoutes(append(routes, mapped...), logger), nil
+}
+
+// dedupRoutes removes the routes with the HTTP method and path pattern of an earlier route, logging
+// them
+func dedupRoutes(routes []*route.Route, logger *zap.Logger) []*route.Route {
+	seen := make(map[string]*route.Route, len(routes))
+	deduped := routes[:0]
+	for _, rt := range routes {
+		key := rt.HTTPMethod + " " + rt.Template.Pattern()
+		if prev, ok := seen[key]; ok {
+			logger.Warn("skipping duplicate route",
+				zap.String("method", rt.Service+"/"+rt.Method), zap.String("route", rt.String()),
+				zap.String("shadowed_by", prev.Service+"/"+prev.Method))
+			continue
+		}
+		seen[key] = rt
+		deduped = append(deduped, rt)
+	}
+	return deduped
+}
+
+func newMethod(service string, md *reflection.MethodDescriptor) *route.Method {
+	return &route.Method{
+		Service:         service,
+		Name:            md.GetName(),
+		InputFields:     md.GetInputType().GetFieldPaths(2),
+		ClientStreaming: md.IsClientStreaming(),
+		ServerStreaming: md.IsServerStreaming(),
+	}
+}
+
+func newRoute(service, method string, rule *annotations.HttpRule) (*route.Route, error) {
+	var httpMethod, path string
+	switch p := rule.GetPattern().(type) {
+	case *annotations.HttpRule_Get:
+		httpMethod, path = http.MethodGet, p.Get
+	case *annotations.HttpRule_Put:
+		httpMethod, path = http.MethodPut, p.Put
+	case *annotations.HttpRule_Post:
+		httpMethod, path = http.MethodPost, p.Post
+	case *annotations.HttpRule_Delete:
+		httpMethod, path = http.MethodDelete, p.Delete
+	case *annotations.HttpRule_Patch:
+		httpMethod, path = http.MethodPatch, p.Patch
+	case *annotations.HttpRule_Custom:
+		httpMethod, path = strings.ToUpper(p.Custom.GetKind()), p.Custom.GetPath()
+	default:
+		return nil, fmt.Errorf("no pattern specified")
+	}
+	tmpl, err := route.ParseTemplate(path)
+	if err != nil {
+		return nil, err
+	}
+	return &route.Route{
+		HTTPMethod:   httpMethod,
+		Template:     tmpl,
+		Service:      service,
+		Method:       method,
+		Body:         rule.GetBody(),
+		ResponseBody: rule.GetResponseBody(),
+	}, nil
+}
@@ -0,0 +1,227 @@
+package proxy
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	perrors "github.com/gdong42/grpc-mate/errors"
+	mhttp "github.com/gdong42/grpc-mate/http"
+	"github.com/gdong42/grpc-mate/proxy/reflection"
+	"github.com/gdong42/grpc-mate/proxy/test"
+	"github.com/gdong42/grpc-mate/route"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+func TestBuildRoutes(t *testing.T) {
+	fd := test.ParseFileDescriptor(t, test.LibraryFile, map[string]string{test.LibraryFile: test.LibraryProto})
+	r := reflection.NewReflector(&test.MockGrpcreflectClient{FileDescriptor: fd})
+	routes, err := buildRoutes(r, nil, zap.NewNop())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := make([]string, len(routes))
+	for i, rt := range routes {
+		got[i] = rt.Service + "/" + rt.Method + " " + rt.String() + " body=" + rt.Body
+	}
+	want := []string{
+		"grpcmate.testing.Library/GetBook GET /v1/{name=shelves/*/books/*} body=",
+		"grpcmate.testing.Library/ListBooks GET /v1/{parent=shelves/*}/books body=",
+		"grpcmate.testing.Library/ListBooks GET /v1/books body=",
+		"grpcmate.testing.Library/CreateBook POST /v1/{parent=shelves/*}/books body=book",
+		"grpcmate.testing.Library/UpdateBook PATCH /v1/{book.name=shelves/*/books/*} body=book",
+		"grpcmate.testing.Library/DeleteBook DELETE /v1/{name=shelves/*/books/*} body=",
+		"grpcmate.testing.Library/ArchiveBook ARCHIVE /v1/{name=shelves/*/books/*}:archive body=*",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestBuildRoutesSkipsInvalidRules(t *testing.T) {
+	fd := test.ParseFileDescriptor(t, "invalid.proto", map[string]string{"invalid.proto": `syntax = "proto3";
+
+package grpcmate.testing;
+
+import "google/api/annotations.proto";
+
+message Request {
+  string name = 1;
+}
+
+service Invalid {
+  rpc Valid(Request) returns (Request) {
+    option (google.api.http) = { get: "/v1/{name=items/*}" };
+  }
+  rpc InvalidTemplate(Request) returns (Request) {
+    option (google.api.http) = {
+      get: "/v1/{name"
+      additional_bindings { get: "/v1/invalid/{name}" }
+    };
+  }
+  rpc NoPattern(Request) returns (Request) {
+    option (google.api.http) = { body: "*" };
+  }
+  rpc ResponseBody(Request) returns (Request) {
+    option (google.api.http) = {
+      get: "/v1/response/{name}"
+      response_body: "name"
+      additional_bindings { get: "/v1/unknown/{name}" response_body: "unknown" }
+    };
+  }
+}
+`})
+	r := reflection.NewReflector(&test.MockGrpcreflectClient{FileDescriptor: fd})
+	routes, err := buildRoutes(r, nil, zap.NewNop())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := make([]string, len(routes))
+	for i, rt := range routes {
+		got[i] = rt.Method + " " + rt.String()
+	}
+	want := []string{
+		"Valid GET /v1/{name=items/*}",
+		"InvalidTemplate GET /v1/invalid/{name}",
+		"ResponseBody GET /v1/response/{name}",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestBuildRoutesSkipsDuplicateRoutes(t *testing.T) {
+	fd := test.ParseFileDescriptor(t, "duplicate.proto", map[string]string{"duplicate.proto": `syntax = "proto3";
+
+package grpcmate.testing;
+
+import "google/api/annotations.proto";
+
+message GetBookRequest {
+  string name = 1;
+}
+
+message Book {
+  string id = 1;
+}
+
+service Shelf {
+  rpc GetBook(GetBookRequest) returns (Book);
+  rpc ListBooks(GetBookRequest) returns (Book) {
+    option (google.api.http) = { get: "/v1/{name=shelves/*}/books" };
+  }
+}
+
+service Store {
+  rpc GetBook(Book) returns (Book);
+  rpc CreateBook(Book) returns (Book);
+  rpc ListBooks(GetBookRequest) returns (Book) {
+    option (google.api.http) = { get: "/v1/{parent=shelves/*}/books" };
+  }
+}
+`})
+	r := reflection.NewReflector(&test.MockGrpcreflectClient{FileDescriptor: fd})
+	routes, err := buildRoutes(r, mhttp.RESTConventionMapper, zap.NewNop())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := make([]string, len(routes))
+	for i, rt := range routes {
+		got[i] = rt.Service + "/" + rt.Method + " " + rt.String()
+	}
+	want := []string{
+		"grpcmate.testing.Shelf/ListBooks GET /v1/{name=shelves/*}/books",
+		"grpcmate.testing.Shelf/GetBook GET /v1/books/{name}",
+		"grpcmate.testing.Store/CreateBook POST /v1/books",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestRoutesWhenUpstreamIsNotReady(t *testing.T) {
+	cc, err := grpc.Dial("localhost:5000", grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	p := NewProxy(cc)
+	_, err = p.Routes()
+	if e, ok := err.(*perrors.ProxyError); !ok || e.Code != perrors.UpstreamConnFailure {
+		t.Fatalf("got %#v, want an upstream connection failure", err)
+	}
+}
+
+func TestBuildRoutesWithMapper(t *testing.T) {
+	mapper := func(m *route.Method) []*route.Route {
+		if m.ClientStreaming || m.ServerStreaming {
+			return nil
+		}
+		tmpl, err := route.ParseTemplate("/mapped/" + m.Name)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		return []*route.Route{{
+			HTTPMethod: http.MethodPost,
+			Template:   tmpl,
+			Service:    m.Service,
+			Method:     m.Name,
+		}}
+	}
+
+	t.Run("methods without options", func(t *testing.T) {
+		fd := test.NewFileDescriptor(t, test.File)
+		r := reflection.NewReflector(&test.MockGrpcreflectClient{FileDescriptor: fd})
+		routes, err := buildRoutes(r, mapper, zap.NewNop())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got := make([]string, len(routes))
+		for i, rt := range routes {
+			got[i] = rt.String()
+		}
+		want := []string{"POST /mapped/EmptyCall", "POST /mapped/UnaryCall"}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("methods with options", func(t *testing.T) {
+		fd := test.ParseFileDescriptor(t, test.LibraryFile, map[string]string{test.LibraryFile: test.LibraryProto})
+		r := reflection.NewReflector(&test.MockGrpcreflectClient{FileDescriptor: fd})
+		routes, err := buildRoutes(r, mapper, zap.NewNop())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, rt := range routes {
+			if strings.HasPrefix(rt.Template.String(), "/mapped/") {
+				t.Fatalf("method %s with options should not be mapped", rt.Method)
+			}
+		}
+	})
+}
+
+func TestMethod(t *testing.T) {
+	cc, err := grpc.Dial("localhost:5000", grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	p := NewProxy(cc)
+	fd := test.NewFileDescriptor(t, test.File)
+	p.reflector = reflection.NewReflector(&test.MockGrpcreflectClient{FileDescriptor: fd})
+
+	m, err := p.Method(test.TestService, test.StreamingOutputCall)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := m.ServerStreaming, true; got != want {
+		t.Fatalf("got %t, want %t", got, want)
+	}
+	if got, want := m.ClientStreaming, false; got != want {
+		t.Fatalf("got %t, want %t", got, want)
+	}
+	if _, err := p.Method(test.TestService, test.NotFoundCall); err == nil {
+		t.Fatalf("err should be not nil")
+	}
+}
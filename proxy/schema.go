@@ -0,0 +1,233 @@
+package proxy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"time"
+
+	"github.com/gdong42/grpc-mate/route"
+	"github.com/golang/protobuf/proto"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/grpcreflect"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/connectivity"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+)
+
+// schema is a snapshot of the upstream schema: a hash of the files describing all services, and a
+// fingerprint of every method, by full name, made of its descriptor and the descriptors of its
+// input and output types
+type schema struct {
+	hash    string
+	methods map[string]string
+}
+
+// SchemaChange describes an upstream schema change, with the full names of the methods added,
+// removed and changed, e.g. helloworld.Greeter/SayHello. Changes to the types used by the input
+// and output types of methods change the hash only.
+type SchemaChange struct {
+	Hash    string
+	Added   []string
+	Removed []string
+	Changed []string
+}
+
+// WatchSchema refreshes the upstream schema every interval, unless it is 0, and whenever the
+// connection to the upstream becomes ready, logging the changes, until ctx is done. Offline
+// proxies, whose descriptor files do not change, do not watch.
+func (p *Proxy) WatchSchema(ctx context.Context, interval time.Duration) {
+	if p.offline {
+		return
+	}
+	ready := make(chan struct{}, 1)
+	go p.watchReady(ctx, ready)
+	var tick <-chan time.Time
+	if interval > 0 {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		tick = t.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+		case <-ready:
+			// the upstream may implement another reflection service once reconnected
+			p.reflectStub.reset()
+		}
+		change, err := p.RefreshSchema()
+		if err != nil {
+			p.logger.Warn("failed to refresh upstream schema", zap.Error(err))
+			continue
+		}
+		if change != nil {
+			p.logger.Info("upstream schema changed",
+				zap.String("hash", change.Hash),
+				zap.Strings("added", change.Added),
+				zap.Strings("removed", change.Removed),
+				zap.Strings("changed", change.Changed))
+		}
+	}
+}
+
+// watchReady signals ready whenever the connection to the upstream becomes ready, until ctx is done
+func (p *Proxy) watchReady(ctx context.Context, ready chan<- struct{}) {
+	state := p.cc.GetState()
+	for {
+		if state == connectivity.Ready {
+			select {
+			case ready <- struct{}{}:
+			default:
+			}
+		}
+		if !p.cc.WaitForStateChange(ctx, state) {
+			return
+		}
+		state = p.cc.GetState()
+	}
+}
+
+// RefreshSchema loads the upstream schema, and if it changed since it was last loaded, swaps the
+// reflector, the descriptor source and the route table for new ones, all at once. It returns the
+// change, or nil if the schema is unchanged, loaded for the first time, or if the proxy is offline.
+func (p *Proxy) RefreshSchema() (*SchemaChange, error) {
+	if p.offline {
+		return nil, nil
+	}
+	s, err := loadSchema(p.reflectStub)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	prev := p.schema
+	if prev == nil {
+		p.schema = s
+	}
+	p.mu.Unlock()
+	if prev == nil || prev.hash == s.hash {
+		return nil, nil
+	}
+
+	reflector := p.newReflector()
+	policy := p.getPolicy()
+	routes, err := buildRoutes(withPolicy(reflector, policy), p.mapper, p.logger)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build routes")
+	}
+	rc := grpcreflect.NewClient(context.Background(), p.reflectStub)
+
+	p.mu.Lock()
+	oldClient := p.reflectClient
+	p.reflector = reflector
+	p.descSource = p.newDescSource(rc)
+	p.reflectClient = rc
+	p.routes = nil
+	p.introspection = nil
+	// the table is kept unless the policy changed while it was built
+	if p.policy == policy {
+		p.routes = route.NewTable(routes)
+	}
+	p.schema = s
+	p.mu.Unlock()
+	if oldClient != nil {
+		oldClient.Reset()
+	}
+	return diffSchemas(prev, s), nil
+}
+
+// loadSchema lists the upstream services and describes them by reflection
+func loadSchema(stub rpb.ServerReflectionClient) (*schema, error) {
+	rc := grpcreflect.NewClient(context.Background(), stub)
+	defer rc.Reset()
+	services, err := rc.ListServices()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list services")
+	}
+	s := &schema{
+		methods: make(map[string]string),
+	}
+	files := make(map[string]*desc.FileDescriptor)
+	for _, svc := range services {
+		sd, err := rc.ResolveService(svc)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to resolve service "+svc)
+		}
+		addFile(files, sd.GetFile())
+		for _, md := range sd.GetMethods() {
+			fp, err := fingerprint(md.AsMethodDescriptorProto(),
+				md.GetInputType().AsDescriptorProto(),
+				md.GetOutputType().AsDescriptorProto())
+			if err != nil {
+				return nil, err
+			}
+			s.methods[svc+"/"+md.GetName()] = fp
+		}
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	// sorted for the hash not to depend on the order services are listed in
+	sort.Strings(names)
+	fds := make([]proto.Message, len(names))
+	for i, name := range names {
+		fds[i] = files[name].AsFileDescriptorProto()
+	}
+	if s.hash, err = fingerprint(fds...); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// addFile adds the file and its dependencies to files, by name
+func addFile(files map[string]*desc.FileDescriptor, fd *desc.FileDescriptor) {
+	if _, ok := files[fd.GetName()]; ok {
+		return
+	}
+	files[fd.GetName()] = fd
+	for _, dep := range fd.GetDependencies() {
+		addFile(files, dep)
+	}
+}
+
+// fingerprint hashes the messages in their binary format
+func fingerprint(msgs ...proto.Message) (string, error) {
+	h := sha256.New()
+	for _, m := range msgs {
+		b, err := proto.Marshal(m)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to marshal descriptor")
+		}
+		h.Write(b)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// diffSchemas lists the methods added, removed and changed from the previous schema to the next one
+func diffSchemas(prev, next *schema) *SchemaChange {
+	c := &SchemaChange{
+		Hash: next.hash,
+	}
+	for m, fp := range next.methods {
+		prevFP, ok := prev.methods[m]
+		if !ok {
+			c.Added = append(c.Added, m)
+		} else if prevFP != fp {
+			c.Changed = append(c.Changed, m)
+		}
+	}
+	for m := range prev.methods {
+		if _, ok := next.methods[m]; !ok {
+			c.Removed = append(c.Removed, m)
+		}
+	}
+	sort.Strings(c.Added)
+	sort.Strings(c.Removed)
+	sort.Strings(c.Changed)
+	return c
+}
@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gdong42/grpc-mate/proxy/test"
+)
+
+func TestRefreshSchema(t *testing.T) {
+	cc, stop := test.StartTestServer(t)
+	defer stop()
+	p := NewProxy(cc)
+
+	for i := 0; i < 2; i++ {
+		change, err := p.RefreshSchema()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if change != nil {
+			t.Fatalf("got %+v, want no change", change)
+		}
+	}
+	if _, err := p.Method(test.TestService, test.EmptyCall); err != nil {
+		t.Fatal(err)
+	}
+
+	// the upstream loses its test service
+	emptyCC, stopEmpty := test.StartReflectionServer(t)
+	defer stopEmpty()
+	p.reflectStub = newServerReflectionClient(emptyCC)
+	change, err := p.RefreshSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if change == nil {
+		t.Fatal("got no change, want one")
+	}
+	removed := []string{
+		test.TestService + "/" + test.EmptyCall,
+		test.TestService + "/FullDuplexCall",
+		test.TestService + "/HalfDuplexCall",
+		test.TestService + "/StreamingInputCall",
+		test.TestService + "/StreamingOutputCall",
+		test.TestService + "/UnaryCall",
+	}
+	if got, want := change.Removed, removed; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got := append(change.Added, change.Changed...); len(got) != 0 {
+		t.Fatalf("got %v, want no added or changed methods", got)
+	}
+	if _, err := p.Method(test.TestService, test.EmptyCall); err == nil {
+		t.Fatal("err should not be nil")
+	}
+}
+
+func TestDiffSchemas(t *testing.T) {
+	prev := &schema{
+		hash: "1",
+		methods: map[string]string{
+			"svc/Kept":    "a",
+			"svc/Changed": "b",
+			"svc/Removed": "c",
+		},
+	}
+	next := &schema{
+		hash: "2",
+		methods: map[string]string{
+			"svc/Kept":    "a",
+			"svc/Changed": "d",
+			"svc/Added":   "e",
+		},
+	}
+	want := &SchemaChange{
+		Hash:    "2",
+		Added:   []string{"svc/Added"},
+		Removed: []string{"svc/Removed"},
+		Changed: []string{"svc/Changed"},
+	}
+	if got := diffSchemas(prev, next); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
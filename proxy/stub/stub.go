@@ -2,28 +2,58 @@ package stub
 
 import (
 	"context"
-	"fmt"
+	"io"
 
 	"github.com/gdong42/grpc-mate/metadata"
 	"github.com/gdong42/grpc-mate/proxy/reflection"
 	"github.com/golang/protobuf/proto"
 	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic/grpcdynamic"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	grpc_metadata "google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 
 	"github.com/gdong42/grpc-mate/errors"
 )
 
-// Stub performs gRPC calls based on descriptors obtained through reflection
+// Stub performs gRPC calls based on descriptors obtained through reflection. The header and
+// trailer metadata received from the backend are stored into header and trailer, the header
+// before any message is passed to onMessage for streaming calls.
 type Stub interface {
-	// InvokeRPC calls the backend gRPC method with the message provided in JSON.
-	// This performs reflection against the backend every time it is called.
+	// InvokeRPC calls the backend gRPC method with the message of the invocation, whose method
+	// descriptor is resolved by the reflector, from its descriptor cache unless expired.
 	InvokeRPC(
 		ctx context.Context,
 		invocation *reflection.MethodInvocation,
-		md *metadata.Metadata) (reflection.Message, error)
+		header, trailer *metadata.Metadata) (reflection.Message, error)
+	// InvokeServerStream calls the backend server streaming gRPC method with the message, and
+	// passes every message received to onMessage until the stream ends. The stream is canceled
+	// when onMessage returns an error, which is then returned.
+	InvokeServerStream(
+		ctx context.Context,
+		invocation *reflection.MethodInvocation,
+		header, trailer *metadata.Metadata,
+		onMessage func(reflection.Message) error) error
+	// InvokeClientStream calls the backend client streaming gRPC method, sending every message
+	// returned by next until it returns io.EOF, and returns the response. The stream is canceled
+	// when next returns another error, which is then returned.
+	InvokeClientStream(
+		ctx context.Context,
+		method *reflection.MethodDescriptor,
+		header, trailer *metadata.Metadata,
+		next func() (reflection.Message, error)) (reflection.Message, error)
+	// InvokeBidiStream calls the backend bidirectional streaming gRPC method, sending every message
+	// returned by next until it returns io.EOF, while passing every message received to onMessage
+	// until the stream ends. The stream is canceled when next or onMessage returns another error,
+	// which is then returned.
+	InvokeBidiStream(
+		ctx context.Context,
+		method *reflection.MethodDescriptor,
+		header, trailer *metadata.Metadata,
+		next func() (reflection.Message, error),
+		onMessage func(reflection.Message) error) error
 }
 
 type stubImpl struct {
@@ -33,6 +63,9 @@ type stubImpl struct {
 type grpcdynamicStub interface {
 	// This must be InvokeRpc with lower-case 'p' and 'c', because that is how the protoreflect library
 	InvokeRpc(ctx context.Context, method *desc.MethodDescriptor, request proto.Message, opts ...grpc.CallOption) (proto.Message, error)
+	InvokeRpcServerStream(ctx context.Context, method *desc.MethodDescriptor, request proto.Message, opts ...grpc.CallOption) (*grpcdynamic.ServerStream, error)
+	InvokeRpcClientStream(ctx context.Context, method *desc.MethodDescriptor, opts ...grpc.CallOption) (*grpcdynamic.ClientStream, error)
+	InvokeRpcBidiStream(ctx context.Context, method *desc.MethodDescriptor, opts ...grpc.CallOption) (*grpcdynamic.BidiStream, error)
 }
 
 // NewStub creates a new Stub with the passed connection
@@ -45,27 +78,17 @@ func NewStub(s grpcdynamicStub) Stub {
 func (s *stubImpl) InvokeRPC(
 	ctx context.Context,
 	invocation *reflection.MethodInvocation,
-	md *metadata.Metadata) (reflection.Message, error) {
+	header, trailer *metadata.Metadata) (reflection.Message, error) {
 
+	var p peer.Peer
 	o, err := s.stub.InvokeRpc(ctx,
 		invocation.MethodDescriptor.AsProtoreflectDescriptor(),
 		invocation.Message.AsProtoreflectMessage(),
-		grpc.Header((*grpc_metadata.MD)(md)))
+		grpc.Header((*grpc_metadata.MD)(header)),
+		grpc.Trailer((*grpc_metadata.MD)(trailer)),
+		grpc.Peer(&p))
 	if err != nil {
-		stat := status.Convert(err)
-		if err != nil && stat.Code() == codes.Unavailable {
-			return nil, &errors.ProxyError{
-				Code:    errors.UpstreamConnFailure,
-				Message: fmt.Sprintf("could not connect to backend"),
-			}
-		}
-
-		// When InvokeRPC returns an error, it should always be a gRPC error, so this should not panic
-		return nil, &errors.GRPCError{
-			StatusCode: int(stat.Code()),
-			Message:    stat.Message(),
-			Details:    stat.Proto().Details,
-		}
+		return nil, convertError(err, &p)
 	}
 	outputMsg := invocation.MethodDescriptor.GetOutputType().NewMessage()
 	err = outputMsg.ConvertFrom(o)
@@ -79,3 +102,198 @@ func (s *stubImpl) InvokeRPC(
 
 	return outputMsg, nil
 }
+
+func (s *stubImpl) InvokeServerStream(
+	ctx context.Context,
+	invocation *reflection.MethodInvocation,
+	header, trailer *metadata.Metadata,
+	onMessage func(reflection.Message) error) error {
+
+	// canceling the context cancels the upstream stream when returning early
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var p peer.Peer
+	stream, err := s.stub.InvokeRpcServerStream(ctx,
+		invocation.MethodDescriptor.AsProtoreflectDescriptor(),
+		invocation.Message.AsProtoreflectMessage(),
+		grpc.Header((*grpc_metadata.MD)(header)),
+		grpc.Trailer((*grpc_metadata.MD)(trailer)),
+		grpc.Peer(&p))
+	if err != nil {
+		return convertError(err, &p)
+	}
+	receiveHeader(stream, header)
+	for {
+		o, err := stream.RecvMsg()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return convertError(err, &p)
+		}
+		outputMsg := invocation.MethodDescriptor.GetOutputType().NewMessage()
+		if err := outputMsg.ConvertFrom(o); err != nil {
+			return &errors.ProxyError{
+				Code:    errors.Unknown,
+				Message: "response from backend could not be converted internally; this is a bug",
+			}
+		}
+		if err := onMessage(outputMsg); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *stubImpl) InvokeClientStream(
+	ctx context.Context,
+	method *reflection.MethodDescriptor,
+	header, trailer *metadata.Metadata,
+	next func() (reflection.Message, error)) (reflection.Message, error) {
+
+	// canceling the context cancels the upstream stream when returning early
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var p peer.Peer
+	stream, err := s.stub.InvokeRpcClientStream(ctx,
+		method.AsProtoreflectDescriptor(),
+		grpc.Header((*grpc_metadata.MD)(header)),
+		grpc.Trailer((*grpc_metadata.MD)(trailer)),
+		grpc.Peer(&p))
+	if err != nil {
+		return nil, convertError(err, &p)
+	}
+	for {
+		m, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		// io.EOF means the upstream ended the call, whose status is then received below
+		if err := stream.SendMsg(m.AsProtoreflectMessage()); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, convertError(err, &p)
+		}
+	}
+	o, err := stream.CloseAndReceive()
+	if err != nil {
+		return nil, convertError(err, &p)
+	}
+	outputMsg := method.GetOutputType().NewMessage()
+	if err := outputMsg.ConvertFrom(o); err != nil {
+		return nil, &errors.ProxyError{
+			Code:    errors.Unknown,
+			Message: "response from backend could not be converted internally; this is a bug",
+		}
+	}
+	return outputMsg, nil
+}
+
+func (s *stubImpl) InvokeBidiStream(
+	ctx context.Context,
+	method *reflection.MethodDescriptor,
+	header, trailer *metadata.Metadata,
+	next func() (reflection.Message, error),
+	onMessage func(reflection.Message) error) error {
+
+	// canceling the context cancels the upstream stream when returning early
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var p peer.Peer
+	stream, err := s.stub.InvokeRpcBidiStream(ctx,
+		method.AsProtoreflectDescriptor(),
+		grpc.Header((*grpc_metadata.MD)(header)),
+		grpc.Trailer((*grpc_metadata.MD)(trailer)),
+		grpc.Peer(&p))
+	if err != nil {
+		return convertError(err, &p)
+	}
+	// messages are sent concurrently with receiving, the stream is canceled if sending fails
+	sendErr := make(chan error, 1)
+	go func() {
+		err := sendAll(stream, next)
+		sendErr <- err
+		if err != nil {
+			cancel()
+		}
+	}()
+	receiveHeader(stream, header)
+	for {
+		o, err := stream.RecvMsg()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			select {
+			case err := <-sendErr:
+				if err != nil {
+					return err
+				}
+			default:
+			}
+			return convertError(err, &p)
+		}
+		outputMsg := method.GetOutputType().NewMessage()
+		if err := outputMsg.ConvertFrom(o); err != nil {
+			return &errors.ProxyError{
+				Code:    errors.Unknown,
+				Message: "response from backend could not be converted internally; this is a bug",
+			}
+		}
+		if err := onMessage(outputMsg); err != nil {
+			return err
+		}
+	}
+}
+
+// sendAll sends every message returned by next on the stream, and closes the sending side
+func sendAll(stream *grpcdynamic.BidiStream, next func() (reflection.Message, error)) error {
+	for {
+		m, err := next()
+		if err == io.EOF {
+			return stream.CloseSend()
+		}
+		if err != nil {
+			return err
+		}
+		// io.EOF means the upstream ended the call, whose status is received by RecvMsg
+		if err := stream.SendMsg(m.AsProtoreflectMessage()); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return convertError(err, nil)
+		}
+	}
+}
+
+// receiveHeader waits for the header metadata of the stream and stores it into header. A failed
+// stream may have no header, in which case its error is received with the messages.
+func receiveHeader(stream interface {
+	Header() (grpc_metadata.MD, error)
+}, header *metadata.Metadata) {
+	if h, err := stream.Header(); err == nil {
+		*header = metadata.Metadata(h)
+	}
+}
+
+// convertError converts the error of a gRPC call into a GRPCError, or into a ProxyError when the
+// call is unavailable without reaching the backend, as told by p being nil or having no address,
+// in which case no connection to the backend could be made. Statuses returned by the backend keep
+// their details, e.g. the RetryInfo of an UNAVAILABLE one.
+func convertError(err error, p *peer.Peer) error {
+	stat := status.Convert(err)
+	if stat.Code() == codes.Unavailable && (p == nil || p.Addr == nil) {
+		return &errors.ProxyError{
+			Code:    errors.UpstreamConnFailure,
+			Message: "could not connect to backend",
+		}
+	}
+
+	// When InvokeRPC returns an error, it should always be a gRPC error, so this should not panic
+	return &errors.GRPCError{
+		StatusCode: int(stat.Code()),
+		Message:    stat.Message(),
+		Details:    stat.Proto().Details,
+	}
+}
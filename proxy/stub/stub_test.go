@@ -2,16 +2,24 @@ package stub
 
 import (
 	"context"
+	"io"
+	"net"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/gdong42/grpc-mate/errors"
 	"github.com/gdong42/grpc-mate/metadata"
 	"github.com/gdong42/grpc-mate/proxy/reflection"
 	"github.com/gdong42/grpc-mate/proxy/test"
+	"github.com/golang/protobuf/ptypes"
+	any "github.com/golang/protobuf/ptypes/any"
 	"github.com/jhump/protoreflect/dynamic/grpcdynamic"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 	_ "google.golang.org/grpc/test/grpc_testing"
 )
 
@@ -69,7 +77,7 @@ func TestStub_InvokeRPC(t *testing.T) {
 				MethodDescriptor: methodDesc,
 				Message:          inputMsg,
 			}
-			outputMsg, err := stub.InvokeRPC(ctx, invocation, (*metadata.Metadata)(&map[string][]string{}))
+			outputMsg, err := stub.InvokeRPC(ctx, invocation, (*metadata.Metadata)(&map[string][]string{}), (*metadata.Metadata)(&map[string][]string{}))
 			if err != nil {
 				switch v := err.(type) {
 				case *errors.ProxyError:
@@ -90,3 +98,288 @@ func TestStub_InvokeRPC(t *testing.T) {
 		})
 	}
 }
+
+func TestStub_InvokeServerStream(t *testing.T) {
+	cases := []struct {
+		name      string
+		request   string
+		stopAfter int
+		messages  int
+		error
+	}{
+		{
+			name:     "success",
+			request:  `{"responseParameters":[{"size":1},{"size":2}]}`,
+			messages: 2,
+			error:    nil,
+		},
+		{
+			name:     "grpc error after messages",
+			request:  `{"responseType":"RANDOM","responseParameters":[{"size":1}]}`,
+			messages: 1,
+			error: &errors.GRPCError{
+				StatusCode: int(codes.Unimplemented),
+				Message:    "random payload unimplemented",
+			},
+		},
+		{
+			name:      "canceled by onMessage",
+			request:   `{"responseParameters":[{"size":1},{"size":2}]}`,
+			stopAfter: 1,
+			messages:  1,
+			error:     test.TestError,
+		},
+	}
+	fileDesc := test.NewFileDescriptor(t, test.File)
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			serviceDesc := reflection.ServiceDescriptorFromFileDescriptor(fileDesc, test.TestService)
+			methodDesc, err := serviceDesc.FindMethodByName(test.StreamingOutputCall)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			inputMsg := methodDesc.GetInputType().NewMessage()
+			if err := inputMsg.UnmarshalJSON([]byte(tc.request)); err != nil {
+				t.Fatal(err.Error())
+			}
+
+			stub := &stubImpl{
+				stub: &test.MockGrpcdynamicStub{},
+			}
+			invocation := &reflection.MethodInvocation{
+				MethodDescriptor: methodDesc,
+				Message:          inputMsg,
+			}
+			messages := 0
+			err = stub.InvokeServerStream(context.Background(), invocation,
+				(*metadata.Metadata)(&map[string][]string{}), (*metadata.Metadata)(&map[string][]string{}),
+				func(m reflection.Message) error {
+					messages++
+					if messages == tc.stopAfter {
+						return test.TestError
+					}
+					return nil
+				})
+			if got, want := err, tc.error; !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %#v, want %#v", got, want)
+			}
+			if got, want := messages, tc.messages; got != want {
+				t.Fatalf("got %d, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestStub_InvokeClientStream(t *testing.T) {
+	cases := []struct {
+		name     string
+		requests []string
+		nextErr  error
+		response string
+		error
+	}{
+		{
+			name:     "success",
+			requests: []string{`{"payload":{"body":"AA=="}}`, `{"payload":{"body":"AAA="}}`},
+			response: `{"aggregatedPayloadSize":3}`,
+			error:    nil,
+		},
+		{
+			name:     "no messages",
+			requests: []string{},
+			response: `{}`,
+			error:    nil,
+		},
+		{
+			name:     "grpc error",
+			requests: []string{`{"payload":{"type":"RANDOM"}}`},
+			error: &errors.GRPCError{
+				StatusCode: int(codes.Unimplemented),
+				Message:    "random payload unimplemented",
+			},
+		},
+		{
+			name:     "canceled by next",
+			requests: []string{`{"payload":{"body":"AA=="}}`},
+			nextErr:  test.TestError,
+			error:    test.TestError,
+		},
+	}
+	fileDesc := test.NewFileDescriptor(t, test.File)
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			serviceDesc := reflection.ServiceDescriptorFromFileDescriptor(fileDesc, test.TestService)
+			methodDesc, err := serviceDesc.FindMethodByName(test.StreamingInputCall)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+
+			stub := &stubImpl{
+				stub: &test.MockGrpcdynamicStub{},
+			}
+			requests := tc.requests
+			outputMsg, err := stub.InvokeClientStream(context.Background(), methodDesc,
+				(*metadata.Metadata)(&map[string][]string{}), (*metadata.Metadata)(&map[string][]string{}),
+				func() (reflection.Message, error) {
+					if len(requests) == 0 {
+						if tc.nextErr != nil {
+							return nil, tc.nextErr
+						}
+						return nil, io.EOF
+					}
+					m, err := methodDesc.CreateInputMessage([]byte(requests[0]), nil)
+					requests = requests[1:]
+					return m, err
+				})
+			if got, want := err, tc.error; !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %#v, want %#v", got, want)
+			}
+			if err != nil {
+				return
+			}
+			js, err := outputMsg.MarshalJSON()
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			if got, want := string(js), tc.response; got != want {
+				t.Fatalf("got %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestStub_InvokeBidiStream(t *testing.T) {
+	cases := []struct {
+		name      string
+		requests  []string
+		nextErr   error
+		stopAfter int
+		messages  int
+		error
+	}{
+		{
+			name:     "success",
+			requests: []string{`{"responseParameters":[{"size":1}]}`, `{"responseParameters":[{"size":1},{"size":2}]}`},
+			messages: 3,
+			error:    nil,
+		},
+		{
+			name:     "grpc error",
+			requests: []string{`{"responseParameters":[{"size":1}]}`, `{"responseType":"RANDOM"}`},
+			messages: 1,
+			error: &errors.GRPCError{
+				StatusCode: int(codes.Unimplemented),
+				Message:    "random payload unimplemented",
+			},
+		},
+		{
+			name:     "canceled by next",
+			requests: []string{},
+			nextErr:  test.TestError,
+			messages: 0,
+			error:    test.TestError,
+		},
+		{
+			name:      "canceled by onMessage",
+			requests:  []string{`{"responseParameters":[{"size":1},{"size":2}]}`},
+			stopAfter: 1,
+			messages:  1,
+			error:     test.TestError,
+		},
+	}
+	fileDesc := test.NewFileDescriptor(t, test.File)
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			serviceDesc := reflection.ServiceDescriptorFromFileDescriptor(fileDesc, test.TestService)
+			methodDesc, err := serviceDesc.FindMethodByName(test.FullDuplexCall)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+
+			stub := &stubImpl{
+				stub: &test.MockGrpcdynamicStub{},
+			}
+			requests := tc.requests
+			messages := 0
+			err = stub.InvokeBidiStream(context.Background(), methodDesc,
+				(*metadata.Metadata)(&map[string][]string{}), (*metadata.Metadata)(&map[string][]string{}),
+				func() (reflection.Message, error) {
+					if len(requests) == 0 {
+						if tc.nextErr != nil {
+							return nil, tc.nextErr
+						}
+						return nil, io.EOF
+					}
+					m, err := methodDesc.CreateInputMessage([]byte(requests[0]), nil)
+					requests = requests[1:]
+					return m, err
+				},
+				func(m reflection.Message) error {
+					messages++
+					if messages == tc.stopAfter {
+						return test.TestError
+					}
+					return nil
+				})
+			if got, want := err, tc.error; !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %#v, want %#v", got, want)
+			}
+			if got, want := messages, tc.messages; got != want {
+				t.Fatalf("got %d, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestConvertError(t *testing.T) {
+	retryInfo, err := ptypes.MarshalAny(&errdetails.RetryInfo{RetryDelay: ptypes.DurationProto(time.Second)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err := status.New(codes.Unavailable, "backend unavailable").WithDetails(&errdetails.RetryInfo{
+		RetryDelay: ptypes.DurationProto(time.Second),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name string
+		peer *peer.Peer
+		error
+	}{
+		{
+			name: "no peer",
+			error: &errors.ProxyError{
+				Code:    errors.UpstreamConnFailure,
+				Message: "could not connect to backend",
+			},
+		},
+		{
+			name: "peer not reached",
+			peer: &peer.Peer{},
+			error: &errors.ProxyError{
+				Code:    errors.UpstreamConnFailure,
+				Message: "could not connect to backend",
+			},
+		},
+		{
+			name: "peer reached",
+			peer: &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 9090}},
+			error: &errors.GRPCError{
+				StatusCode: int(codes.Unavailable),
+				Message:    "backend unavailable",
+				Details:    []*any.Any{retryInfo},
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got, want := convertError(st.Err(), tc.peer), tc.error; !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %#v, want %#v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package test
+
+const (
+	// LibraryFile is an example protobuf file with google.api.http options
+	LibraryFile = "library.proto"
+	// LibraryService is a service with google.api.http options
+	LibraryService = "grpcmate.testing.Library"
+)
+
+// LibraryProto is the source of LibraryFile
+const LibraryProto = `syntax = "proto3";
+
+package grpcmate.testing;
+
+import "google/api/annotations.proto";
+import "google/protobuf/timestamp.proto";
+import "google/protobuf/wrappers.proto";
+
+message Book {
+  enum State {
+    STATE_UNSPECIFIED = 0;
+    DRAFT = 1;
+    PUBLISHED = 2;
+  }
+  string name = 1;
+  string title = 2;
+  int64 page_count = 3;
+  State state = 4;
+  repeated string tags = 5;
+  Author author = 6;
+}
+
+message Author {
+  string name = 1;
+  int32 age = 2;
+}
+
+message GetBookRequest {
+  string name = 1;
+}
+
+message ListBooksRequest {
+  message Filter {
+    Book.State state = 1;
+    bool has_author = 2;
+  }
+  string parent = 1;
+  int32 page_size = 2;
+  Filter filter = 3;
+  repeated string tags = 4;
+  google.protobuf.Timestamp published_after = 5;
+  google.protobuf.Int32Value min_rating = 6;
+  bytes cursor = 7;
+  map<string, string> labels = 8;
+}
+
+message ListBooksResponse {
+  repeated Book books = 1;
+  string next_page_token = 2;
+}
+
+message CreateBookRequest {
+  string parent = 1;
+  Book book = 2;
+}
+
+message UpdateBookRequest {
+  Book book = 1;
+}
+
+message DeleteBookRequest {
+  string name = 1;
+}
+
+message Empty {}
+
+service Library {
+  rpc GetBook(GetBookRequest) returns (Book) {
+    option (google.api.http) = {
+      get: "/v1/{name=shelves/*/books/*}"
+    };
+  }
+  rpc ListBooks(ListBooksRequest) returns (ListBooksResponse) {
+    option (google.api.http) = {
+      get: "/v1/{parent=shelves/*}/books"
+      additional_bindings {
+        get: "/v1/books"
+      }
+    };
+  }
+  rpc CreateBook(CreateBookRequest) returns (Book) {
+    option (google.api.http) = {
+      post: "/v1/{parent=shelves/*}/books"
+      body: "book"
+    };
+  }
+  rpc UpdateBook(UpdateBookRequest) returns (Book) {
+    option (google.api.http) = {
+      patch: "/v1/{book.name=shelves/*/books/*}"
+      body: "book"
+    };
+  }
+  rpc DeleteBook(DeleteBookRequest) returns (Empty) {
+    option (google.api.http) = {
+      delete: "/v1/{name=shelves/*/books/*}"
+    };
+  }
+  rpc ArchiveBook(GetBookRequest) returns (Book) {
+    option (google.api.http) = {
+      custom {
+        kind: "archive"
+        path: "/v1/{name=shelves/*/books/*}:archive"
+      }
+      body: "*"
+    };
+  }
+}
+`
+
+// HTTPProto is the source of google/api/http.proto, trimmed to the messages only
+const HTTPProto = `syntax = "proto3";
+
+package google.api;
+
+option go_package = "google.golang.org/genproto/googleapis/api/annotations;annotations";
+
+message Http {
+  repeated HttpRule rules = 1;
+  bool fully_decode_reserved_expansion = 2;
+}
+
+message HttpRule {
+  string selector = 1;
+  oneof pattern {
+    string get = 2;
+    string put = 3;
+    string post = 4;
+    string delete = 5;
+    string patch = 6;
+    CustomHttpPattern custom = 8;
+  }
+  string body = 7;
+  string response_body = 12;
+  repeated HttpRule additional_bindings = 11;
+}
+
+message CustomHttpPattern {
+  string kind = 1;
+  string path = 2;
+}
+`
+
+// AnnotationsProto is the source of google/api/annotations.proto
+const AnnotationsProto = `syntax = "proto3";
+
+package google.api;
+
+import "google/api/http.proto";
+import "google/protobuf/descriptor.proto";
+
+option go_package = "google.golang.org/genproto/googleapis/api/annotations;annotations";
+
+extend google.protobuf.MethodOptions {
+  HttpRule http = 72295728;
+}
+`
@@ -0,0 +1,160 @@
+package test
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/jhump/protoreflect/desc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+)
+
+// reflectionV1ServiceDesc describes the grpc.reflection.v1 reflection service, whose messages are
+// the same as those of v1alpha
+var reflectionV1ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "grpc.reflection.v1.ServerReflection",
+	HandlerType: (*rpb.ServerReflectionServer)(nil),
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName: "ServerReflectionInfo",
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				return srv.(rpb.ServerReflectionServer).ServerReflectionInfo(&reflectionInfoServer{stream})
+			},
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "grpc_reflection_v1/reflection.proto",
+}
+
+// registerReflectionV1 registers the grpc.reflection.v1 reflection service, which describes the
+// services registered with s
+func registerReflectionV1(s *grpc.Server) {
+	s.RegisterService(&reflectionV1ServiceDesc, &reflectionV1Server{s: s})
+}
+
+type reflectionInfoServer struct {
+	grpc.ServerStream
+}
+
+func (x *reflectionInfoServer) Send(m *rpb.ServerReflectionResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *reflectionInfoServer) Recv() (*rpb.ServerReflectionRequest, error) {
+	m := new(rpb.ServerReflectionRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// reflectionV1Server answers the list services, file by filename and file containing symbol
+// requests, which are the ones made to describe services
+type reflectionV1Server struct {
+	s *grpc.Server
+}
+
+func (r *reflectionV1Server) ServerReflectionInfo(stream rpb.ServerReflection_ServerReflectionInfoServer) error {
+	for {
+		in, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		out := &rpb.ServerReflectionResponse{
+			ValidHost:       in.Host,
+			OriginalRequest: in,
+		}
+		switch req := in.MessageRequest.(type) {
+		case *rpb.ServerReflectionRequest_ListServices:
+			r.listServices(out)
+		case *rpb.ServerReflectionRequest_FileByFilename:
+			fd, err := desc.LoadFileDescriptor(req.FileByFilename)
+			fileResponse(out, fd, err)
+		case *rpb.ServerReflectionRequest_FileContainingSymbol:
+			fd, err := r.fileContainingSymbol(req.FileContainingSymbol)
+			fileResponse(out, fd, err)
+		default:
+			errorResponse(out, codes.Unimplemented, "unsupported request")
+		}
+		if err := stream.Send(out); err != nil {
+			return err
+		}
+	}
+}
+
+// listServices lists the services which can be described, which this one cannot, as its file is not
+// registered with the protobuf registry
+func (r *reflectionV1Server) listServices(out *rpb.ServerReflectionResponse) {
+	var services []*rpb.ServiceResponse
+	for name, info := range r.s.GetServiceInfo() {
+		if _, err := loadFile(info); err != nil {
+			continue
+		}
+		services = append(services, &rpb.ServiceResponse{Name: name})
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+	out.MessageResponse = &rpb.ServerReflectionResponse_ListServicesResponse{
+		ListServicesResponse: &rpb.ListServiceResponse{Service: services},
+	}
+}
+
+func (r *reflectionV1Server) fileContainingSymbol(symbol string) (*desc.FileDescriptor, error) {
+	for _, info := range r.s.GetServiceInfo() {
+		fd, err := loadFile(info)
+		if err != nil {
+			continue
+		}
+		if fd.FindSymbol(symbol) != nil {
+			return fd, nil
+		}
+	}
+	return nil, fmt.Errorf("symbol %s not found", symbol)
+}
+
+// loadFile loads the file describing the service from the protobuf registry
+func loadFile(info grpc.ServiceInfo) (*desc.FileDescriptor, error) {
+	file, ok := info.Metadata.(string)
+	if !ok {
+		return nil, fmt.Errorf("unknown file")
+	}
+	return desc.LoadFileDescriptor(file)
+}
+
+// fileResponse answers with the file and its dependencies, or with a not found error
+func fileResponse(out *rpb.ServerReflectionResponse, fd *desc.FileDescriptor, err error) {
+	if err != nil {
+		errorResponse(out, codes.NotFound, "not found")
+		return
+	}
+	var files [][]byte
+	seen := make(map[string]bool)
+	var add func(fd *desc.FileDescriptor)
+	add = func(fd *desc.FileDescriptor) {
+		if seen[fd.GetName()] {
+			return
+		}
+		seen[fd.GetName()] = true
+		b, _ := proto.Marshal(fd.AsFileDescriptorProto())
+		files = append(files, b)
+		for _, dep := range fd.GetDependencies() {
+			add(dep)
+		}
+	}
+	add(fd)
+	out.MessageResponse = &rpb.ServerReflectionResponse_FileDescriptorResponse{
+		FileDescriptorResponse: &rpb.FileDescriptorResponse{FileDescriptorProto: files},
+	}
+}
+
+func errorResponse(out *rpb.ServerReflectionResponse, code codes.Code, message string) {
+	out.MessageResponse = &rpb.ServerReflectionResponse_ErrorResponse{
+		ErrorResponse: &rpb.ErrorResponse{ErrorCode: int32(code), ErrorMessage: message},
+	}
+}
@@ -2,15 +2,20 @@ package test
 
 import (
 	"context"
+	"io"
 	"testing"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
 	"github.com/jhump/protoreflect/dynamic"
+	"github.com/jhump/protoreflect/dynamic/grpcdynamic"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/grpc_testing"
 )
 
 const (
@@ -22,6 +27,12 @@ const (
 	EmptyCall = "EmptyCall"
 	// UnaryCall is a method name
 	UnaryCall = "UnaryCall"
+	// StreamingOutputCall is a server streaming method name
+	StreamingOutputCall = "StreamingOutputCall"
+	// StreamingInputCall is a client streaming method name
+	StreamingInputCall = "StreamingInputCall"
+	// FullDuplexCall is a bidirectional streaming method name
+	FullDuplexCall = "FullDuplexCall"
 	// UnaryCallInputMsgName is the type name of method UnaryCall
 	UnaryCallInputMsgName = "grpc.testing.SimpleRequest"
 	// NotFoundCall is a method name that does not exist
@@ -47,25 +58,50 @@ func NewFileDescriptor(t *testing.T, file string) *desc.FileDescriptor {
 	return desc
 }
 
+// ParseFileDescriptor parses the given file from proto sources for testing, the sources of
+// google/api/annotations.proto and its imports are always available
+func ParseFileDescriptor(t *testing.T, file string, sources map[string]string) *desc.FileDescriptor {
+	t.Helper()
+	files := map[string]string{
+		"google/api/http.proto":        HTTPProto,
+		"google/api/annotations.proto": AnnotationsProto,
+	}
+	for k, v := range sources {
+		files[k] = v
+	}
+	p := protoparse.Parser{
+		Accessor: protoparse.FileContentsFromMap(files),
+	}
+	fds, err := p.ParseFiles(file)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return fds[0]
+}
+
 // MockGrpcreflectClient is a mock of grpcreflectClient
 type MockGrpcreflectClient struct {
 	*desc.FileDescriptor
 }
 
-// ResolveService is a mock that returns TestService from test.proto
+// ResolveService is a mock that returns a service from the file descriptor
 func (c *MockGrpcreflectClient) ResolveService(serviceName string) (*desc.ServiceDescriptor, error) {
-	if serviceName != TestService {
+	if c.FileDescriptor == nil {
+		return nil, errors.Errorf("service not found")
+	}
+	sd := c.FileDescriptor.FindService(serviceName)
+	if sd == nil {
 		return nil, errors.Errorf("service not found")
 	}
-	return c.FileDescriptor.FindService(serviceName), nil
+	return sd, nil
 }
 
-// ListServices is a mock that returns all services from test.proto
+// ListServices is a mock that returns all services from the file descriptor
 func (c *MockGrpcreflectClient) ListServices() ([]string, error) {
 	sds := c.FileDescriptor.GetServices()
 	names := make([]string, len(sds))
 	for i, s := range sds {
-		names[i] = s.GetName()
+		names[i] = s.GetFullyQualifiedName()
 	}
 	return names, nil
 }
@@ -82,3 +118,181 @@ func (m *MockGrpcdynamicStub) InvokeRpc(ctx context.Context, method *desc.Method
 	output := dynamic.NewMessage(method.GetOutputType())
 	return output, nil
 }
+
+// InvokeRpcServerStream mocks the invocation of a server streaming RPC call, which streams a
+// response for every response parameter of a grpc.testing.StreamingOutputCallRequest, with a
+// payload of the requested size. The stream fails after the responses if the requested
+// response type is RANDOM.
+func (m *MockGrpcdynamicStub) InvokeRpcServerStream(ctx context.Context, method *desc.MethodDescriptor, request proto.Message, opts ...grpc.CallOption) (*grpcdynamic.ServerStream, error) {
+	return grpcdynamic.NewStub(&mockChannel{}).InvokeRpcServerStream(ctx, method, request, opts...)
+}
+
+// InvokeRpcClientStream mocks the invocation of a client streaming RPC call, which responds with
+// the aggregated payload size of the grpc.testing.StreamingInputCallRequest messages sent. The
+// call fails if the type of a payload is RANDOM.
+func (m *MockGrpcdynamicStub) InvokeRpcClientStream(ctx context.Context, method *desc.MethodDescriptor, opts ...grpc.CallOption) (*grpcdynamic.ClientStream, error) {
+	return grpcdynamic.NewStub(&mockChannel{}).InvokeRpcClientStream(ctx, method, opts...)
+}
+
+// InvokeRpcBidiStream mocks the invocation of a bidirectional streaming RPC call, which streams
+// responses for every grpc.testing.StreamingOutputCallRequest message sent, like
+// InvokeRpcServerStream does.
+func (m *MockGrpcdynamicStub) InvokeRpcBidiStream(ctx context.Context, method *desc.MethodDescriptor, opts ...grpc.CallOption) (*grpcdynamic.BidiStream, error) {
+	return grpcdynamic.NewStub(&mockChannel{}).InvokeRpcBidiStream(ctx, method, opts...)
+}
+
+// mockChannel is a grpcdynamic.Channel creating mockClientStream
+type mockChannel struct {
+}
+
+func (c *mockChannel) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error {
+	return status.Error(codes.Unimplemented, "unimplemented")
+}
+
+func (c *mockChannel) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return &mockClientStream{ctx: ctx, method: desc.StreamName, in: make(chan []byte, 16)}, nil
+}
+
+// mockClientStream serves the responses of StreamingOutputCall, StreamingInputCall and
+// FullDuplexCall
+type mockClientStream struct {
+	ctx    context.Context
+	method string
+	// requests holds the requests sent, and responses counts the responses received
+	requests  [][]byte
+	responses int
+	// in holds the requests of FullDuplexCall that are not served yet, and pending the
+	// responses to the request being served
+	in      chan []byte
+	pending [][]byte
+}
+
+func (s *mockClientStream) Header() (metadata.MD, error) {
+	return metadata.MD{}, nil
+}
+
+func (s *mockClientStream) Trailer() metadata.MD {
+	return metadata.MD{}
+}
+
+func (s *mockClientStream) CloseSend() error {
+	close(s.in)
+	return nil
+}
+
+func (s *mockClientStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *mockClientStream) SendMsg(m interface{}) error {
+	b, err := proto.Marshal(m.(proto.Message))
+	if err != nil {
+		return err
+	}
+	if s.method == FullDuplexCall {
+		s.in <- b
+		return nil
+	}
+	s.requests = append(s.requests, b)
+	return nil
+}
+
+func (s *mockClientStream) RecvMsg(m interface{}) error {
+	if s.ctx.Err() != nil {
+		return status.Error(codes.Canceled, s.ctx.Err().Error())
+	}
+	var resp proto.Message
+	var err error
+	switch s.method {
+	case StreamingOutputCall:
+		resp, err = s.streamingOutputCallResponse()
+	case StreamingInputCall:
+		resp, err = s.streamingInputCallResponse()
+	case FullDuplexCall:
+		return s.fullDuplexCallResponse(m.(proto.Message))
+	default:
+		err = status.Error(codes.Unimplemented, "unimplemented")
+	}
+	if err != nil {
+		return err
+	}
+	s.responses++
+	b, err := proto.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(b, m.(proto.Message))
+}
+
+func (s *mockClientStream) streamingOutputCallResponse() (proto.Message, error) {
+	var req grpc_testing.StreamingOutputCallRequest
+	if err := proto.Unmarshal(s.requests[0], &req); err != nil {
+		return nil, err
+	}
+	params := req.GetResponseParameters()
+	if s.responses >= len(params) {
+		if req.GetResponseType() == grpc_testing.PayloadType_RANDOM {
+			return nil, status.Error(codes.Unimplemented, "random payload unimplemented")
+		}
+		return nil, io.EOF
+	}
+	return &grpc_testing.StreamingOutputCallResponse{
+		Payload: &grpc_testing.Payload{
+			Body: make([]byte, params[s.responses].GetSize()),
+		},
+	}, nil
+}
+
+func (s *mockClientStream) streamingInputCallResponse() (proto.Message, error) {
+	if s.responses > 0 {
+		return nil, io.EOF
+	}
+	size := 0
+	for _, b := range s.requests {
+		var req grpc_testing.StreamingInputCallRequest
+		if err := proto.Unmarshal(b, &req); err != nil {
+			return nil, err
+		}
+		if req.GetPayload().GetType() == grpc_testing.PayloadType_RANDOM {
+			return nil, status.Error(codes.Unimplemented, "random payload unimplemented")
+		}
+		size += len(req.GetPayload().GetBody())
+	}
+	return &grpc_testing.StreamingInputCallResponse{
+		AggregatedPayloadSize: int32(size),
+	}, nil
+}
+
+func (s *mockClientStream) fullDuplexCallResponse(m proto.Message) error {
+	for len(s.pending) == 0 {
+		var b []byte
+		var ok bool
+		select {
+		case b, ok = <-s.in:
+		case <-s.ctx.Done():
+			return status.Error(codes.Canceled, s.ctx.Err().Error())
+		}
+		if !ok {
+			return io.EOF
+		}
+		s.requests, s.responses = [][]byte{b}, 0
+		for {
+			resp, err := s.streamingOutputCallResponse()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			s.responses++
+			b, err := proto.Marshal(resp)
+			if err != nil {
+				return err
+			}
+			s.pending = append(s.pending, b)
+		}
+	}
+	b := s.pending[0]
+	s.pending = s.pending[1:]
+	return proto.Unmarshal(b, m)
+}
@@ -0,0 +1,178 @@
+package test
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/grpc_testing"
+)
+
+// TestServer implements grpc.testing.TestService, responding like MockGrpcdynamicStub does. EmptyCall
+// also sends x-header header and x-trailer trailer metadata.
+type TestServer struct {
+}
+
+// Reflection tells which versions of the reflection service a test server serves
+type Reflection int
+
+const (
+	// ReflectionV1Alpha serves grpc.reflection.v1alpha
+	ReflectionV1Alpha Reflection = 1 << iota
+	// ReflectionV1 serves grpc.reflection.v1
+	ReflectionV1
+)
+
+// StartTestServer serves TestServer, along with the v1alpha reflection service, on a local port,
+// and returns a connection to it along with a function stopping both
+func StartTestServer(t testing.TB) (*grpc.ClientConn, func()) {
+	return StartTestServerWithReflection(t, ReflectionV1Alpha)
+}
+
+// StartTestServerWithReflection serves TestServer like StartTestServer, along with the given
+// versions of the reflection service
+func StartTestServerWithReflection(t testing.TB, r Reflection) (*grpc.ClientConn, func()) {
+	return startServer(t, r, func(s *grpc.Server) {
+		grpc_testing.RegisterTestServiceServer(s, &TestServer{})
+	})
+}
+
+// StartReflectionServer serves the v1alpha reflection service only, like an upstream without
+// services
+func StartReflectionServer(t testing.TB) (*grpc.ClientConn, func()) {
+	return startServer(t, ReflectionV1Alpha, func(s *grpc.Server) {})
+}
+
+func startServer(t testing.TB, r Reflection, register func(s *grpc.Server)) (*grpc.ClientConn, func()) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	s := grpc.NewServer()
+	register(s)
+	if r&ReflectionV1Alpha != 0 {
+		reflection.Register(s)
+	}
+	if r&ReflectionV1 != 0 {
+		registerReflectionV1(s)
+	}
+	go s.Serve(ln)
+	cc, err := grpc.Dial(ln.Addr().String(), grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		s.Stop()
+		t.Fatal(err.Error())
+	}
+	return cc, func() {
+		cc.Close()
+		s.Stop()
+	}
+}
+
+// EmptyCall responds with an empty message
+func (s *TestServer) EmptyCall(ctx context.Context, in *grpc_testing.Empty) (*grpc_testing.Empty, error) {
+	grpc.SetHeader(ctx, metadata.Pairs("x-header", "header"))
+	grpc.SetTrailer(ctx, metadata.Pairs("x-trailer", "trailer"))
+	return &grpc_testing.Empty{}, nil
+}
+
+// UnaryCall fails with the status code given as response size, with a RetryInfo detail for
+// UNAVAILABLE, and QuotaFailure and RetryInfo ones for RESOURCE_EXHAUSTED. It is otherwise
+// unimplemented.
+func (s *TestServer) UnaryCall(ctx context.Context, in *grpc_testing.SimpleRequest) (*grpc_testing.SimpleResponse, error) {
+	retryInfo := &errdetails.RetryInfo{RetryDelay: ptypes.DurationProto(1500 * time.Millisecond)}
+	switch c := codes.Code(in.GetResponseSize()); c {
+	case codes.Unavailable:
+		return nil, statusWithDetails(c, "backend unavailable", retryInfo)
+	case codes.ResourceExhausted:
+		quotaFailure := &errdetails.QuotaFailure{
+			Violations: []*errdetails.QuotaFailure_Violation{{Subject: "project:1", Description: "daily limit"}},
+		}
+		return nil, statusWithDetails(c, "quota exceeded", quotaFailure, retryInfo)
+	default:
+		return nil, status.Error(codes.Unimplemented, "unary unimplemented")
+	}
+}
+
+func statusWithDetails(c codes.Code, msg string, details ...proto.Message) error {
+	st, err := status.New(c, msg).WithDetails(details...)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	return st.Err()
+}
+
+// StreamingOutputCall streams a response of the requested size for every response parameter, and
+// fails after the responses if the requested response type is RANDOM
+func (s *TestServer) StreamingOutputCall(in *grpc_testing.StreamingOutputCallRequest, stream grpc_testing.TestService_StreamingOutputCallServer) error {
+	return sendResponses(in, stream.Send)
+}
+
+// StreamingInputCall responds with the aggregated payload size of the requests, and fails if the
+// type of a payload is RANDOM
+func (s *TestServer) StreamingInputCall(stream grpc_testing.TestService_StreamingInputCallServer) error {
+	size := 0
+	for {
+		in, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(&grpc_testing.StreamingInputCallResponse{
+				AggregatedPayloadSize: int32(size),
+			})
+		}
+		if err != nil {
+			return err
+		}
+		if in.GetPayload().GetType() == grpc_testing.PayloadType_RANDOM {
+			return status.Error(codes.Unimplemented, "random payload unimplemented")
+		}
+		size += len(in.GetPayload().GetBody())
+	}
+}
+
+// FullDuplexCall streams the responses of every request like StreamingOutputCall
+func (s *TestServer) FullDuplexCall(stream grpc_testing.TestService_FullDuplexCallServer) error {
+	for {
+		in, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := sendResponses(in, stream.Send); err != nil {
+			return err
+		}
+	}
+}
+
+// HalfDuplexCall is unimplemented
+func (s *TestServer) HalfDuplexCall(stream grpc_testing.TestService_HalfDuplexCallServer) error {
+	return status.Error(codes.Unimplemented, "half duplex unimplemented")
+}
+
+func sendResponses(in *grpc_testing.StreamingOutputCallRequest, send func(*grpc_testing.StreamingOutputCallResponse) error) error {
+	for _, p := range in.GetResponseParameters() {
+		err := send(&grpc_testing.StreamingOutputCallResponse{
+			Payload: &grpc_testing.Payload{
+				Body: make([]byte, p.GetSize()),
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if in.GetResponseType() == grpc_testing.PayloadType_RANDOM {
+		return status.Error(codes.Unimplemented, "random payload unimplemented")
+	}
+	return nil
+}
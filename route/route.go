@@ -0,0 +1,127 @@
+package route
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// Route maps an HTTP method and path template to a gRPC method
+type Route struct {
+	// HTTPMethod is the HTTP method of the route, e.g. GET
+	HTTPMethod string
+	// Template is the path template of the route
+	Template *Template
+	// Service is the fully qualified name of the gRPC service
+	Service string
+	// Method is the name of the gRPC method
+	Method string
+	// Body is the field path the request body is mapped to, "*" for the whole request message,
+	// or empty if the request has no body
+	Body string
+	// ResponseBody is the field path of the response message used as the response body, or
+	// empty if the whole response message is used
+	ResponseBody string
+}
+
+// Method describes a gRPC method to derive routes from
+type Method struct {
+	// Service is the fully qualified name of the gRPC service
+	Service string
+	// Name is the name of the gRPC method
+	Name string
+	// InputFields holds the field paths of the input message, down to the fields of its direct
+	// sub messages, e.g. name, book, book.name
+	InputFields []string
+	// ClientStreaming tells if the method is client streaming
+	ClientStreaming bool
+	// ServerStreaming tells if the method is server streaming
+	ServerStreaming bool
+}
+
+// HasInputField tells if the input message has the field path
+func (m *Method) HasInputField(fieldPath string) bool {
+	for _, f := range m.InputFields {
+		if f == fieldPath {
+			return true
+		}
+	}
+	return false
+}
+
+// Mapper derives routes for a method which has no google.api.http option
+type Mapper func(m *Method) []*Route
+
+// Match is a Route matched against an HTTP request
+type Match struct {
+	*Route
+	// PathParams holds the path variables captured by the template, keyed by field path
+	PathParams map[string]string
+}
+
+// Params returns the Params of the matched request with the given query parameters
+func (m *Match) Params(query url.Values) *Params {
+	return &Params{
+		BodyField:         m.Body,
+		PathParams:        m.PathParams,
+		QueryParams:       query,
+		ResponseBodyField: m.ResponseBody,
+	}
+}
+
+// Params holds the parts of an HTTP request bound into the fields of the input message, and the
+// field of the output message bound into the response body
+type Params struct {
+	// BodyField is the field path the request body is bound to, "*" for the whole message, or
+	// empty if the request body is ignored
+	BodyField string
+	// PathParams holds the path variables keyed by field path
+	PathParams map[string]string
+	// QueryParams holds the query parameters keyed by field path, they are ignored when the
+	// whole message is bound to the request body
+	QueryParams url.Values
+	// ResponseBodyField is the field path of the output message the response body is made of, or
+	// empty for the whole message
+	ResponseBodyField string
+}
+
+// Table holds all routes, and looks up the one matching an HTTP request
+type Table struct {
+	routes []*Route
+}
+
+// NewTable creates a new Table from routes, earlier routes take precedence on conflicts
+func NewTable(routes []*Route) *Table {
+	return &Table{
+		routes: routes,
+	}
+}
+
+// Routes returns all routes of the table
+func (t *Table) Routes() []*Route {
+	return t.routes
+}
+
+// Lookup finds the route matching the HTTP method and escaped path. When no route matches,
+// pathMatched tells whether there is a route matching the path but with another HTTP method.
+func (t *Table) Lookup(httpMethod, escapedPath string) (m *Match, pathMatched bool) {
+	for _, r := range t.routes {
+		params, ok := r.Template.Match(escapedPath)
+		if !ok {
+			continue
+		}
+		if r.HTTPMethod != httpMethod && !(r.HTTPMethod == http.MethodGet && httpMethod == http.MethodHead) {
+			pathMatched = true
+			continue
+		}
+		return &Match{
+			Route:      r,
+			PathParams: params,
+		}, true
+	}
+	return nil, pathMatched
+}
+
+// String returns the route in the form of "GET /v1/path"
+func (r *Route) String() string {
+	return r.HTTPMethod + " " + r.Template.String()
+}
@@ -0,0 +1,138 @@
+package route
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func newTestTable(t *testing.T) *Table {
+	t.Helper()
+	routes := []struct {
+		httpMethod string
+		template   string
+		method     string
+	}{
+		{http.MethodGet, "/v1/users/{user_id}", "GetUser"},
+		{http.MethodDelete, "/v1/users/{user_id}", "DeleteUser"},
+		{http.MethodPost, "/v1/users", "CreateUser"},
+		{http.MethodGet, "/v1/{name=users/*/posts/*}", "GetPost"},
+	}
+	var rs []*Route
+	for _, r := range routes {
+		tmpl, err := ParseTemplate(r.template)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		rs = append(rs, &Route{
+			HTTPMethod: r.httpMethod,
+			Template:   tmpl,
+			Service:    "example.Users",
+			Method:     r.method,
+		})
+	}
+	return NewTable(rs)
+}
+
+func TestTable_Lookup(t *testing.T) {
+	cases := []struct {
+		name        string
+		httpMethod  string
+		path        string
+		method      string
+		pathMatched bool
+	}{
+		{
+			name:        "get",
+			httpMethod:  http.MethodGet,
+			path:        "/v1/users/42",
+			method:      "GetUser",
+			pathMatched: true,
+		},
+		{
+			name:        "same path with another method",
+			httpMethod:  http.MethodDelete,
+			path:        "/v1/users/42",
+			method:      "DeleteUser",
+			pathMatched: true,
+		},
+		{
+			name:        "head is served by get",
+			httpMethod:  http.MethodHead,
+			path:        "/v1/users/42",
+			method:      "GetUser",
+			pathMatched: true,
+		},
+		{
+			name:        "method not allowed",
+			httpMethod:  http.MethodPut,
+			path:        "/v1/users/42",
+			method:      "",
+			pathMatched: true,
+		},
+		{
+			name:        "multi segment variable",
+			httpMethod:  http.MethodGet,
+			path:        "/v1/users/42/posts/1",
+			method:      "GetPost",
+			pathMatched: true,
+		},
+		{
+			name:        "not found",
+			httpMethod:  http.MethodGet,
+			path:        "/v1/shelves/1",
+			method:      "",
+			pathMatched: false,
+		},
+	}
+	table := newTestTable(t)
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, pathMatched := table.Lookup(tc.httpMethod, tc.path)
+			if got, want := pathMatched, tc.pathMatched; got != want {
+				t.Fatalf("got %t, want %t", got, want)
+			}
+			method := ""
+			if m != nil {
+				method = m.Method
+			}
+			if got, want := method, tc.method; got != want {
+				t.Fatalf("got %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestRoute_String(t *testing.T) {
+	table := newTestTable(t)
+	if got, want := table.Routes()[0].String(), "GET /v1/users/{user_id}"; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestMatch_Params(t *testing.T) {
+	tmpl, err := ParseTemplate("/v1/users/{user_id}")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	m := &Match{
+		Route: &Route{
+			HTTPMethod:   http.MethodPatch,
+			Template:     tmpl,
+			Body:         "user",
+			ResponseBody: "user",
+		},
+		PathParams: map[string]string{"user_id": "1"},
+	}
+	query := url.Values{"update_mask": {"name"}}
+	want := &Params{
+		BodyField:         "user",
+		PathParams:        map[string]string{"user_id": "1"},
+		QueryParams:       query,
+		ResponseBodyField: "user",
+	}
+	if got := m.Params(query); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v, want %#v", got, want)
+	}
+}
@@ -0,0 +1,291 @@
+package route
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Template is a parsed google.api.http path template, e.g. /v1/{name=projects/*/items/*}:cancel
+//
+// The grammar is described in https://github.com/googleapis/googleapis/blob/master/google/api/http.proto
+//
+//	Template = "/" Segments [ Verb ] ;
+//	Segments = Segment { "/" Segment } ;
+//	Segment  = "*" | "**" | LITERAL | Variable ;
+//	Variable = "{" FieldPath [ "=" Segments ] "}" ;
+//	FieldPath = IDENT { "." IDENT } ;
+//	Verb     = ":" LITERAL ;
+type Template struct {
+	raw      string
+	segments []segment
+	verb     string
+}
+
+// segment is a single path segment of a template
+type segment struct {
+	kind    segmentKind
+	literal string
+	// variable is the field path the segment is captured into, if any
+	variable string
+}
+
+type segmentKind int
+
+const (
+	literalSegment segmentKind = iota
+	// wildcardSegment matches exactly one path segment
+	wildcardSegment
+	// deepWildcardSegment matches zero or more path segments, it must be the last one
+	deepWildcardSegment
+)
+
+// ParseTemplate parses a path template
+func ParseTemplate(tmpl string) (*Template, error) {
+	if !strings.HasPrefix(tmpl, "/") {
+		return nil, fmt.Errorf("invalid path template %q: must start with /", tmpl)
+	}
+	path := tmpl[1:]
+	t := &Template{raw: tmpl}
+
+	// the verb follows the last colon of the last segment
+	if i := strings.LastIndex(path, ":"); i > strings.LastIndexAny(path, "/}") {
+		t.verb = path[i+1:]
+		path = path[:i]
+	}
+
+	parts, err := splitSegments(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path template %q: %v", tmpl, err)
+	}
+	for _, p := range parts {
+		if !strings.HasPrefix(p, "{") {
+			s, err := parseSegment(p, "")
+			if err != nil {
+				return nil, fmt.Errorf("invalid path template %q: %v", tmpl, err)
+			}
+			t.segments = append(t.segments, s)
+			continue
+		}
+		if !strings.HasSuffix(p, "}") {
+			return nil, fmt.Errorf("invalid path template %q: unterminated variable %s", tmpl, p)
+		}
+		v := p[1 : len(p)-1]
+		field, sub := v, "*"
+		if i := strings.Index(v, "="); i >= 0 {
+			field, sub = v[:i], v[i+1:]
+		}
+		if !isFieldPath(field) {
+			return nil, fmt.Errorf("invalid path template %q: invalid field path %q", tmpl, field)
+		}
+		for _, sp := range strings.Split(sub, "/") {
+			s, err := parseSegment(sp, field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid path template %q: %v", tmpl, err)
+			}
+			t.segments = append(t.segments, s)
+		}
+	}
+	for i, s := range t.segments {
+		if s.kind == deepWildcardSegment && i != len(t.segments)-1 {
+			return nil, fmt.Errorf("invalid path template %q: ** must be the last segment", tmpl)
+		}
+	}
+	return t, nil
+}
+
+// splitSegments splits a path on slashes that are not inside a variable
+func splitSegments(path string) ([]string, error) {
+	var parts []string
+	depth, start := 0, 0
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case '{':
+			if depth > 0 {
+				return nil, fmt.Errorf("nested variables are not allowed")
+			}
+			depth++
+		case '}':
+			if depth == 0 {
+				return nil, fmt.Errorf("unexpected }")
+			}
+			depth--
+		case '/':
+			if depth == 0 {
+				parts = append(parts, path[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("unterminated variable")
+	}
+	return append(parts, path[start:]), nil
+}
+
+func parseSegment(s, variable string) (segment, error) {
+	switch s {
+	case "":
+		return segment{}, fmt.Errorf("empty segment")
+	case "*":
+		return segment{kind: wildcardSegment, variable: variable}, nil
+	case "**":
+		return segment{kind: deepWildcardSegment, variable: variable}, nil
+	}
+	if strings.ContainsAny(s, "{}*=") {
+		return segment{}, fmt.Errorf("invalid segment %q", s)
+	}
+	return segment{kind: literalSegment, literal: s, variable: variable}, nil
+}
+
+func isFieldPath(p string) bool {
+	for _, ident := range strings.Split(p, ".") {
+		if ident == "" {
+			return false
+		}
+		for i, c := range ident {
+			letter := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+			if !letter && (i == 0 || c < '0' || c > '9') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// String returns the template as it was written
+func (t *Template) String() string {
+	return t.raw
+}
+
+// Variables returns the field paths of all variables in the template, in order of appearance
+func (t *Template) Variables() []string {
+	var vars []string
+	for _, s := range t.segments {
+		if s.variable != "" && (len(vars) == 0 || vars[len(vars)-1] != s.variable) {
+			vars = append(vars, s.variable)
+		}
+	}
+	return vars
+}
+
+// OpenAPIPath returns the template as an OpenAPI path, whose variables are named by their field path
+// only, e.g. /v1/{name}:cancel for /v1/{name=projects/*/items/*}:cancel
+func (t *Template) OpenAPIPath() string {
+	var b strings.Builder
+	for i, s := range t.segments {
+		if s.variable != "" {
+			if i > 0 && t.segments[i-1].variable == s.variable {
+				continue
+			}
+			b.WriteString("/{" + s.variable + "}")
+			continue
+		}
+		switch s.kind {
+		case wildcardSegment:
+			b.WriteString("/*")
+		case deepWildcardSegment:
+			b.WriteString("/**")
+		default:
+			b.WriteString("/" + s.literal)
+		}
+	}
+	if t.verb != "" {
+		b.WriteString(":" + t.verb)
+	}
+	return b.String()
+}
+
+// Pattern returns the template without its variables, which are replaced by the segments they
+// match, e.g. /v1/shelves/*/books/* for /v1/{name=shelves/*/books/*}, so that templates matching the
+// same paths have the same pattern
+func (t *Template) Pattern() string {
+	var b strings.Builder
+	for _, s := range t.segments {
+		switch s.kind {
+		case wildcardSegment:
+			b.WriteString("/*")
+		case deepWildcardSegment:
+			b.WriteString("/**")
+		default:
+			b.WriteString("/" + s.literal)
+		}
+	}
+	if t.verb != "" {
+		b.WriteString(":" + t.verb)
+	}
+	return b.String()
+}
+
+// Match matches an escaped URL path against the template, and returns the captured variables
+// keyed by field path. Variables that capture a single segment are unescaped, while the ones
+// spanning multiple segments keep their slashes escaped, as required by the google.api.http spec.
+func (t *Template) Match(escapedPath string) (map[string]string, bool) {
+	if !strings.HasPrefix(escapedPath, "/") {
+		return nil, false
+	}
+	path := escapedPath[1:]
+	if t.verb != "" {
+		if !strings.HasSuffix(path, ":"+t.verb) {
+			return nil, false
+		}
+		path = strings.TrimSuffix(path, ":"+t.verb)
+	}
+	parts := strings.Split(path, "/")
+
+	captured := make(map[string][]string)
+	i := 0
+	for _, s := range t.segments {
+		switch s.kind {
+		case deepWildcardSegment:
+			if s.variable != "" {
+				captured[s.variable] = append(captured[s.variable], parts[i:]...)
+			}
+			i = len(parts)
+			continue
+		case wildcardSegment:
+			if i >= len(parts) || parts[i] == "" {
+				return nil, false
+			}
+		case literalSegment:
+			if i >= len(parts) || parts[i] != s.literal {
+				return nil, false
+			}
+		}
+		if s.variable != "" {
+			captured[s.variable] = append(captured[s.variable], parts[i])
+		}
+		i++
+	}
+	if i != len(parts) {
+		return nil, false
+	}
+
+	vars := make(map[string]string, len(captured))
+	for k, v := range captured {
+		if len(v) == 1 {
+			u, err := url.PathUnescape(v[0])
+			if err != nil {
+				return nil, false
+			}
+			vars[k] = u
+			continue
+		}
+		for j, p := range v {
+			u, err := unescapeKeepSlash(p)
+			if err != nil {
+				return nil, false
+			}
+			v[j] = u
+		}
+		vars[k] = strings.Join(v, "/")
+	}
+	return vars, true
+}
+
+// unescapeKeepSlash unescapes a path segment except for escaped slashes
+func unescapeKeepSlash(s string) (string, error) {
+	s = strings.Replace(s, "%2F", "%252F", -1)
+	s = strings.Replace(s, "%2f", "%252f", -1)
+	return url.PathUnescape(s)
+}
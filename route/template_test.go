@@ -0,0 +1,239 @@
+package route
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTemplate(t *testing.T) {
+	cases := []struct {
+		name       string
+		template   string
+		variables  []string
+		errorIsNil bool
+	}{
+		{
+			name:       "literal",
+			template:   "/v1/books",
+			errorIsNil: true,
+		},
+		{
+			name:       "simple variable",
+			template:   "/v1/users/{user_id}",
+			variables:  []string{"user_id"},
+			errorIsNil: true,
+		},
+		{
+			name:       "nested field path",
+			template:   "/v1/{book.name=shelves/*/books/*}",
+			variables:  []string{"book.name"},
+			errorIsNil: true,
+		},
+		{
+			name:       "multiple variables and verb",
+			template:   "/v1/shelves/{shelf}/books/{book}:archive",
+			variables:  []string{"shelf", "book"},
+			errorIsNil: true,
+		},
+		{
+			name:       "deep wildcard",
+			template:   "/v1/{name=files/**}",
+			variables:  []string{"name"},
+			errorIsNil: true,
+		},
+		{
+			name:       "missing leading slash",
+			template:   "v1/books",
+			errorIsNil: false,
+		},
+		{
+			name:       "unterminated variable",
+			template:   "/v1/{name",
+			errorIsNil: false,
+		},
+		{
+			name:       "nested variable",
+			template:   "/v1/{name={id}}",
+			errorIsNil: false,
+		},
+		{
+			name:       "deep wildcard not last",
+			template:   "/v1/**/books",
+			errorIsNil: false,
+		},
+		{
+			name:       "invalid field path",
+			template:   "/v1/{1name}",
+			errorIsNil: false,
+		},
+		{
+			name:       "empty segment",
+			template:   "/v1//books",
+			errorIsNil: false,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpl, err := ParseTemplate(tc.template)
+			if got, want := err == nil, tc.errorIsNil; got != want {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+			if err != nil {
+				return
+			}
+			if got, want := tmpl.Variables(), tc.variables; !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+			if got, want := tmpl.String(), tc.template; got != want {
+				t.Fatalf("got %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestTemplate_Match(t *testing.T) {
+	cases := []struct {
+		name     string
+		template string
+		path     string
+		matched  bool
+		params   map[string]string
+	}{
+		{
+			name:     "literal",
+			template: "/v1/books",
+			path:     "/v1/books",
+			matched:  true,
+			params:   map[string]string{},
+		},
+		{
+			name:     "literal mismatch",
+			template: "/v1/books",
+			path:     "/v1/shelves",
+			matched:  false,
+		},
+		{
+			name:     "simple variable",
+			template: "/v1/users/{user_id}",
+			path:     "/v1/users/42",
+			matched:  true,
+			params:   map[string]string{"user_id": "42"},
+		},
+		{
+			name:     "simple variable is unescaped",
+			template: "/v1/users/{user_id}",
+			path:     "/v1/users/a%2Fb%20c",
+			matched:  true,
+			params:   map[string]string{"user_id": "a/b c"},
+		},
+		{
+			name:     "variable does not match empty segment",
+			template: "/v1/users/{user_id}",
+			path:     "/v1/users/",
+			matched:  false,
+		},
+		{
+			name:     "too many segments",
+			template: "/v1/users/{user_id}",
+			path:     "/v1/users/42/books",
+			matched:  false,
+		},
+		{
+			name:     "multi segment variable",
+			template: "/v1/{name=shelves/*/books/*}",
+			path:     "/v1/shelves/1/books/a%2Fb",
+			matched:  true,
+			params:   map[string]string{"name": "shelves/1/books/a%2Fb"},
+		},
+		{
+			name:     "multi segment variable mismatch",
+			template: "/v1/{name=shelves/*/books/*}",
+			path:     "/v1/shelves/1/authors/2",
+			matched:  false,
+		},
+		{
+			name:     "verb",
+			template: "/v1/{name=shelves/*/books/*}:archive",
+			path:     "/v1/shelves/1/books/2:archive",
+			matched:  true,
+			params:   map[string]string{"name": "shelves/1/books/2"},
+		},
+		{
+			name:     "verb mismatch",
+			template: "/v1/{name=shelves/*/books/*}:archive",
+			path:     "/v1/shelves/1/books/2:restore",
+			matched:  false,
+		},
+		{
+			name:     "deep wildcard",
+			template: "/v1/{name=files/**}",
+			path:     "/v1/files/a/b/c",
+			matched:  true,
+			params:   map[string]string{"name": "files/a/b/c"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpl, err := ParseTemplate(tc.template)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			params, ok := tmpl.Match(tc.path)
+			if got, want := ok, tc.matched; got != want {
+				t.Fatalf("got %t, want %t", got, want)
+			}
+			if got, want := params, tc.params; !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestTemplate_OpenAPIPath(t *testing.T) {
+	cases := []struct {
+		template string
+		want     string
+	}{
+		{"/v1/books", "/v1/books"},
+		{"/v1/users/{user_id}", "/v1/users/{user_id}"},
+		{"/v1/{book.name=shelves/*/books/*}", "/v1/{book.name}"},
+		{"/v1/shelves/{shelf}/books/{book}:archive", "/v1/shelves/{shelf}/books/{book}:archive"},
+		{"/v1/{name=files/**}", "/v1/{name}"},
+		{"/v1/*/books", "/v1/*/books"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.template, func(t *testing.T) {
+			tmpl, err := ParseTemplate(tc.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := tmpl.OpenAPIPath(), tc.want; got != want {
+				t.Fatalf("got %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestTemplate_Pattern(t *testing.T) {
+	cases := []struct {
+		template string
+		want     string
+	}{
+		{"/v1/books", "/v1/books"},
+		{"/v1/users/{user_id}", "/v1/users/*"},
+		{"/v1/{book.name=shelves/*/books/*}", "/v1/shelves/*/books/*"},
+		{"/v1/shelves/{shelf}/books/{book}:archive", "/v1/shelves/*/books/*:archive"},
+		{"/v1/{name=files/**}", "/v1/files/**"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.template, func(t *testing.T) {
+			tmpl, err := ParseTemplate(tc.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := tmpl.Pattern(), tc.want; got != want {
+				t.Fatalf("got %s, want %s", got, want)
+			}
+		})
+	}
+}